@@ -0,0 +1,352 @@
+/*
+account turns persons into users of the API: each person can have a
+password and any number of API keys, each key limited to a set of
+scopes.
+*/
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/persons"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrExists          = errors.New("account: already exists")
+	ErrBadCredentials  = errors.New("account: bad credentials")
+	ErrUnknownScope    = errors.New("account: unknown scope")
+	ErrNoKey           = errors.New("account: no such key")
+	errMalformedAPIKey = fmt.Errorf("%w: malformed api key", ErrBadCredentials)
+)
+
+// Scope is something an API key is allowed to do.
+type Scope string
+
+const (
+	// ScopeRead allows reading market data and the caller's own data.
+	ScopeRead Scope = "read"
+	// ScopeWatchlists allows changing the caller's watchlists.
+	ScopeWatchlists Scope = "watchlists"
+	// ScopeAlerts allows managing the caller's alerts.
+	ScopeAlerts Scope = "alerts"
+	// ScopeTrade allows placing and cancelling orders.
+	ScopeTrade Scope = "trade"
+	// ScopeKeys allows issuing and revoking the caller's API keys.
+	ScopeKeys Scope = "keys"
+	// ScopeAdmin allows managing the accounts of others, as far as the
+	// caller's roles permit.
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes lists every scope.
+var Scopes = []Scope{ScopeRead, ScopeWatchlists, ScopeAlerts, ScopeTrade, ScopeKeys, ScopeAdmin}
+
+// Key describes an issued API key. The secret itself is only shown
+// once, when the key is issued.
+type Key struct {
+	ID        string    `json:"id"`
+	PersonID  int64     `json:"person_id"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Allows reports whether k carries scope.
+func (k Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps the accounts and api_keys tables next to persons. Both are
+// keyed by person id; the username is only used to log in, so renaming
+// a person does not touch their account.
+type Store struct {
+	db      *sql.DB
+	persons *persons.Repository
+	now     func() time.Time
+}
+
+// NewStore creates the accounts and api_keys tables in db if needed.
+func NewStore(db *sql.DB) (*Store, error) {
+	repo, err := persons.NewRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS accounts(
+			person_id INTEGER PRIMARY KEY REFERENCES persons(id),
+			username TEXT NOT NULL UNIQUE,
+			password_hash BLOB,
+			created_at INT);
+		CREATE TABLE IF NOT EXISTS api_keys(
+			id TEXT PRIMARY KEY,
+			person_id INT REFERENCES accounts(person_id),
+			secret_hash TEXT,
+			scopes TEXT,
+			created_at INT)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db, repo, time.Now}, nil
+}
+
+// Register adds p to persons with an account logging in as username
+// with password, and returns the new person. It never takes over an
+// existing person, whatever their name; see Attach for that.
+func (s *Store) Register(p mylib.Person, username, password string) (persons.Record, error) {
+	var rec persons.Record
+	err := s.addAccount(username, password, func(tx *sql.Tx) (int64, error) {
+		var err error
+		rec, err = s.persons.CreateTx(tx, p, username)
+		return rec.ID, err
+	})
+	return rec, err
+}
+
+// Attach gives the existing, live person id an account logging in as
+// username with password. Callers must check that whoever asks may
+// manage that person's account.
+func (s *Store) Attach(id int64, username, password string) (persons.Record, error) {
+	rec, err := s.persons.Get(id)
+	if err != nil {
+		return rec, err
+	}
+	err = s.addAccount(username, password, func(tx *sql.Tx) (int64, error) {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE person_id = ?`, id).Scan(&n); err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			return 0, fmt.Errorf("%w: person %d has an account", ErrExists, id)
+		}
+		return id, nil
+	})
+	return rec, err
+}
+
+// addAccount inserts an account for the person returned by person, in
+// the same transaction.
+func (s *Store) addAccount(username, password string, person func(tx *sql.Tx) (int64, error)) error {
+	if username == "" || password == "" {
+		return errors.New("account: username and password are required")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE username = ?`, username).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s", ErrExists, username)
+	}
+	id, err := person(tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO accounts(person_id, username, password_hash, created_at) VALUES(?, ?, ?, ?)`,
+		id, username, hash, s.now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetPassword replaces the password of username.
+func (s *Store) SetPassword(username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE accounts SET password_hash = ? WHERE username = ?`, hash, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrBadCredentials
+	}
+	return nil
+}
+
+// dummyHash is compared against when the username is unknown, so that
+// a login takes as long whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Login checks username and password and returns the person. A deleted
+// person can no longer log in.
+func (s *Store) Login(username, password string) (persons.Record, error) {
+	var id int64
+	var hash []byte
+	err := s.db.QueryRow(`SELECT person_id, password_hash FROM accounts WHERE username = ?`, username).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return persons.Record{}, ErrBadCredentials
+	}
+	if err != nil {
+		return persons.Record{}, err
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return persons.Record{}, ErrBadCredentials
+	}
+	return s.person(id)
+}
+
+// Lookup returns the live person logging in as username.
+func (s *Store) Lookup(username string) (persons.Record, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT person_id FROM accounts WHERE username = ?`, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return persons.Record{}, fmt.Errorf("%w: no account %s", ErrBadCredentials, username)
+	}
+	if err != nil {
+		return persons.Record{}, err
+	}
+	return s.person(id)
+}
+
+// IssueKey creates an API key for the person id limited to scopes. The
+// returned token is the only copy of the secret.
+func (s *Store) IssueKey(id int64, scopes ...Scope) (string, Key, error) {
+	for _, sc := range scopes {
+		if !known(sc) {
+			return "", Key{}, fmt.Errorf("%w: %s", ErrUnknownScope, sc)
+		}
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE person_id = ?`, id).Scan(&n); err != nil {
+		return "", Key{}, err
+	}
+	if n == 0 {
+		return "", Key{}, fmt.Errorf("%w: no account for person %d", ErrBadCredentials, id)
+	}
+	keyID, secret := random(8), random(32)
+	k := Key{ID: keyID, PersonID: id, Scopes: scopes, CreatedAt: time.Unix(s.now().Unix(), 0)}
+	_, err := s.db.Exec(`INSERT INTO api_keys(id, person_id, secret_hash, scopes, created_at) VALUES(?, ?, ?, ?, ?)`,
+		k.ID, k.PersonID, hash(secret), joinScopes(scopes), k.CreatedAt.Unix())
+	if err != nil {
+		return "", Key{}, err
+	}
+	return keyID + "." + secret, k, nil
+}
+
+// Keys lists the keys of the person id.
+func (s *Store) Keys(id int64) ([]Key, error) {
+	rows, err := s.db.Query(`SELECT id, person_id, scopes, created_at FROM api_keys WHERE person_id = ? ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		var scopes string
+		var created int64
+		if err := rows.Scan(&k.ID, &k.PersonID, &scopes, &created); err != nil {
+			return nil, err
+		}
+		k.Scopes, k.CreatedAt = splitScopes(scopes), time.Unix(created, 0)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey deletes the key keyID of the person id.
+func (s *Store) RevokeKey(id int64, keyID string) error {
+	res, err := s.db.Exec(`DELETE FROM api_keys WHERE person_id = ? AND id = ?`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrNoKey, keyID)
+	}
+	return nil
+}
+
+// Verify checks an API key token and returns its owner and key.
+func (s *Store) Verify(token string) (persons.Record, Key, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return persons.Record{}, Key{}, errMalformedAPIKey
+	}
+	var k Key
+	var want, scopes string
+	var created int64
+	err := s.db.QueryRow(`SELECT id, person_id, secret_hash, scopes, created_at FROM api_keys WHERE id = ?`, id).
+		Scan(&k.ID, &k.PersonID, &want, &scopes, &created)
+	if err == sql.ErrNoRows || (err == nil && subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(want)) != 1) {
+		return persons.Record{}, Key{}, ErrBadCredentials
+	}
+	if err != nil {
+		return persons.Record{}, Key{}, err
+	}
+	k.Scopes, k.CreatedAt = splitScopes(scopes), time.Unix(created, 0)
+	p, err := s.person(k.PersonID)
+	return p, k, err
+}
+
+// person returns the live person id; a deleted one has no credentials.
+func (s *Store) person(id int64) (persons.Record, error) {
+	rec, err := s.persons.Get(id)
+	if errors.Is(err, persons.ErrNotFound) || errors.Is(err, persons.ErrDeleted) {
+		return persons.Record{}, ErrBadCredentials
+	}
+	return rec, err
+}
+
+func known(scope Scope) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func joinScopes(scopes []Scope) string {
+	s := make([]string, len(scopes))
+	for i, sc := range scopes {
+		s[i] = string(sc)
+	}
+	return strings.Join(s, " ")
+}
+
+func splitScopes(s string) []Scope {
+	var scopes []Scope
+	for _, f := range strings.Fields(s) {
+		scopes = append(scopes, Scope(f))
+	}
+	return scopes
+}
+
+// random returns n random bytes, hex encoded.
+func random(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hash is enough for API key secrets, which unlike passwords are long and random.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
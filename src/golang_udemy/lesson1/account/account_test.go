@@ -0,0 +1,189 @@
+package account
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/universe"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newStore(t *testing.T) (*Store, *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+func TestAccounts(t *testing.T) {
+	s, _ := newStore(t)
+	alice, err := s.Register(mylib.Person{Name: "Alice", Age: 30}, "alice", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register(mylib.Person{Name: "Other"}, "alice", "x"); !errors.Is(err, ErrExists) {
+		t.Error("Expected ErrExists, got", err)
+	}
+	if p, err := s.Login("alice", "s3cret"); err != nil || p.ID != alice.ID || p.Age != 30 {
+		t.Error("Expected alice aged 30, got", p, err)
+	}
+	// signing up under an existing name makes a new person
+	if p, _ := s.Register(mylib.Person{Name: "Alice"}, "alice2", "x"); p.ID == alice.ID {
+		t.Error("Expected a new person, got", p)
+	}
+	bob, _ := s.persons.Create(mylib.Person{Name: "Bob"}, "test")
+	if _, err := s.Attach(bob.ID, "bob", "pw"); err != nil {
+		t.Error("Expected to attach an account to bob, got", err)
+	}
+	if _, err := s.Attach(bob.ID, "bob2", "pw"); !errors.Is(err, ErrExists) {
+		t.Error("Expected bob to have an account already, got", err)
+	}
+	if _, err := s.Login("alice", "wrong"); !errors.Is(err, ErrBadCredentials) {
+		t.Error("Expected bad credentials, got", err)
+	}
+
+	token, k, err := s.IssueKey(alice.ID, ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, got, err := s.Verify(token); err != nil || p.ID != alice.ID || !got.Allows(ScopeRead) || got.Allows(ScopeTrade) {
+		t.Error("Expected a read key of alice, got", p, got, err)
+	}
+	if _, _, err := s.IssueKey(alice.ID, "root"); !errors.Is(err, ErrUnknownScope) {
+		t.Error("Expected unknown scope, got", err)
+	}
+	s.RevokeKey(alice.ID, k.ID)
+	if _, _, err := s.Verify(token); !errors.Is(err, ErrBadCredentials) {
+		t.Error("Expected revoked key to fail, got", err)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	s, _ := newStore(t)
+	alice, _ := s.Register(mylib.Person{Name: "Alice"}, "alice", "s3cret")
+	token, _, _ := s.IssueKey(alice.ID, ScopeRead)
+
+	// renaming the person keeps the login
+	s.persons.Update(alice.ID, mylib.Person{Name: "Alice Smith"}, "test")
+	if p, err := s.Login("alice", "s3cret"); err != nil || p.Name != "Alice Smith" {
+		t.Error("Expected login to survive a rename, got", p, err)
+	}
+
+	// a new person with a deleted person's name inherits nothing
+	s.persons.Delete(alice.ID, "test")
+	other, _ := s.persons.Create(mylib.Person{Name: "Alice Smith"}, "test")
+	if _, err := s.Login("alice", "s3cret"); !errors.Is(err, ErrBadCredentials) {
+		t.Error("Expected deleted person not to log in, got", err)
+	}
+	if p, _, err := s.Verify(token); !errors.Is(err, ErrBadCredentials) {
+		t.Error("Expected deleted person's key to fail, got", p, err)
+	}
+	if _, _, err := s.IssueKey(other.ID, ScopeRead); !errors.Is(err, ErrBadCredentials) {
+		t.Error("Expected no account for the new person, got", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s, db := newStore(t)
+	s.Register(mylib.Person{Name: "Alice"}, "alice", "s3cret")
+	bob, _ := s.Register(mylib.Person{Name: "Bob"}, "bob", "hunter2")
+	u, _ := universe.NewStore(db)
+	u.AddSymbols(universe.Symbol{Symbol: "BTC-USD", Market: "coinbase"})
+	u.CreateWatchlist(bob.ID, "bobs")
+
+	carol, _ := s.persons.Create(mylib.Person{Name: "Carol"}, "test")
+	mayNot := func(c Caller, id int64) (bool, error) { return c.Person.ID == id, nil }
+
+	mux := http.NewServeMux()
+	accounts := NewHandler(s, mayNot)
+	mux.Handle("/accounts", accounts)
+	mux.Handle("/accounts/", accounts)
+	mux.Handle("/keys", accounts)
+	mux.Handle("/keys/", accounts)
+	watchlists := s.Require(universe.NewHandler(u, Owner), ScopeRead, ScopeWatchlists)
+	mux.Handle("/watchlists", watchlists)
+	mux.Handle("/watchlists/", watchlists)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, auth string, body interface{}) *http.Response {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, _ := http.NewRequest(method, srv.URL+path, &b)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		} else {
+			req.SetBasicAuth("alice", "s3cret")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := do("POST", "/keys", "", map[string]interface{}{"scopes": []Scope{ScopeRead}})
+	var issued struct{ Token string }
+	json.NewDecoder(res.Body).Decode(&issued)
+	if res.StatusCode != http.StatusOK || issued.Token == "" {
+		t.Fatal("Expected a key, got", res.Status)
+	}
+
+	if res = do("PUT", "/watchlists/mine", issued.Token, nil); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected read key to be forbidden from writing, got", res.Status)
+	}
+	if res = do("PUT", "/watchlists/mine", "", nil); res.StatusCode != http.StatusNoContent {
+		t.Error("Expected password login to write, got", res.Status)
+	}
+	res = do("GET", "/watchlists", issued.Token, nil)
+	body, _ := io.ReadAll(res.Body)
+	if string(bytes.TrimSpace(body)) != `["mine"]` {
+		t.Error("Expected only alice's watchlists, got", string(body))
+	}
+	if res = do("GET", "/watchlists", "nope.nope", nil); res.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected unauthorized, got", res.Status)
+	}
+	if res = do("POST", "/keys", issued.Token, map[string]interface{}{"scopes": []Scope{ScopeRead}}); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected a read key not to issue keys, got", res.Status)
+	}
+	res = do("POST", "/keys", "", map[string]interface{}{"scopes": []Scope{ScopeRead, ScopeKeys}})
+	json.NewDecoder(res.Body).Decode(&issued)
+	if res = do("POST", "/keys", issued.Token, map[string]interface{}{"scopes": []Scope{ScopeTrade}}); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected escalation to be forbidden, got", res.Status)
+	}
+	if res = do("POST", "/keys", issued.Token, map[string]interface{}{"scopes": []Scope{ScopeRead}}); res.StatusCode != http.StatusOK {
+		t.Error("Expected a keys key to issue a narrower key, got", res.Status)
+	}
+
+	claim := map[string]string{"username": "carol", "password": "pw"}
+	req, _ := http.NewRequest("POST", srv.URL+fmt.Sprintf("/accounts/%d", carol.ID), strings.NewReader(`{"username":"carol","password":"pw"}`))
+	if res, _ = http.DefaultClient.Do(req); res.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected anonymous attach to be unauthorized, got", res.Status)
+	}
+	if res = do("POST", fmt.Sprintf("/accounts/%d", carol.ID), "", claim); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected alice not to attach an account to carol, got", res.Status)
+	}
+	req, _ = http.NewRequest("POST", srv.URL+"/accounts", strings.NewReader(`{"username":"carol","password":"pw","name":"Carol"}`))
+	res, _ = http.DefaultClient.Do(req)
+	var signed struct{ ID int64 }
+	json.NewDecoder(res.Body).Decode(&signed)
+	if res.StatusCode != http.StatusOK || signed.ID == carol.ID {
+		t.Error("Expected sign-up to make a new person, got", res.Status, signed.ID)
+	}
+}
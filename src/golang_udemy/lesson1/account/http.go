@@ -0,0 +1,202 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/persons"
+	"golang_udemy/lesson1/reply"
+)
+
+// Caller is who made a request. Key is nil when the caller logged in
+// with a password, which grants every scope.
+type Caller struct {
+	Person persons.Record
+	Key    *Key
+}
+
+// Allows reports whether the caller may act within scope.
+func (c Caller) Allows(scope Scope) bool {
+	return c.Key == nil || c.Key.Allows(scope)
+}
+
+var errForbidden = errors.New("forbidden")
+
+type callerKey struct{}
+
+// CallerFrom returns the caller that Require stored in ctx.
+func CallerFrom(ctx context.Context) (Caller, bool) {
+	c, ok := ctx.Value(callerKey{}).(Caller)
+	return c, ok
+}
+
+// Owner returns the id of the authenticated person behind r. It is a
+// universe.OwnerFunc for handlers wrapped by Require.
+func Owner(r *http.Request) (int64, error) {
+	c, ok := CallerFrom(r.Context())
+	if !ok {
+		return 0, errors.New("not authenticated")
+	}
+	return c.Person.ID, nil
+}
+
+// Actor returns the authenticated person behind r. It is a
+// persons.ActorFunc for handlers wrapped by Require.
+func Actor(r *http.Request) (mylib.Person, error) {
+	c, ok := CallerFrom(r.Context())
+	if !ok {
+		return mylib.Person{}, errors.New("not authenticated")
+	}
+	return c.Person.Person, nil
+}
+
+// Authenticate reads an API key from "Authorization: Bearer <key>" or a
+// username and password from basic auth.
+func (s *Store) Authenticate(r *http.Request) (Caller, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		p, k, err := s.Verify(strings.TrimSpace(token))
+		return Caller{p, &k}, err
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		p, err := s.Login(username, password)
+		return Caller{Person: p}, err
+	}
+	return Caller{}, ErrBadCredentials
+}
+
+// Require authenticates every request to next. GET and HEAD requests
+// need the read scope, anything else the write scope.
+func (s *Store) Require(next http.Handler, read, write Scope) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := s.Authenticate(r)
+		if errors.Is(err, ErrBadCredentials) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="lesson1"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		scope := write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = read
+		}
+		if !c.Allows(scope) {
+			http.Error(w, "api key lacks scope "+string(scope), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+// MayManage reports whether c may manage the account of the person id.
+// rbac.Enforcer.MayManage fits.
+type MayManage func(c Caller, personID int64) (bool, error)
+
+// NewHandler serves account management:
+//
+//	POST   /accounts            {"username": ..., "password": ..., "name": ..., "age": ...}
+//	POST   /accounts/{person}   {"username": ..., "password": ...}
+//	GET    /keys
+//	POST   /keys                {"scopes": [...]}
+//	DELETE /keys/{id}
+//
+// POST /accounts signs up a new person. Giving an existing person an
+// account needs authentication, the admin scope for API keys, and may's
+// consent. Key routes act for the authenticated caller and need the keys
+// scope to change anything; a caller using an API key can only issue
+// keys with scopes of its own.
+func NewHandler(s *Store, may MayManage) http.Handler {
+	type signup struct {
+		mylib.Person
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /accounts", func(w http.ResponseWriter, r *http.Request) {
+		var req signup
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec, err := s.Register(req.Person, req.Username, req.Password)
+		reply.JSON(w, rec, err, codes...)
+	})
+
+	auth := http.NewServeMux()
+	auth.HandleFunc("POST /accounts/{person}", func(w http.ResponseWriter, r *http.Request) {
+		c, _ := CallerFrom(r.Context())
+		id, err := strconv.ParseInt(r.PathValue("person"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad person id", http.StatusBadRequest)
+			return
+		}
+		if ok, err := may(c, id); err != nil || !ok {
+			if err == nil {
+				err = fmt.Errorf("may not manage the account of person %d", id)
+			}
+			reply.JSON(w, nil, fmt.Errorf("%w: %v", errForbidden, err), codes...)
+			return
+		}
+		var req signup
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec, err := s.Attach(id, req.Username, req.Password)
+		reply.JSON(w, rec, err, codes...)
+	})
+	auth.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		c, _ := CallerFrom(r.Context())
+		list, err := s.Keys(c.Person.ID)
+		reply.JSON(w, list, err, codes...)
+	})
+	auth.HandleFunc("POST /keys", func(w http.ResponseWriter, r *http.Request) {
+		c, _ := CallerFrom(r.Context())
+		var req struct {
+			Scopes []Scope `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, sc := range req.Scopes {
+			if !c.Allows(sc) {
+				http.Error(w, "api key lacks scope "+string(sc), http.StatusForbidden)
+				return
+			}
+		}
+		token, k, err := s.IssueKey(c.Person.ID, req.Scopes...)
+		reply.JSON(w, struct {
+			Key
+			Token string `json:"token"`
+		}{k, token}, err, codes...)
+	})
+	auth.HandleFunc("DELETE /keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		c, _ := CallerFrom(r.Context())
+		reply.JSON(w, nil, s.RevokeKey(c.Person.ID, r.PathValue("id")), codes...)
+	})
+	mux.Handle("/accounts/", s.Require(auth, ScopeAdmin, ScopeAdmin))
+	keys := s.Require(auth, ScopeRead, ScopeKeys)
+	mux.Handle("/keys", keys)
+	mux.Handle("/keys/", keys)
+	return mux
+}
+
+// codes are the statuses of the errors the handlers return.
+var codes = []reply.Code{
+	{Err: ErrExists, Status: http.StatusConflict},
+	{Err: ErrNoKey, Status: http.StatusNotFound},
+	{Err: persons.ErrNotFound, Status: http.StatusNotFound},
+	{Err: persons.ErrDeleted, Status: http.StatusNotFound},
+	{Err: errForbidden, Status: http.StatusForbidden},
+	{Err: ErrUnknownScope, Status: http.StatusBadRequest},
+	{Err: ErrBadCredentials, Status: http.StatusBadRequest},
+}
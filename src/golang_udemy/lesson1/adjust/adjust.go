@@ -0,0 +1,71 @@
+/*
+adjust stores corporate actions and back-adjusts candle series for them
+on read, leaving the raw series untouched.
+*/
+package adjust
+
+import (
+	"sort"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Action is a split and/or cash dividend taking effect on ExDate.
+// Split is new shares per old share (2 for a 2-for-1 split, 0 for none).
+type Action struct {
+	Symbol   string
+	ExDate   time.Time
+	Split    float64
+	Dividend float64
+}
+
+// Mode selects raw or adjusted prices.
+type Mode int
+
+const (
+	Raw Mode = iota
+	Adjusted
+)
+
+// Adjust returns a copy of q with every bar before an action's ex-date
+// scaled so the series has no jumps from splits and dividends.
+// Prices are divided by the split ratio and multiplied by
+// 1 - dividend/previous close; volume is multiplied by the split ratio.
+func Adjust(q quote.Quote, actions []Action) quote.Quote {
+	out := quote.NewQuote(q.Symbol, len(q.Close))
+	out.Precision = q.Precision
+	copy(out.Date, q.Date)
+	copy(out.Open, q.Open)
+	copy(out.High, q.High)
+	copy(out.Low, q.Low)
+	copy(out.Close, q.Close)
+	copy(out.Volume, q.Volume)
+
+	sorted := append([]Action(nil), actions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ExDate.After(sorted[j].ExDate) })
+
+	price, volume := 1.0, 1.0
+	a := 0
+	for i := len(q.Date) - 1; i >= 0; i-- {
+		// walking back in time, bar i is the last one before each
+		// ex-date it is the first to precede
+		for a < len(sorted) && q.Date[i].Before(sorted[a].ExDate) {
+			act := sorted[a]
+			if act.Split > 0 {
+				price /= act.Split
+				volume *= act.Split
+			}
+			if act.Dividend > 0 && q.Close[i] > 0 {
+				price *= 1 - act.Dividend/q.Close[i]
+			}
+			a++
+		}
+		out.Open[i] *= price
+		out.High[i] *= price
+		out.Low[i] *= price
+		out.Close[i] *= price
+		out.Volume[i] *= volume
+	}
+	return out
+}
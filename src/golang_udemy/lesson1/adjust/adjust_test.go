@@ -0,0 +1,55 @@
+package adjust
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAdjust(t *testing.T) {
+	q := quote.NewQuote("AAPL", 4)
+	for i, c := range []float64{100, 100, 50, 50} {
+		q.Date[i] = day(i + 1)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i] = c, c, c, c
+		q.Volume[i] = 10
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(Action{Symbol: "AAPL", ExDate: day(3), Split: 2}, Action{Symbol: "AAPL", ExDate: day(4), Dividend: 5})
+
+	raw, _ := s.Prices(q, Raw)
+	adj, err := s.Prices(q, Adjusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2:1 split on day 3, then a 10% dividend on day 4
+	want := []float64{45, 45, 45, 50}
+	for i := range want {
+		if math.Abs(adj.Close[i]-want[i]) > 1e-9 {
+			t.Error("Expected", want, "got", adj.Close)
+			break
+		}
+	}
+	if adj.Volume[0] != 20 || adj.Volume[2] != 10 {
+		t.Error("Expected split adjusted volume, got", adj.Volume)
+	}
+	if raw.Close[0] != 100 || q.Close[0] != 100 {
+		t.Error("Expected raw prices untouched, got", raw.Close[0], q.Close[0])
+	}
+}
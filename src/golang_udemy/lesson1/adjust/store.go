@@ -0,0 +1,78 @@
+package adjust
+
+import (
+	"database/sql"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Store keeps corporate actions per symbol in the corporate_actions table.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates the corporate_actions table in db if needed.
+func NewStore(db *sql.DB) (*Store, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS corporate_actions(
+		symbol TEXT,
+		ex_date INT,
+		split REAL,
+		dividend REAL,
+		PRIMARY KEY(symbol, ex_date))`)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// Add records actions, replacing any already stored for the same symbol and ex-date.
+func (s *Store) Add(actions ...Action) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, a := range actions {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO corporate_actions(symbol, ex_date, split, dividend)
+			VALUES(?, ?, ?, ?)`, a.Symbol, a.ExDate.Unix(), a.Split, a.Dividend)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Actions returns the actions of symbol, oldest first.
+func (s *Store) Actions(symbol string) ([]Action, error) {
+	rows, err := s.db.Query(`SELECT symbol, ex_date, split, dividend FROM corporate_actions
+		WHERE symbol = ? ORDER BY ex_date`, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var actions []Action
+	for rows.Next() {
+		var a Action
+		var exDate int64
+		if err := rows.Scan(&a.Symbol, &exDate, &a.Split, &a.Dividend); err != nil {
+			return nil, err
+		}
+		a.ExDate = time.Unix(exDate, 0).UTC()
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
+
+// Prices returns q as is for Raw, or back-adjusted with the stored
+// actions of q.Symbol for Adjusted.
+func (s *Store) Prices(q quote.Quote, mode Mode) (quote.Quote, error) {
+	if mode == Raw {
+		return q, nil
+	}
+	actions, err := s.Actions(q.Symbol)
+	if err != nil {
+		return q, err
+	}
+	return Adjust(q, actions), nil
+}
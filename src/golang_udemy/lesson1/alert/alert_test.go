@@ -0,0 +1,190 @@
+package alert
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func series(closes ...float64) quote.Quote {
+	q := quote.NewQuote("BTC-USD", len(closes))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range closes {
+		q.Date[i] = start.Add(time.Duration(i) * 5 * time.Minute)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i] = c, c, c, c
+	}
+	return q
+}
+
+func TestEngine(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	h, err := NewHistory(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	e := NewEngine(h, WriterNotifier{&out})
+	e.Add(Rule{Owner: 1, ID: "move", Symbol: "BTC-USD", Condition: PriceMove{5, 15 * time.Minute}, Cooldown: time.Hour})
+
+	q := series(100, 101, 102, 106)
+	fired, _ := e.Evaluate(q)
+	if len(fired) != 1 {
+		t.Fatal("Expected 1 alert, got", len(fired))
+	}
+	// same bar again is de-duplicated
+	if fired, _ = e.Evaluate(q); len(fired) != 0 {
+		t.Error("Expected duplicate to be dropped, got", fired)
+	}
+	// next bar still moves 5% but is inside the cooldown
+	if fired, _ = e.Evaluate(series(100, 101, 102, 106, 108)); len(fired) != 0 {
+		t.Error("Expected cooldown, got", fired)
+	}
+	if !strings.Contains(out.String(), "price moved +6.00%") {
+		t.Error("Unexpected output", out.String())
+	}
+
+	// a fresh engine picks up where the history left off
+	e2 := NewEngine(h)
+	e2.Add(Rule{Owner: 1, ID: "move", Symbol: "BTC-USD", Condition: PriceMove{5, 15 * time.Minute}, Cooldown: time.Hour})
+	if fired, _ = e2.Evaluate(q); len(fired) != 0 {
+		t.Error("Expected history to suppress alert, got", fired)
+	}
+	list, _ := h.List(1, "BTC-USD")
+	if len(list) != 1 || list[0].Owner != 1 {
+		t.Error("Expected 1 alert in history, got", list)
+	}
+
+	// another person's rule of the same id is their own
+	e2.Add(Rule{Owner: 2, ID: "move", Symbol: "BTC-USD", Condition: PriceMove{5, 15 * time.Minute}, Cooldown: time.Hour})
+	if fired, _ = e2.Evaluate(q); len(fired) != 1 || fired[0].Owner != 2 {
+		t.Error("Expected only the second owner's rule to fire, got", fired)
+	}
+	if list, _ := h.List(2, ""); len(list) != 1 {
+		t.Error("Expected the second owner to see 1 alert, got", list)
+	}
+	if list, _ := h.List(1, ""); len(list) != 1 {
+		t.Error("Expected the first owner to still see 1 alert, got", list)
+	}
+
+	owner := func(r *http.Request) (int64, error) { return 2, nil }
+	w := httptest.NewRecorder()
+	NewHandler(h, owner).ServeHTTP(w, httptest.NewRequest("GET", "/alerts?symbol=BTC-USD", nil))
+	var got []Alert
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || len(got) != 1 || got[0].Owner != 2 {
+		t.Error("Expected the owner's alert over HTTP, got", w.Code, got, err)
+	}
+}
+
+func TestCrossBelow(t *testing.T) {
+	c := CrossBelow{Close, 100}
+	if ok, _ := c.Check(series(101, 99)); !ok {
+		t.Error("Expected cross below")
+	}
+	if ok, _ := c.Check(series(99, 98)); ok {
+		t.Error("Expected no cross when already below")
+	}
+	if ok, _ := (CrossBelow{RSI(14), 30}).Check(series(99, 98)); ok {
+		t.Error("Expected no RSI on a short series")
+	}
+	if ok, _ := (CrossBelow{Close, 0.5}).Check(series(1, 0)); !ok {
+		t.Error("Expected a close of 0 to count as a cross")
+	}
+	if ok, _ := (CrossAbove{SMA(2), 99}).Check(series(98, 99, 101)); !ok {
+		t.Error("Expected SMA(2) to cross above once warmed up")
+	}
+	if ok, _ := (CrossAbove{SMA(2), -1}).Check(series(98, 99)); ok {
+		t.Error("Expected the warm-up bar not to count")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	err := WebhookNotifier{URL: srv.URL}.Notify(Alert{RuleID: "r1", Symbol: "ETH-USD"})
+	if err != nil || got.RuleID != "r1" {
+		t.Error("Expected r1, got", got.RuleID, err)
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	body := make(chan string, 1)
+	go fakeSMTP(ln, body)
+
+	n := SMTPNotifier{Addr: ln.Addr().String(), From: "bot@example.com", To: []string{"me@example.com"}}
+	if err := n.Notify(Alert{RuleID: "r1", Symbol: "ETH-USD", Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-body; !strings.Contains(b, "Subject: [alert] ETH-USD r1") {
+		t.Error("Unexpected mail", b)
+	}
+
+	go fakeSMTP(ln, body)
+	if err := n.Notify(Alert{RuleID: "r1\r\nBcc: x@example.com", Symbol: "ETH-USD"}); err != nil {
+		t.Fatal(err)
+	}
+	if head, _, _ := strings.Cut(<-body, "\r\n\r\n"); strings.Contains(head, "\nBcc:") {
+		t.Error("Expected the line break in the subject to be encoded, got", head)
+	}
+}
+
+// fakeSMTP answers a single SMTP session and sends the message data to body.
+func fakeSMTP(ln net.Listener, body chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+	reply("220 localhost")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+		case "EHLO", "HELO", "MAIL", "RCPT":
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, _ := r.ReadString('\n')
+				if l == ".\r\n" || l == "" {
+					break
+				}
+				data.WriteString(l)
+			}
+			body <- data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package alert
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// History stores fired alerts in the alerts table, each under the person
+// owning its rule.
+type History struct {
+	db *sql.DB
+}
+
+// NewHistory creates the alerts table in db if needed.
+func NewHistory(db *sql.DB) (*History, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS alerts(
+		owner INTEGER,
+		rule_id TEXT,
+		symbol TEXT,
+		message TEXT,
+		bar_time INT,
+		fired_at INT,
+		UNIQUE(owner, rule_id, bar_time))`)
+	if err != nil {
+		return nil, err
+	}
+	return &History{db}, nil
+}
+
+// Add records a. It reports false if the rule already fired on that bar.
+func (h *History) Add(a Alert) (bool, error) {
+	res, err := h.db.Exec(`INSERT OR IGNORE INTO alerts(owner, rule_id, symbol, message, bar_time, fired_at)
+		VALUES(?, ?, ?, ?, ?, ?)`, a.Owner, a.RuleID, a.Symbol, a.Message, a.BarTime.Unix(), a.FiredAt.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Last returns the bar time of the latest alert of owner's rule ruleID.
+func (h *History) Last(owner int64, ruleID string) (time.Time, bool, error) {
+	var t sql.NullInt64
+	err := h.db.QueryRow(`SELECT MAX(bar_time) FROM alerts WHERE owner = ? AND rule_id = ?`, owner, ruleID).Scan(&t)
+	if err != nil || !t.Valid {
+		return time.Time{}, false, err
+	}
+	return time.Unix(t.Int64, 0).UTC(), true, nil
+}
+
+// List returns owner's alerts of symbol, oldest first. An empty symbol
+// lists all of them.
+func (h *History) List(owner int64, symbol string) ([]Alert, error) {
+	rows, err := h.db.Query(`SELECT owner, rule_id, symbol, message, bar_time, fired_at FROM alerts
+		WHERE owner = ? AND (? = '' OR symbol = ?) ORDER BY bar_time, rule_id`, owner, symbol, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		var bar, fired int64
+		if err := rows.Scan(&a.Owner, &a.RuleID, &a.Symbol, &a.Message, &bar, &fired); err != nil {
+			return nil, err
+		}
+		a.BarTime = time.Unix(bar, 0).UTC()
+		a.FiredAt = time.Unix(fired, 0).UTC()
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// rule identifies a rule; ids are only unique per owner.
+type rule struct {
+	owner int64
+	id    string
+}
+
+// Engine checks its rules against candles and dispatches what fires.
+type Engine struct {
+	rules     []Rule
+	history   *History
+	notifiers []Notifier
+	last      map[rule]time.Time
+	now       func() time.Time
+}
+
+// NewEngine returns an engine recording to history, which may be nil.
+func NewEngine(history *History, notifiers ...Notifier) *Engine {
+	return &Engine{
+		history:   history,
+		notifiers: notifiers,
+		last:      map[rule]time.Time{},
+		now:       time.Now,
+	}
+}
+
+// Add registers rules with the engine.
+func (e *Engine) Add(rules ...Rule) error {
+	for _, r := range rules {
+		if e.history != nil {
+			t, ok, err := e.history.Last(r.Owner, r.ID)
+			if err != nil {
+				return err
+			}
+			if ok {
+				e.last[rule{r.Owner, r.ID}] = t
+			}
+		}
+		e.rules = append(e.rules, r)
+	}
+	return nil
+}
+
+// Evaluate checks every rule for q.Symbol on the last bar of q and
+// returns the alerts that fired. Notifier failures are logged, not returned.
+func (e *Engine) Evaluate(q quote.Quote) ([]Alert, error) {
+	if len(q.Date) == 0 {
+		return nil, nil
+	}
+	bar := q.Date[len(q.Date)-1]
+	var fired []Alert
+	for _, r := range e.rules {
+		if r.Symbol != q.Symbol {
+			continue
+		}
+		if last, ok := e.last[rule{r.Owner, r.ID}]; ok && (!bar.After(last) || bar.Before(last.Add(r.Cooldown))) {
+			continue
+		}
+		ok, msg := r.Condition.Check(q)
+		if !ok {
+			continue
+		}
+		a := Alert{Owner: r.Owner, RuleID: r.ID, Symbol: r.Symbol, Message: msg, BarTime: bar, FiredAt: e.now()}
+		if e.history != nil {
+			added, err := e.history.Add(a)
+			if err != nil {
+				return fired, err
+			}
+			if !added {
+				continue
+			}
+		}
+		e.last[rule{r.Owner, r.ID}] = bar
+		for _, n := range e.notifiers {
+			if err := n.Notify(a); err != nil {
+				log.Printf("alert %s: notify: %v", a.RuleID, err)
+			}
+		}
+		fired = append(fired, a)
+	}
+	return fired, nil
+}
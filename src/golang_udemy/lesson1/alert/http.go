@@ -0,0 +1,28 @@
+package alert
+
+import (
+	"net/http"
+
+	"golang_udemy/lesson1/reply"
+	"golang_udemy/lesson1/universe"
+)
+
+// NewHandler serves the alerts of the person owner works out, never
+// anyone else's:
+//
+//	GET /alerts?symbol=
+//
+// It must be wrapped by account.Store.Require.
+func NewHandler(h *History, owner universe.OwnerFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /alerts", func(w http.ResponseWriter, r *http.Request) {
+		p, err := owner(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		list, err := h.List(p, r.URL.Query().Get("symbol"))
+		reply.JSON(w, list, err)
+	})
+	return mux
+}
@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Alert is a single firing of a rule.
+type Alert struct {
+	Owner   int64     `json:"owner"`
+	RuleID  string    `json:"rule_id"`
+	Symbol  string    `json:"symbol"`
+	Message string    `json:"message"`
+	BarTime time.Time `json:"bar_time"`
+	FiredAt time.Time `json:"fired_at"`
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("%s [%s] %s %s", a.BarTime.Format(time.RFC3339), a.RuleID, a.Symbol, a.Message)
+}
+
+// Notifier delivers alerts somewhere.
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// WriterNotifier writes one line per alert to W.
+type WriterNotifier struct {
+	W io.Writer
+}
+
+// Stdout returns a notifier printing alerts to standard output.
+func Stdout() WriterNotifier {
+	return WriterNotifier{os.Stdout}
+}
+
+func (n WriterNotifier) Notify(a Alert) error {
+	_, err := fmt.Fprintln(n.W, a)
+	return err
+}
+
+// FileNotifier appends one line per alert to Path.
+type FileNotifier struct {
+	Path string
+}
+
+func (n FileNotifier) Notify(a Alert) error {
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, a)
+	return err
+}
+
+// WebhookNotifier POSTs each alert as JSON to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", n.URL, resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier mails each alert through the server at Addr (host:port).
+// Auth may be nil for servers that do not require it.
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (n SMTPNotifier) Notify(a Alert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	// Q-encoding keeps line breaks in the symbol or rule out of the headers
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[alert] "+a.Symbol+" "+a.RuleID))
+	fmt.Fprintf(&msg, "\r\n%s\r\n", a)
+	return smtp.SendMail(n.Addr, n.Auth, n.From, n.To, []byte(msg.String()))
+}
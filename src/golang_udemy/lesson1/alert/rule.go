@@ -0,0 +1,136 @@
+/*
+alert evaluates rules against candle series and sends the alerts
+they raise to notifiers.
+*/
+package alert
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+	talib "github.com/markcheno/go-talib"
+)
+
+// Indicator computes one value per bar of q. Bars still warming up are
+// NaN.
+type Indicator struct {
+	Name string
+	Calc func(q quote.Quote) []float64
+}
+
+// Close is the closing price itself.
+var Close = Indicator{"close", func(q quote.Quote) []float64 { return q.Close }}
+
+// RSI returns the relative strength index of the close over n bars.
+func RSI(n int) Indicator {
+	return Indicator{fmt.Sprintf("RSI(%d)", n), guard(n, func(q quote.Quote) []float64 { return talib.Rsi(q.Close, n) })}
+}
+
+// SMA returns the simple moving average of the close over n bars.
+func SMA(n int) Indicator {
+	return Indicator{fmt.Sprintf("SMA(%d)", n), guard(n-1, func(q quote.Quote) []float64 { return talib.Sma(q.Close, n) })}
+}
+
+// EMA returns the exponential moving average of the close over n bars.
+func EMA(n int) Indicator {
+	return Indicator{fmt.Sprintf("EMA(%d)", n), guard(n-1, func(q quote.Quote) []float64 { return talib.Ema(q.Close, n) })}
+}
+
+// guard skips calc on series no longer than lookback, which talib panics
+// on, and marks the lookback bars talib pads with zeros NaN.
+func guard(lookback int, calc func(q quote.Quote) []float64) func(q quote.Quote) []float64 {
+	return func(q quote.Quote) []float64 {
+		if len(q.Close) <= lookback {
+			return nil
+		}
+		out := calc(q)
+		for i := 0; i < lookback && i < len(out); i++ {
+			out[i] = math.NaN()
+		}
+		return out
+	}
+}
+
+// Condition reports whether it holds on the last bar of q,
+// with a human readable description when it does.
+type Condition interface {
+	Check(q quote.Quote) (bool, string)
+}
+
+// CrossBelow holds when the indicator moves from at or above Level to below it.
+type CrossBelow struct {
+	Indicator Indicator
+	Level     float64
+}
+
+func (c CrossBelow) Check(q quote.Quote) (bool, string) {
+	prev, last, ok := lastTwo(c.Indicator.Calc(q))
+	if !ok || !(prev >= c.Level && last < c.Level) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("%s crossed below %g (%.2f)", c.Indicator.Name, c.Level, last)
+}
+
+// CrossAbove holds when the indicator moves from at or below Level to above it.
+type CrossAbove struct {
+	Indicator Indicator
+	Level     float64
+}
+
+func (c CrossAbove) Check(q quote.Quote) (bool, string) {
+	prev, last, ok := lastTwo(c.Indicator.Calc(q))
+	if !ok || !(prev <= c.Level && last > c.Level) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("%s crossed above %g (%.2f)", c.Indicator.Name, c.Level, last)
+}
+
+// PriceMove holds when the close has moved by at least Percent
+// (in either direction) within Window.
+type PriceMove struct {
+	Percent float64
+	Window  time.Duration
+}
+
+func (c PriceMove) Check(q quote.Quote) (bool, string) {
+	n := len(q.Close)
+	if n < 2 {
+		return false, ""
+	}
+	since := q.Date[n-1].Add(-c.Window)
+	i := n - 1
+	for i > 0 && !q.Date[i-1].Before(since) {
+		i--
+	}
+	if i == n-1 || q.Close[i] == 0 {
+		return false, ""
+	}
+	move := (q.Close[n-1] - q.Close[i]) / q.Close[i] * 100
+	if move < c.Percent && move > -c.Percent {
+		return false, ""
+	}
+	return true, fmt.Sprintf("price moved %+.2f%% in %s", move, c.Window)
+}
+
+// Rule raises an alert for Owner, a person id, when Condition holds on
+// Symbol. After firing it stays quiet for Cooldown, measured in bar time.
+type Rule struct {
+	Owner     int64
+	ID        string
+	Symbol    string
+	Condition Condition
+	Cooldown  time.Duration
+}
+
+func lastTwo(s []float64) (float64, float64, bool) {
+	if len(s) < 2 {
+		return 0, 0, false
+	}
+	prev, last := s[len(s)-2], s[len(s)-1]
+	if math.IsNaN(prev) || math.IsNaN(last) {
+		return 0, 0, false
+	}
+	return prev, last, true
+}
@@ -0,0 +1,203 @@
+/*
+backtest replays a strategy over candles and keeps the resulting trade
+ledger, from which it derives the equity curve, drawdowns, monthly
+returns and summary statistics.
+
+A Ledger is just closed trades and a starting capital, so trades from
+elsewhere, a broker statement say, can be analysed the same way.
+
+Simulate resamples the trades of a ledger many times over, to show how
+much of a single equity curve is down to the order its trades came in.
+*/
+package backtest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"golang_udemy/lesson1/dsl"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Trade is one closed round trip. Quantity is negative for a short.
+type Trade struct {
+	Symbol     string    `json:"symbol"`
+	Entry      time.Time `json:"entry"`
+	Exit       time.Time `json:"exit"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	Quantity   float64   `json:"quantity"`
+	Fees       float64   `json:"fees,omitempty"`
+}
+
+// PnL is the profit of t after fees.
+func (t Trade) PnL() float64 {
+	return (t.ExitPrice-t.EntryPrice)*t.Quantity - t.Fees
+}
+
+// Ledger is a starting capital and the trades made with it.
+type Ledger struct {
+	Capital float64 `json:"capital"`
+	Trades  []Trade `json:"trades"`
+}
+
+// Point is the equity of a ledger, and how far it is below its
+// previous peak as a negative fraction, after a trade closes.
+type Point struct {
+	Time     time.Time
+	Equity   float64
+	Drawdown float64
+}
+
+// Equity returns the equity curve: the capital at the first entry, then
+// one point per trade in order of exit.
+func (l Ledger) Equity() []Point {
+	if len(l.Trades) == 0 {
+		return nil
+	}
+	trades := append([]Trade(nil), l.Trades...)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Exit.Before(trades[j].Exit) })
+	first := trades[0].Entry
+	for _, t := range trades {
+		if t.Entry.Before(first) {
+			first = t.Entry
+		}
+	}
+	points := []Point{{Time: first, Equity: l.Capital}}
+	equity, peak := l.Capital, l.Capital
+	for _, t := range trades {
+		equity += t.PnL()
+		peak = math.Max(peak, equity)
+		p := Point{Time: t.Exit, Equity: equity}
+		if peak > 0 {
+			p.Drawdown = equity/peak - 1
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// Month is the return of a calendar month, as a fraction of the equity
+// it started with.
+type Month struct {
+	Year   int
+	Month  time.Month
+	Return float64
+}
+
+// Monthly returns the return of every month from the first entry to the
+// last exit, months without trades included.
+func (l Ledger) Monthly() []Month {
+	points := l.Equity()
+	if len(points) == 0 {
+		return nil
+	}
+	var months []Month
+	start := points[0].Equity
+	y, m, _ := points[0].Time.Date()
+	i := 1
+	for {
+		end := start
+		for ; i < len(points); i++ {
+			py, pm, _ := points[i].Time.Date()
+			if py != y || pm != m {
+				break
+			}
+			end = points[i].Equity
+		}
+		month := Month{Year: y, Month: m}
+		if start != 0 {
+			month.Return = end/start - 1
+		}
+		months = append(months, month)
+		if i == len(points) {
+			return months
+		}
+		start = end
+		if m == time.December {
+			y, m = y+1, time.January
+		} else {
+			m++
+		}
+	}
+}
+
+// Stats summarises a ledger.
+type Stats struct {
+	Trades int     `json:"trades"`
+	Wins   int     `json:"wins"`
+	Net    float64 `json:"net"`
+	// Return is Net as a fraction of the capital.
+	Return float64 `json:"return"`
+	// MaxDrawdown is the deepest drop from a peak, as a negative fraction.
+	MaxDrawdown float64 `json:"max_drawdown"`
+	// ProfitFactor is gross profit over gross loss, or +Inf without
+	// losing trades; JSON has no infinity, so it is left out then.
+	ProfitFactor float64 `json:"-"`
+	AvgWin       float64 `json:"avg_win"`
+	AvgLoss      float64 `json:"avg_loss"`
+}
+
+// Stats computes the summary statistics of l.
+func (l Ledger) Stats() Stats {
+	s := Stats{Trades: len(l.Trades)}
+	var won, lost float64
+	for _, t := range l.Trades {
+		p := t.PnL()
+		s.Net += p
+		if p > 0 {
+			s.Wins++
+			won += p
+		} else {
+			lost -= p
+		}
+	}
+	if l.Capital != 0 {
+		s.Return = s.Net / l.Capital
+	}
+	for _, p := range l.Equity() {
+		s.MaxDrawdown = math.Min(s.MaxDrawdown, p.Drawdown)
+	}
+	switch {
+	case lost > 0:
+		s.ProfitFactor = won / lost
+	case won > 0:
+		s.ProfitFactor = math.Inf(1)
+	}
+	if s.Wins > 0 {
+		s.AvgWin = won / float64(s.Wins)
+	}
+	if n := s.Trades - s.Wins; n > 0 {
+		s.AvgLoss = -lost / float64(n)
+	}
+	return s
+}
+
+// Run trades s over q, long only: it buys with all its equity at the
+// close of a bar signalling buy while flat, and sells at the close of a
+// bar signalling sell while long. A position still open on the last bar
+// is closed there. fee is charged on the value of every fill, as a
+// fraction.
+func Run(s *dsl.Strategy, q quote.Quote, capital, fee float64) Ledger {
+	l := Ledger{Capital: capital}
+	equity := capital
+	var open *Trade
+	signals := s.Signals(q)
+	for i, sig := range signals {
+		price := q.Close[i]
+		switch {
+		case sig == dsl.Buy && open == nil && price > 0 && i < len(signals)-1:
+			qty := equity / (price * (1 + fee))
+			open = &Trade{Symbol: q.Symbol, Entry: q.Date[i], EntryPrice: price, Quantity: qty, Fees: qty * price * fee}
+		case (sig == dsl.Sell || i == len(signals)-1) && open != nil:
+			open.Exit, open.ExitPrice = q.Date[i], price
+			open.Fees += open.Quantity * price * fee
+			equity += open.PnL()
+			l.Trades = append(l.Trades, *open)
+			open = nil
+		}
+	}
+	return l
+}
@@ -0,0 +1,76 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/dsl"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var ledger = Ledger{Capital: 1000, Trades: []Trade{
+	{Symbol: "A", Entry: day(1, 2), Exit: day(1, 10), EntryPrice: 10, ExitPrice: 12, Quantity: 50},
+	{Symbol: "A", Entry: day(1, 15), Exit: day(3, 5), EntryPrice: 12, ExitPrice: 9, Quantity: 100},
+	{Symbol: "A", Entry: day(3, 6), Exit: day(3, 20), EntryPrice: 9, ExitPrice: 8, Quantity: -50, Fees: 10},
+}}
+
+func TestLedger(t *testing.T) {
+	eq := ledger.Equity()
+	want := []float64{1000, 1100, 800, 840}
+	if len(eq) != len(want) {
+		t.Fatal("Unexpected equity", eq)
+	}
+	for i, w := range want {
+		if math.Abs(eq[i].Equity-w) > 1e-9 {
+			t.Error("Expected", want, "got", eq)
+			break
+		}
+	}
+	s := ledger.Stats()
+	if s.Trades != 3 || s.Wins != 2 || math.Abs(s.Net+160) > 1e-9 || math.Abs(s.MaxDrawdown-(800.0/1100-1)) > 1e-9 {
+		t.Error("Unexpected stats", s)
+	}
+	if math.Abs(s.ProfitFactor-140.0/300) > 1e-9 {
+		t.Error("Expected profit factor 140/300, got", s.ProfitFactor)
+	}
+
+	months := ledger.Monthly()
+	if len(months) != 3 || months[1].Month != time.February || months[1].Return != 0 {
+		t.Fatal("Expected January to March with a flat February, got", months)
+	}
+	if math.Abs(months[0].Return-0.1) > 1e-9 || math.Abs(months[2].Return-(840.0/1100-1)) > 1e-9 {
+		t.Error("Unexpected monthly returns", months)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, err := dsl.Compile(`
+		buy when crossover(close, sma(close, 3))
+		sell when crossunder(close, sma(close, 3))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closes := []float64{10, 9, 8, 7, 9, 10, 11, 8, 7, 9, 12}
+	q := quote.NewQuote("BTC-USD", len(closes))
+	for i, c := range closes {
+		q.Date[i] = day(1, i+1)
+		q.Close[i] = c
+	}
+	l := Run(s, q, 900, 0)
+	if len(l.Trades) != 2 {
+		t.Fatal("Expected two trades, got", l.Trades)
+	}
+	// in at 9, out at 8; in again at 9 and closed at 12 on the last bar
+	if tr := l.Trades[0]; tr.EntryPrice != 9 || tr.ExitPrice != 8 || tr.Quantity != 100 {
+		t.Error("Unexpected first trade", tr)
+	}
+	if tr := l.Trades[1]; tr.EntryPrice != 9 || tr.ExitPrice != 12 || !tr.Exit.Equal(day(1, 11)) {
+		t.Error("Unexpected last trade", tr)
+	}
+}
@@ -0,0 +1,165 @@
+package backtest
+
+import (
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Resampling is how a Monte Carlo run reorders the trades of a ledger.
+type Resampling string
+
+const (
+	// Shuffle takes every trade once, in random order. It is also what
+	// an empty or unknown Resampling does.
+	Shuffle Resampling = "shuffle"
+	// Bootstrap draws as many trades as the ledger has, with
+	// replacement.
+	Bootstrap Resampling = "bootstrap"
+	// Skip keeps the order but leaves each trade out with probability
+	// SkipRate, as missed fills would.
+	Skip Resampling = "skip"
+)
+
+// MonteCarlo configures Simulate. The same Seed gives the same result
+// whatever the number of Workers.
+type MonteCarlo struct {
+	Method   Resampling
+	Runs     int
+	Seed     uint64
+	SkipRate float64
+	// Confidence is the share of runs an interval covers, 0.9 when zero.
+	Confidence float64
+	// Workers is how many goroutines share the runs, GOMAXPROCS when zero.
+	Workers int
+}
+
+// Interval is a confidence interval and the median of a statistic
+// over the runs of a simulation.
+type Interval struct {
+	Lo     float64 `json:"lo"`
+	Median float64 `json:"median"`
+	Hi     float64 `json:"hi"`
+}
+
+// Simulation is the outcome of Simulate.
+type Simulation struct {
+	Method      Resampling `json:"method"`
+	Runs        int        `json:"runs"`
+	Confidence  float64    `json:"confidence"`
+	FinalEquity Interval   `json:"final_equity"`
+	// MaxDrawdown is the deepest drop from a peak, a negative fraction.
+	MaxDrawdown Interval `json:"max_drawdown"`
+	// Recovery is the longest run of trades spent below an earlier
+	// peak, counting one never recovered from up to the last trade.
+	Recovery Interval `json:"recovery"`
+}
+
+// Simulate replays the profits of l's trades in mc.Runs resampled
+// orders, each from l.Capital, and reports how final equity, maximum
+// drawdown and time to recovery spread over the runs. Trades keep their
+// profit in money rather than as a return, as a fixed size strategy
+// would.
+func (l Ledger) Simulate(mc MonteCarlo) Simulation {
+	if mc.Confidence <= 0 || mc.Confidence >= 1 {
+		mc.Confidence = 0.9
+	}
+	if mc.Workers <= 0 {
+		mc.Workers = runtime.GOMAXPROCS(0)
+	}
+	sim := Simulation{Method: mc.Method, Runs: mc.Runs, Confidence: mc.Confidence}
+	if mc.Runs <= 0 || len(l.Trades) == 0 {
+		return sim
+	}
+	pnl := make([]float64, len(l.Trades))
+	for i, t := range l.Trades {
+		pnl[i] = t.PnL()
+	}
+	final := make([]float64, mc.Runs)
+	drawdown := make([]float64, mc.Runs)
+	recovery := make([]float64, mc.Runs)
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < mc.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq := make([]float64, 0, len(pnl))
+			for i := range runs {
+				rng := rand.New(rand.NewPCG(mc.Seed, uint64(i)))
+				seq = mc.resample(seq[:0], pnl, rng)
+				final[i], drawdown[i], recovery[i] = walk(l.Capital, seq)
+			}
+		}()
+	}
+	for i := 0; i < mc.Runs; i++ {
+		runs <- i
+	}
+	close(runs)
+	wg.Wait()
+
+	sim.FinalEquity = interval(final, mc.Confidence)
+	sim.MaxDrawdown = interval(drawdown, mc.Confidence)
+	sim.Recovery = interval(recovery, mc.Confidence)
+	return sim
+}
+
+// resample appends one resampled sequence of pnl to seq.
+func (mc MonteCarlo) resample(seq, pnl []float64, rng *rand.Rand) []float64 {
+	switch mc.Method {
+	case Bootstrap:
+		for range pnl {
+			seq = append(seq, pnl[rng.IntN(len(pnl))])
+		}
+	case Skip:
+		for _, p := range pnl {
+			if rng.Float64() >= mc.SkipRate {
+				seq = append(seq, p)
+			}
+		}
+	default:
+		seq = append(seq, pnl...)
+		rng.Shuffle(len(seq), func(i, j int) { seq[i], seq[j] = seq[j], seq[i] })
+	}
+	return seq
+}
+
+// walk books pnl in order from capital and returns the final equity,
+// the maximum drawdown and the longest stretch of trades under water.
+func walk(capital float64, pnl []float64) (final, drawdown, recovery float64) {
+	equity, peak := capital, capital
+	under, longest := 0, 0
+	for _, p := range pnl {
+		equity += p
+		if equity >= peak {
+			peak, under = equity, 0
+			continue
+		}
+		under++
+		longest = max(longest, under)
+		if peak > 0 {
+			drawdown = math.Min(drawdown, equity/peak-1)
+		}
+	}
+	return equity, drawdown, float64(longest)
+}
+
+// interval sorts v and returns its median and the central share
+// confidence of it.
+func interval(v []float64, confidence float64) Interval {
+	sort.Float64s(v)
+	tail := (1 - confidence) / 2
+	return Interval{quantile(v, tail), quantile(v, 0.5), quantile(v, 1-tail)}
+}
+
+// quantile interpolates linearly between the closest ranks of sorted.
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
@@ -0,0 +1,35 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	mc := MonteCarlo{Method: Shuffle, Runs: 500, Seed: 7, Workers: 1}
+	sim := ledger.Simulate(mc)
+	if f := sim.FinalEquity; math.Abs(f.Lo-840) > 1e-9 || math.Abs(f.Hi-840) > 1e-9 {
+		t.Error("Expected every shuffle to end at 840, got", f)
+	}
+	if d := sim.MaxDrawdown; d.Lo > d.Median || d.Median > d.Hi || d.Hi > 0 {
+		t.Error("Unexpected drawdown interval", d)
+	}
+	mc.Workers = 8
+	if again := ledger.Simulate(mc); again != sim {
+		t.Error("Expected the same seed to give the same result, got", sim, again)
+	}
+
+	boot := ledger.Simulate(MonteCarlo{Method: Bootstrap, Runs: 500, Seed: 7, Confidence: 0.95})
+	if boot.FinalEquity.Lo >= 840 || boot.FinalEquity.Hi <= 840 {
+		t.Error("Expected bootstrapping to spread final equity around 840, got", boot.FinalEquity)
+	}
+
+	none := ledger.Simulate(MonteCarlo{Method: Skip, Runs: 10, SkipRate: 0})
+	if none.FinalEquity.Median != 840 || none.Recovery.Median != 2 {
+		t.Error("Expected skipping nothing to replay the ledger, got", none)
+	}
+	all := ledger.Simulate(MonteCarlo{Method: Skip, Runs: 10, SkipRate: 1})
+	if all.FinalEquity.Median != 1000 || all.MaxDrawdown.Median != 0 {
+		t.Error("Expected skipping everything to keep the capital, got", all)
+	}
+}
@@ -0,0 +1,152 @@
+package calendar
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Duration returns the bar length of an intraday go-quote period.
+func Duration(p quote.Period) (time.Duration, error) {
+	switch p {
+	case quote.Min1:
+		return time.Minute, nil
+	case quote.Min3:
+		return 3 * time.Minute, nil
+	case quote.Min5:
+		return 5 * time.Minute, nil
+	case quote.Min15:
+		return 15 * time.Minute, nil
+	case quote.Min30:
+		return 30 * time.Minute, nil
+	case quote.Min60:
+		return time.Hour, nil
+	case quote.Hour2:
+		return 2 * time.Hour, nil
+	case quote.Hour4:
+		return 4 * time.Hour, nil
+	case quote.Hour6:
+		return 6 * time.Hour, nil
+	case quote.Hour8:
+		return 8 * time.Hour, nil
+	case quote.Hour12:
+		return 12 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("calendar: %q is not an intraday period", p)
+}
+
+// ExpectedBars counts the bars of period that start in [from, to).
+// Intraday bars start at each session open and every period after it
+// until the close; daily and longer bars count one per session, week
+// or month that has trading.
+func (c *Calendar) ExpectedBars(from, to time.Time, period quote.Period) int {
+	sessions := c.Sessions(from, to)
+	switch period {
+	case quote.Daily, quote.Day3:
+		n := 0
+		for _, s := range sessions {
+			if !s[0].Before(from) {
+				n++
+			}
+		}
+		if period == quote.Day3 {
+			return int(math.Ceil(float64(n) / 3))
+		}
+		return n
+	case quote.Weekly, quote.Monthly:
+		seen := map[string]bool{}
+		for _, s := range sessions {
+			if s[0].Before(from) {
+				continue
+			}
+			key := s[0].Format("2006-01")
+			if period == quote.Weekly {
+				y, w := s[0].ISOWeek()
+				key = fmt.Sprint(y, w)
+			}
+			seen[key] = true
+		}
+		return len(seen)
+	}
+
+	d, err := Duration(period)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, s := range sessions {
+		first, last := s[0], s[1]
+		if from.After(first) {
+			// round up to the next bar boundary
+			k := (from.Sub(first) + d - 1) / d
+			first = first.Add(k * d)
+		}
+		if to.Before(last) {
+			last = to
+		}
+		if first.Before(last) {
+			n += int((last.Sub(first) + d - 1) / d)
+		}
+	}
+	return n
+}
+
+// Gap is a run of bars missing between two bars of a series.
+type Gap struct {
+	After   time.Time
+	Before  time.Time
+	Missing int
+}
+
+// Gaps returns where q lacks bars the calendar says should be there. It
+// returns ErrNoData if q runs past the calendar's data.
+func (c *Calendar) Gaps(q quote.Quote, period quote.Period) ([]Gap, error) {
+	if n := len(q.Date); n > 0 {
+		if err := c.Covers(q.Date[n-1]); err != nil {
+			return nil, err
+		}
+	}
+	var gaps []Gap
+	for i := 0; i+1 < len(q.Date); i++ {
+		// the range includes bar i itself, hence the -1
+		if n := c.ExpectedBars(q.Date[i], q.Date[i+1], period) - 1; n > 0 {
+			gaps = append(gaps, Gap{q.Date[i], q.Date[i+1], n})
+		}
+	}
+	return gaps, nil
+}
+
+// Positions places times, which must be in order, on an axis that only
+// advances while the market trades: each is the number of sessions since
+// the first one's, plus the fraction of its own session gone by. A time
+// outside a session sits at the start of the next one. It returns
+// ErrNoData if the times run past the calendar's data.
+func (c *Calendar) Positions(times []time.Time) ([]float64, error) {
+	if len(times) == 0 {
+		return nil, nil
+	}
+	last := times[len(times)-1]
+	if err := c.Covers(last); err != nil {
+		return nil, err
+	}
+	// a week on, so a time after the last session still finds the next
+	sessions := c.Sessions(times[0], last.AddDate(0, 0, 7))
+	out := make([]float64, len(times))
+	k := 0
+	for i, t := range times {
+		for k < len(sessions)-1 && !sessions[k][1].After(t) {
+			k++
+		}
+		frac := 0.0
+		if k < len(sessions) {
+			open, closing := sessions[k][0], sessions[k][1]
+			if t.After(open) {
+				frac = math.Min(float64(t.Sub(open))/float64(closing.Sub(open)), 1)
+			}
+		}
+		out[i] = float64(k) + frac
+	}
+	return out, nil
+}
@@ -0,0 +1,180 @@
+/*
+calendar knows when markets trade: weekly sessions in a time zone,
+holidays and early closes, or around the clock for crypto.
+
+Holidays are only known for the years a calendar's data lists, up to
+Through. Past that every weekday looks like a session, so Covers, Gaps
+and Positions refuse such dates rather than answer wrongly.
+*/
+package calendar
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+//go:embed data/*.cal
+var data embed.FS
+
+// ErrNoData is returned for dates past the holidays a calendar knows.
+var ErrNoData = errors.New("calendar: no holiday data")
+
+// Clock is a time of day.
+type Clock struct {
+	Hour, Minute int
+}
+
+func (c Clock) on(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), c.Hour, c.Minute, 0, 0, day.Location())
+}
+
+// Calendar describes the trading sessions of a market.
+type Calendar struct {
+	Name       string
+	Location   *time.Location
+	AlwaysOpen bool
+	Weekdays   [7]bool
+	Open       Clock
+	Close      Clock
+	Holidays   map[string]string
+	EarlyClose map[string]Clock
+	// Through is the last local date the holidays are known for, as
+	// 2006-01-02; empty if they always are.
+	Through string
+}
+
+// Crypto returns the calendar of a market that never closes.
+func Crypto() *Calendar {
+	return &Calendar{Name: "crypto", Location: time.UTC, AlwaysOpen: true}
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]*Calendar{"crypto": Crypto()}
+)
+
+// Get returns a named calendar: "crypto" or one of the embedded data files.
+func Get(name string) (*Calendar, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok := registry[name]; ok {
+		return c, nil
+	}
+	f, err := data.Open(path.Join("data", name+".cal"))
+	if err != nil {
+		return nil, fmt.Errorf("calendar: unknown calendar %q", name)
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	registry[name] = c
+	return c, nil
+}
+
+// For returns the calendar symbol trades on: crypto for pairs such as
+// BTC-USD, nyse for anything else.
+func For(symbol string) (*Calendar, error) {
+	if strings.Contains(symbol, "-") {
+		return Get("crypto")
+	}
+	return Get("nyse")
+}
+
+// Register makes c available to Get under its name.
+func Register(c *Calendar) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[c.Name] = c
+}
+
+// Session returns the open and close of the session on the local date
+// of day, or false if the market is shut that day.
+func (c *Calendar) Session(day time.Time) (time.Time, time.Time, bool) {
+	day = day.In(c.Location)
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, c.Location)
+	if c.AlwaysOpen {
+		return midnight, midnight.AddDate(0, 0, 1), true
+	}
+	key := day.Format("2006-01-02")
+	if !c.Weekdays[day.Weekday()] {
+		return time.Time{}, time.Time{}, false
+	}
+	if _, ok := c.Holidays[key]; ok {
+		return time.Time{}, time.Time{}, false
+	}
+	closing := c.Close
+	if early, ok := c.EarlyClose[key]; ok {
+		closing = early
+	}
+	return c.Open.on(midnight), closing.on(midnight), true
+}
+
+// Covers returns ErrNoData if the local date of t is past Through.
+func (c *Calendar) Covers(t time.Time) error {
+	if day := t.In(c.Location).Format("2006-01-02"); c.Through != "" && day > c.Through {
+		return fmt.Errorf("%w: %s is past %s, the last day of calendar %s", ErrNoData, day, c.Through, c.Name)
+	}
+	return nil
+}
+
+// IsOpen reports whether the market is trading at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	open, closing, ok := c.Session(t)
+	return ok && !t.Before(open) && t.Before(closing)
+}
+
+// NextOpen returns t if the market is open at t, otherwise the start
+// of the next session. It gives up after a year of closed days.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if c.IsOpen(t) {
+		return t
+	}
+	day := t.In(c.Location)
+	for i := 0; i <= 366; i++ {
+		if open, _, ok := c.Session(day); ok && !open.Before(t) {
+			return open
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// Sessions returns the open/close pairs of every session overlapping [from, to).
+func (c *Calendar) Sessions(from, to time.Time) [][2]time.Time {
+	var out [][2]time.Time
+	local := from.In(c.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		open, closing, ok := c.Session(day)
+		if ok && closing.After(from) && open.Before(to) {
+			out = append(out, [2]time.Time{open, closing})
+		}
+	}
+	return out
+}
+
+// Holiday returns the name of the holiday on the local date of day, if any.
+func (c *Calendar) Holiday(day time.Time) (string, bool) {
+	name, ok := c.Holidays[day.In(c.Location).Format("2006-01-02")]
+	return name, ok
+}
+
+// Names returns the calendars Get knows about.
+func Names() []string {
+	names := []string{"crypto"}
+	entries, _ := data.ReadDir("data")
+	for _, e := range entries {
+		names = append(names, e.Name()[:len(e.Name())-len(".cal")])
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,117 @@
+package calendar
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func TestNYSE(t *testing.T) {
+	c, err := Get("nyse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny := c.Location
+	if !c.IsOpen(time.Date(2024, 7, 3, 12, 59, 0, 0, ny)) || c.IsOpen(time.Date(2024, 7, 3, 13, 0, 0, 0, ny)) {
+		t.Error("Expected early close at 13:00 on 2024-07-03")
+	}
+	if c.IsOpen(time.Date(2024, 7, 4, 10, 0, 0, 0, ny)) {
+		t.Error("Expected closed on Independence Day")
+	}
+	// Friday evening before a weekend and a Monday holiday (MLK day)
+	got := c.NextOpen(time.Date(2024, 1, 12, 17, 0, 0, 0, ny))
+	if want := time.Date(2024, 1, 16, 9, 30, 0, 0, ny); !got.Equal(want) {
+		t.Error("Expected", want, "got", got)
+	}
+
+	week := [2]time.Time{time.Date(2024, 11, 25, 0, 0, 0, 0, ny), time.Date(2024, 12, 2, 0, 0, 0, 0, ny)}
+	// Thanksgiving week: 3 full days, a holiday and a half day
+	if n := c.ExpectedBars(week[0], week[1], quote.Daily); n != 4 {
+		t.Error("Expected 4 daily bars, got", n)
+	}
+	if n := c.ExpectedBars(week[0], week[1], quote.Min60); n != 3*7+4 {
+		t.Error("Expected 25 hourly bars, got", n)
+	}
+}
+
+func TestCryptoGaps(t *testing.T) {
+	c, _ := Get("crypto")
+	q := quote.NewQuote("BTC-USD", 3)
+	q.Date[0] = time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)
+	q.Date[1] = time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	q.Date[2] = time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	gaps, err := c.Gaps(q, quote.Daily)
+	if err != nil || len(gaps) != 1 || gaps[0].Missing != 2 {
+		t.Error("Expected one gap of 2 bars, got", gaps, err)
+	}
+	if n := c.ExpectedBars(q.Date[0], q.Date[1], quote.Min15); n != 96 {
+		t.Error("Expected 96 bars in a day, got", n)
+	}
+}
+
+func TestPastData(t *testing.T) {
+	c, err := Get("nyse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny := c.Location
+	if c.IsOpen(time.Date(2027, 7, 5, 10, 0, 0, 0, ny)) || !c.IsOpen(time.Date(2027, 11, 26, 12, 0, 0, 0, ny)) {
+		t.Error("Expected the 2027 holidays and early closes")
+	}
+	if err := c.Covers(time.Date(2027, 12, 31, 23, 0, 0, 0, ny)); err != nil {
+		t.Error("Expected 2027 to be covered, got", err)
+	}
+	// midnight UTC is still 2027 in New York
+	if err := c.Covers(time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Error("Expected the local date to count, got", err)
+	}
+	q := quote.NewQuote("AAPL", 2)
+	q.Date[0] = time.Date(2027, 12, 30, 0, 0, 0, 0, ny)
+	q.Date[1] = time.Date(2028, 1, 3, 0, 0, 0, 0, ny)
+	if _, err := c.Gaps(q, quote.Daily); !errors.Is(err, ErrNoData) {
+		t.Error("Expected gaps past the data to be refused, got", err)
+	}
+	if _, err := c.Positions(q.Date); !errors.Is(err, ErrNoData) {
+		t.Error("Expected positions past the data to be refused, got", err)
+	}
+	if err := Crypto().Covers(q.Date[1]); err != nil {
+		t.Error("Expected crypto to cover every date, got", err)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	if c, _ := For("BTC-USD"); c == nil || !c.AlwaysOpen {
+		t.Error("Expected pairs to trade around the clock, got", c)
+	}
+	c, _ := For("AAPL")
+	ny := c.Location
+	times := []time.Time{
+		time.Date(2024, 7, 2, 9, 30, 0, 0, ny),
+		time.Date(2024, 7, 2, 12, 45, 0, 0, ny),
+		time.Date(2024, 7, 3, 11, 15, 0, 0, ny), // half day to 13:00
+		time.Date(2024, 7, 5, 9, 30, 0, 0, ny),  // after the holiday
+		time.Date(2024, 7, 5, 18, 0, 0, 0, ny),  // after the close
+	}
+	got, err := c.Positions(times)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0, 0.5, 1.5, 2, 3}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Error("Expected", want, "got", got)
+			break
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("name x\nsession 09:30 25:00\n"))
+	if err == nil || err.Error() != `calendar: line 2: bad time "25:00"` {
+		t.Error("Unexpected error", err)
+	}
+}
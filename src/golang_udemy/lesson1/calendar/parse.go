@@ -0,0 +1,103 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse reads a calendar definition, one directive per line:
+//
+//	name nyse
+//	timezone America/New_York
+//	weekdays mon tue wed thu fri
+//	session 09:30 16:00
+//	holiday 2024-12-25 Christmas Day
+//	halfday 2024-12-24 13:00
+//	through 2024-12-31
+//
+// through is the last date the holidays listed cover. Blank lines and lines starting with # are ignored.
+func Parse(r io.Reader) (*Calendar, error) {
+	c := &Calendar{Location: time.UTC, Holidays: map[string]string{}, EarlyClose: map[string]Clock{}}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if err := c.directive(f); err != nil {
+			return nil, fmt.Errorf("calendar: line %d: %v", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if c.Name == "" {
+		return nil, fmt.Errorf("calendar: missing name")
+	}
+	return c, nil
+}
+
+// ParseFile reads a calendar definition from filename.
+func ParseFile(filename string) (*Calendar, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func (c *Calendar) directive(f []string) error {
+	var err error
+	switch {
+	case f[0] == "name" && len(f) == 2:
+		c.Name = f[1]
+	case f[0] == "timezone" && len(f) == 2:
+		c.Location, err = time.LoadLocation(f[1])
+	case f[0] == "weekdays":
+		for _, d := range f[1:] {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return fmt.Errorf("bad weekday %q", d)
+			}
+			c.Weekdays[wd] = true
+		}
+	case f[0] == "session" && len(f) == 3:
+		if c.Open, err = parseClock(f[1]); err == nil {
+			c.Close, err = parseClock(f[2])
+		}
+	case f[0] == "holiday" && len(f) >= 2:
+		if _, err = time.Parse("2006-01-02", f[1]); err == nil {
+			c.Holidays[f[1]] = strings.Join(f[2:], " ")
+		}
+	case f[0] == "halfday" && len(f) == 3:
+		if _, err = time.Parse("2006-01-02", f[1]); err == nil {
+			c.EarlyClose[f[1]], err = parseClock(f[2])
+		}
+	case f[0] == "through" && len(f) == 2:
+		if _, err = time.Parse("2006-01-02", f[1]); err == nil {
+			c.Through = f[1]
+		}
+	default:
+		return fmt.Errorf("bad directive %q", strings.Join(f, " "))
+	}
+	return err
+}
+
+func parseClock(s string) (Clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return Clock{}, fmt.Errorf("bad time %q", s)
+	}
+	return Clock{t.Hour(), t.Minute()}, nil
+}
@@ -0,0 +1,330 @@
+/*
+chart renders candle series and their indicators to SVG.
+
+Given a calendar, bars are laid out by trading session, so nights,
+weekends and holidays leave no empty space on the time axis while bars
+missing from a session do. Without one they are laid out by index.
+*/
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/calendar"
+
+	quote "github.com/markcheno/go-quote"
+	talib "github.com/markcheno/go-talib"
+)
+
+// Options controls the size and content of a chart.
+type Options struct {
+	Width, Height int
+	// Indicators are specs such as "sma:20", "ema:50", "bbands:20",
+	// "rsi:14" or "macd". Moving averages and bands overlay the
+	// candles; rsi and macd get their own panes.
+	Indicators []string
+	// Volume adds volume bars under the candles.
+	Volume bool
+	// Calendar, if set, lays the bars out by its sessions.
+	Calendar *calendar.Calendar
+}
+
+const (
+	margin     = 50.0
+	paneHeight = 100.0
+	paneGap    = 20.0
+)
+
+var palette = []string{"#1e88e5", "#fb8c00", "#8e24aa", "#43a047", "#6d4c41"}
+
+// line is an indicator series; values before start are warm-up.
+type line struct {
+	name   string
+	values []float64
+	start  int
+	color  string
+}
+
+type subpane struct {
+	lines    []line
+	hist     []float64
+	min, max float64
+	guides   []float64
+}
+
+type pane struct {
+	top, height, min, max float64
+}
+
+func (p pane) y(v float64) float64 {
+	if p.max == p.min {
+		return p.top + p.height/2
+	}
+	return p.top + (p.max-v)/(p.max-p.min)*p.height
+}
+
+// Render writes an SVG chart of q to w.
+func Render(w io.Writer, q quote.Quote, o Options) error {
+	if o.Width == 0 {
+		o.Width = 900
+	}
+	if o.Height == 0 {
+		o.Height = 500
+	}
+	var overlays []line
+	var subs []subpane
+	for i, spec := range o.Indicators {
+		color := palette[i%len(palette)]
+		l, s, err := indicator(q, spec, color)
+		if err != nil {
+			return err
+		}
+		overlays = append(overlays, l...)
+		if s != nil {
+			subs = append(subs, *s)
+		}
+	}
+
+	n := len(q.Close)
+	width := float64(o.Width)
+	plotW := width - 2*margin
+	mainH := float64(o.Height) - 2*margin - float64(len(subs))*(paneHeight+paneGap)
+	if mainH < paneHeight {
+		mainH = paneHeight
+	}
+	height := 2*margin + mainH + float64(len(subs))*(paneHeight+paneGap)
+	pos, unit, err := positions(q, o.Calendar)
+	if err != nil {
+		return err
+	}
+	scale := plotW / math.Max(pos[len(pos)-1]-pos[0]+unit, unit)
+	x := func(i int) float64 { return margin + (pos[i]-pos[0]+unit/2)*scale }
+	step := unit * scale
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%.0f" viewBox="0 0 %d %.0f" font-family="sans-serif" font-size="10">`+"\n",
+		o.Width, height, o.Width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="14">%s</text>`+"\n", margin, margin-20, html.EscapeString(q.Symbol))
+
+	main := pane{top: margin, height: mainH}
+	volH := 0.0
+	if o.Volume {
+		volH = mainH * 0.2
+		main.height -= volH
+	}
+	main.min, main.max = bounds(q, overlays)
+	grid(&b, main, width)
+
+	if o.Volume {
+		vol := pane{top: main.top + main.height, height: volH, max: maxOf(q.Volume)}
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s" opacity="0.4"/>`+"\n",
+				x(i)-step*0.35, vol.y(q.Volume[i]), step*0.7, vol.top+vol.height-vol.y(q.Volume[i]), candleColor(q, i))
+		}
+	}
+	for i := 0; i < n; i++ {
+		c := candleColor(q, i)
+		fmt.Fprintf(&b, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s"/>`+"\n",
+			x(i), main.y(q.High[i]), x(i), main.y(q.Low[i]), c)
+		top, bottom := main.y(math.Max(q.Open[i], q.Close[i])), main.y(math.Min(q.Open[i], q.Close[i]))
+		fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`+"\n",
+			x(i)-step*0.35, top, step*0.7, math.Max(bottom-top, 0.5), c)
+	}
+	for k, l := range overlays {
+		polyline(&b, main, l, x)
+		legend(&b, l, margin+float64(k)*110, main.top-5)
+	}
+
+	top := main.top + main.height + volH
+	for _, s := range subs {
+		top += paneGap
+		p := pane{top: top, height: paneHeight, min: s.min, max: s.max}
+		grid(&b, p, width)
+		for _, g := range s.guides {
+			fmt.Fprintf(&b, `<line x1="%.0f" y1="%.2f" x2="%.0f" y2="%.2f" stroke="#999" stroke-dasharray="4 3"/>`+"\n",
+				margin, p.y(g), width-margin, p.y(g))
+		}
+		for i, v := range s.hist {
+			if i < s.lines[0].start {
+				continue
+			}
+			y0, y1 := p.y(0), p.y(v)
+			fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="#90a4ae"/>`+"\n",
+				x(i)-step*0.35, math.Min(y0, y1), step*0.7, math.Abs(y1-y0))
+		}
+		for k, l := range s.lines {
+			polyline(&b, p, l, x)
+			legend(&b, l, margin+float64(k)*110, p.top+12)
+		}
+		top += paneHeight
+	}
+	timeAxis(&b, q, x, top)
+	b.WriteString("</svg>\n")
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// indicator computes the overlay lines or the sub-pane of spec.
+func indicator(q quote.Quote, spec, color string) ([]line, *subpane, error) {
+	name, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	n, err := strconv.Atoi(arg)
+	if arg == "" {
+		n, err = 20, nil
+	}
+	if err != nil || n < 1 {
+		return nil, nil, fmt.Errorf("chart: bad indicator %q", spec)
+	}
+	if name == "rsi" && arg == "" {
+		n = 14
+	}
+	label := fmt.Sprintf("%s(%d)", strings.ToUpper(name), n)
+	lookback := map[string]int{"sma": n - 1, "ema": n - 1, "bbands": n - 1, "rsi": n, "macd": 33}[name]
+	// talib panics on series shorter than its lookback; chart them without indicators
+	short := len(q.Close) <= lookback
+	empty := make([]float64, len(q.Close))
+	switch name {
+	case "sma", "ema", "bbands":
+		if short {
+			return nil, nil, nil
+		}
+		if name == "sma" {
+			return []line{{label, talib.Sma(q.Close, n), lookback, color}}, nil, nil
+		}
+		if name == "ema" {
+			return []line{{label, talib.Ema(q.Close, n), lookback, color}}, nil, nil
+		}
+		upper, middle, lower := talib.BBands(q.Close, n, 2, 2, talib.SMA)
+		return []line{{label + " upper", upper, lookback, color}, {label, middle, lookback, color}, {label + " lower", lower, lookback, color}}, nil, nil
+	case "rsi":
+		rsi := empty
+		if !short {
+			rsi = talib.Rsi(q.Close, n)
+		}
+		return nil, &subpane{lines: []line{{label, rsi, lookback, color}},
+			min: 0, max: 100, guides: []float64{30, 70}}, nil
+	case "macd":
+		macd, signal, hist := empty, empty, empty
+		if !short {
+			macd, signal, hist = talib.Macd(q.Close, 12, 26, 9)
+		}
+		s := &subpane{hist: hist, guides: []float64{0},
+			lines: []line{{"MACD", macd, lookback, color}, {"signal", signal, lookback, "#e53935"}}}
+		for i := lookback; i < len(macd); i++ {
+			s.min = math.Min(s.min, math.Min(macd[i], math.Min(signal[i], hist[i])))
+			s.max = math.Max(s.max, math.Max(macd[i], math.Max(signal[i], hist[i])))
+		}
+		return nil, s, nil
+	}
+	return nil, nil, fmt.Errorf("chart: unknown indicator %q", spec)
+}
+
+func bounds(q quote.Quote, overlays []line) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range q.Close {
+		lo, hi = math.Min(lo, q.Low[i]), math.Max(hi, q.High[i])
+	}
+	for _, l := range overlays {
+		for i := l.start; i < len(l.values); i++ {
+			lo, hi = math.Min(lo, l.values[i]), math.Max(hi, l.values[i])
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return 0, 1
+	}
+	pad := (hi - lo) * 0.05
+	return lo - pad, hi + pad
+}
+
+func grid(b *bytes.Buffer, p pane, width float64) {
+	for k := 0; k <= 4; k++ {
+		v := p.min + (p.max-p.min)*float64(k)/4
+		y := p.y(v)
+		fmt.Fprintf(b, `<line x1="%.0f" y1="%.2f" x2="%.0f" y2="%.2f" stroke="#eee"/>`+"\n", margin, y, width-margin, y)
+		fmt.Fprintf(b, `<text x="%.0f" y="%.2f">%s</text>`+"\n", width-margin+4, y+3, strconv.FormatFloat(v, 'f', 2, 64))
+	}
+}
+
+func polyline(b *bytes.Buffer, p pane, l line, x func(int) float64) {
+	var pts []string
+	for i := l.start; i < len(l.values); i++ {
+		pts = append(pts, fmt.Sprintf("%.2f,%.2f", x(i), p.y(l.values[i])))
+	}
+	if len(pts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, `<polyline fill="none" stroke="%s" points="%s"/>`+"\n", l.color, strings.Join(pts, " "))
+}
+
+func legend(b *bytes.Buffer, l line, x, y float64) {
+	fmt.Fprintf(b, `<text x="%.0f" y="%.0f" fill="%s">%s</text>`+"\n", x, y, l.color, html.EscapeString(l.name))
+}
+
+// positions places the bars of q along the time axis, by index or by
+// the sessions of c, and returns the spacing of the closest two bars.
+func positions(q quote.Quote, c *calendar.Calendar) ([]float64, float64, error) {
+	pos := make([]float64, len(q.Date))
+	for i := range pos {
+		pos[i] = float64(i)
+	}
+	if c != nil && len(pos) > 0 {
+		var err error
+		if pos, err = c.Positions(q.Date); err != nil {
+			return nil, 0, err
+		}
+	}
+	unit := math.Inf(1)
+	for i := 1; i < len(pos); i++ {
+		if d := pos[i] - pos[i-1]; d > 0 && d < unit {
+			unit = d
+		}
+	}
+	if math.IsInf(unit, 1) {
+		unit = 1
+	}
+	if len(pos) == 0 {
+		pos = []float64{0}
+	}
+	return pos, unit, nil
+}
+
+// timeAxis labels about eight evenly spaced bars with their dates.
+func timeAxis(b *bytes.Buffer, q quote.Quote, x func(int) float64, y float64) {
+	n := len(q.Date)
+	if n == 0 {
+		return
+	}
+	layout := "2006-01-02"
+	for i := 1; i < n; i++ {
+		if q.Date[i].Sub(q.Date[i-1]) < 24*time.Hour {
+			layout = "01-02 15:04"
+			break
+		}
+	}
+	every := int(math.Max(1, math.Ceil(float64(n)/8)))
+	for i := 0; i < n; i += every {
+		fmt.Fprintf(b, `<text x="%.2f" y="%.0f" text-anchor="middle">%s</text>`+"\n", x(i), y+15, q.Date[i].Format(layout))
+	}
+}
+
+func candleColor(q quote.Quote, i int) string {
+	if q.Close[i] >= q.Open[i] {
+		return "#26a69a"
+	}
+	return "#ef5350"
+}
+
+func maxOf(s []float64) float64 {
+	m := 0.0
+	for _, v := range s {
+		m = math.Max(m, v)
+	}
+	return m
+}
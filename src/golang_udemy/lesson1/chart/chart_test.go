@@ -0,0 +1,134 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/calendar"
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func candles(n int) quote.Quote {
+	q := quote.NewQuote("A&B", n)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		// skip weekends, as an equity series would
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		c := 100 + 10*math.Sin(float64(i)/5)
+		q.Date[i] = day
+		q.Open[i], q.Close[i] = c-1, c
+		q.High[i], q.Low[i] = c+2, c-3
+		q.Volume[i] = float64(1000 + i)
+		day = day.AddDate(0, 0, 1)
+	}
+	return q
+}
+
+// wellFormed reports whether s parses as XML.
+func wellFormed(s string) error {
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	var b bytes.Buffer
+	err := Render(&b, candles(60), Options{Indicators: []string{"sma:10", "bbands", "rsi", "macd"}, Volume: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+	if err := wellFormed(svg); err != nil {
+		t.Fatal("Expected well-formed SVG, got", err)
+	}
+	// bar 8 is 2024-01-11 because weekends take no space
+	for _, want := range []string{"A&amp;B", "SMA(10)", "BBANDS(20) upper", "RSI(14)", "MACD", ">2024-01-01<", ">2024-01-11<"} {
+		if !strings.Contains(svg, want) {
+			t.Error("Expected SVG to contain", want)
+		}
+	}
+
+	// indicators longer than the series are left out rather than panicking
+	b.Reset()
+	if err := Render(&b, candles(5), Options{Indicators: []string{"sma:20", "macd"}}); err != nil {
+		t.Error(err)
+	}
+	if err := Render(&b, candles(5), Options{Indicators: []string{"wma:3"}}); err == nil {
+		t.Error("Expected unknown indicator error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	p := provider.Func{ProviderName: "fake", Get: func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		if symbol != "AAPL" {
+			return quote.NewQuote("", 0), nil
+		}
+		return candles(30), nil
+	}}
+	h := NewHandler(p)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/chart.svg?symbol=AAPL&indicators=ema:5,rsi:7", nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(w.Body.String(), "EMA(5)") {
+		t.Error("Unexpected response", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/chart.svg?symbol=NOPE", nil))
+	if w.Code != 404 {
+		t.Error("Expected 404, got", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/chart.svg?symbol=AAPL&calendar=lse", nil))
+	if w.Code != 400 {
+		t.Error("Expected an unknown calendar to be refused, got", w.Code)
+	}
+	// past the calendar's data the chart falls back to laying bars out by index
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/chart.svg?symbol=AAPL&to=2099-01-01", nil))
+	if w.Code != 200 {
+		t.Error("Expected a chart past the calendar's data, got", w.Code, w.Body)
+	}
+}
+
+func TestSessionLayout(t *testing.T) {
+	nyse, err := calendar.Get("nyse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := quote.NewQuote("AAPL", 4)
+	// the 4th of July and a weekend take no space, the missing 3rd does
+	for i, d := range []int{2, 5, 8, 9} {
+		q.Date[i] = time.Date(2024, 7, d, 0, 0, 0, 0, nyse.Location)
+	}
+	pos, unit, err := positions(q, nyse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0, 2, 3, 4}; unit != 1 || !reflect.DeepEqual(pos, want) {
+		t.Error("Expected", want, "by 1, got", pos, "by", unit)
+	}
+	if pos, unit, _ = positions(q, nil); unit != 1 || pos[3] != 3 {
+		t.Error("Expected bars by index without a calendar, got", pos, unit)
+	}
+	var b bytes.Buffer
+	q.Date[3] = time.Date(2099, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := Render(&b, q, Options{Calendar: nyse}); !errors.Is(err, calendar.ErrNoData) {
+		t.Error("Expected a series past the calendar's data to be refused, got", err)
+	}
+}
@@ -0,0 +1,87 @@
+package chart
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/calendar"
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// NewHandler serves
+//
+//	GET /chart.svg?symbol=&period=d&from=&to=&indicators=sma:20,rsi:14&volume=1&width=&height=&calendar=
+//
+// fetching candles through p. The range defaults to the last 180 days.
+// Bars are laid out by the sessions of the named calendar, by default
+// the one calendar.For gives the symbol, or by index if the range runs
+// past the calendar's data.
+func NewHandler(p provider.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		req := provider.Request{Symbol: q.Get("symbol"), Period: quote.Period(q.Get("period")), To: time.Now()}
+		if req.Symbol == "" {
+			http.Error(w, "missing symbol", http.StatusBadRequest)
+			return
+		}
+		if req.Period == "" {
+			req.Period = quote.Daily
+		}
+		var err error
+		if s := q.Get("to"); s != "" {
+			if req.To, err = time.Parse("2006-01-02", s); err != nil {
+				http.Error(w, "bad to date", http.StatusBadRequest)
+				return
+			}
+		}
+		req.From = req.To.AddDate(0, 0, -180)
+		if s := q.Get("from"); s != "" {
+			if req.From, err = time.Parse("2006-01-02", s); err != nil {
+				http.Error(w, "bad from date", http.StatusBadRequest)
+				return
+			}
+		}
+		o := Options{Volume: q.Get("volume") == "1"}
+		o.Width, _ = strconv.Atoi(q.Get("width"))
+		o.Height, _ = strconv.Atoi(q.Get("height"))
+		if s := q.Get("indicators"); s != "" {
+			o.Indicators = strings.Split(s, ",")
+		}
+		if name := q.Get("calendar"); name != "" {
+			o.Calendar, err = calendar.Get(name)
+		} else {
+			o.Calendar, err = calendar.For(req.Symbol)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := o.Calendar.Covers(req.To); err != nil {
+			log.Printf("chart: %v; laying %s out by index", err, req.Symbol)
+			o.Calendar = nil
+		}
+
+		candles, err := p.Fetch(req)
+		if provider.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		var b bytes.Buffer
+		if err := Render(&b, candles, o); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(b.Bytes())
+	})
+}
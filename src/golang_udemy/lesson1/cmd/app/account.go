@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["account"] = accountCmd
+	permissions["account"] = func(args []string) rbac.Permission { return rbac.ManageAccounts }
+}
+
+const accountUsage = `usage: app account <subcommand>
+  add <username> <name> <age> <password>
+  attach <person id> <username> <password>
+  passwd <username> <password>
+  keys <username>
+  issue <username> <scope>...
+  revoke <username> <key id>`
+
+func accountCmd(db *sql.DB, args []string) error {
+	s, err := account.NewStore(db)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(accountUsage)
+	}
+	switch {
+	case args[0] == "add" && len(args) == 5:
+		age, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("bad age %q", args[3])
+		}
+		rec, err := s.Register(mylib.Person{Name: args[2], Age: age}, args[1], args[4])
+		if err == nil {
+			fmt.Println(rec.ID)
+		}
+		return err
+	case args[0] == "attach" && len(args) == 4:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad person id %q", args[1])
+		}
+		_, err = s.Attach(id, args[2], args[3])
+		return err
+	case args[0] == "passwd" && len(args) == 3:
+		return s.SetPassword(args[1], args[2])
+	}
+	if len(args) < 2 {
+		return errors.New(accountUsage)
+	}
+	who, err := s.Lookup(args[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "keys" && len(args) == 2:
+		keys, err := s.Keys(who.ID)
+		for _, k := range keys {
+			fmt.Printf("%s\t%s\t%v\n", k.ID, k.CreatedAt.Format("2006-01-02 15:04"), k.Scopes)
+		}
+		return err
+	case args[0] == "issue" && len(args) > 2:
+		var scopes []account.Scope
+		for _, sc := range args[2:] {
+			scopes = append(scopes, account.Scope(strings.ToLower(sc)))
+		}
+		token, _, err := s.IssueKey(who.ID, scopes...)
+		if err == nil {
+			fmt.Println(token)
+		}
+		return err
+	case args[0] == "revoke" && len(args) == 3:
+		return s.RevokeKey(who.ID, args[2])
+	}
+	return errors.New(accountUsage)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/adjust"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["action"] = actionCmd
+	permissions["action"] = func(args []string) rbac.Permission {
+		if len(args) > 0 && args[0] == "add" {
+			return rbac.ManageUniverse
+		}
+		return rbac.ReadMarket
+	}
+}
+
+const actionUsage = `usage: app action <subcommand>
+  list <symbol>
+  add <symbol> <ex date, e.g. 2024-06-10> <split> <dividend>
+
+Records a corporate action for backtest -prices adjusted. Split is new
+shares per old share, dividend the cash per share; 0 for none. Adding an
+action on the ex date of another of the symbol replaces it.`
+
+func actionCmd(db *sql.DB, args []string) error {
+	s, err := adjust.NewStore(db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(args) == 2 && args[0] == "list":
+		list, err := s.Actions(args[1])
+		for _, a := range list {
+			fmt.Printf("%s\t%s\t%g\t%g\n", a.Symbol, a.ExDate.Format("2006-01-02"), a.Split, a.Dividend)
+		}
+		return err
+	case len(args) == 5 && args[0] == "add":
+		a := adjust.Action{Symbol: args[1]}
+		if a.ExDate, err = time.Parse("2006-01-02", args[2]); err != nil {
+			return fmt.Errorf("bad ex date %q", args[2])
+		}
+		if a.Split, err = strconv.ParseFloat(args[3], 64); err != nil || !(a.Split >= 0) {
+			return fmt.Errorf("bad split %q", args[3])
+		}
+		if a.Dividend, err = strconv.ParseFloat(args[4], 64); err != nil || !(a.Dividend >= 0) {
+			return fmt.Errorf("bad dividend %q", args[4])
+		}
+		if a.Split == 0 && a.Dividend == 0 {
+			return errors.New("an action needs a split or a dividend")
+		}
+		return s.Add(a)
+	}
+	return errors.New(actionUsage)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang_udemy/lesson1/adjust"
+	"golang_udemy/lesson1/backtest"
+	"golang_udemy/lesson1/dsl"
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/rbac"
+	"golang_udemy/lesson1/report"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func init() {
+	commands["backtest"] = backtestCmd
+	permissions["backtest"] = func(args []string) rbac.Permission { return rbac.ReadMarket }
+}
+
+const backtestUsage = `usage: app backtest [-prices raw|adjusted] <strategy file> <symbol> <from> <to> [report.md or report.html]
+
+Trades the strategy long only on daily candles from 10000 of capital with
+a 0.1% fee, bootstraps its trades 1000 times, and writes the report to
+the file or as Markdown to stdout. Prices are raw unless asked to be
+adjusted for the corporate actions recorded with app action.`
+
+const (
+	backtestCapital = 10000
+	backtestFee     = 0.001
+)
+
+// priceModes maps the values of backtest -prices to adjust's.
+var priceModes = map[string]adjust.Mode{
+	"raw":      adjust.Raw,
+	"adjusted": adjust.Adjusted,
+}
+
+func backtestCmd(db *sql.DB, args []string) error {
+	prices := "raw"
+	if len(args) > 1 && args[0] == "-prices" {
+		prices, args = args[1], args[2:]
+	}
+	mode, ok := priceModes[prices]
+	if !ok || len(args) != 4 && len(args) != 5 {
+		return errors.New(backtestUsage)
+	}
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	s, err := dsl.Compile(string(src))
+	if err != nil {
+		return err
+	}
+	req := provider.Request{Symbol: args[1], Period: quote.Daily}
+	if req.From, err = time.Parse("2006-01-02", args[2]); err != nil {
+		return errors.New(backtestUsage)
+	}
+	if req.To, err = time.Parse("2006-01-02", args[3]); err != nil {
+		return errors.New(backtestUsage)
+	}
+	p, err := market()
+	if err != nil {
+		return err
+	}
+	q, err := p.Fetch(req)
+	if err != nil {
+		return err
+	}
+	actions, err := adjust.NewStore(db)
+	if err != nil {
+		return err
+	}
+	if q, err = actions.Prices(q, mode); err != nil {
+		return err
+	}
+	r := report.Report{
+		Title: "Backtest of " + filepath.Base(args[0]) + " on " + args[1],
+		Params: map[string]string{
+			"strategy": s.Source,
+			"symbol":   args[1],
+			"from":     args[2],
+			"to":       args[3],
+			"fee":      "0.1%",
+			"prices":   prices,
+		},
+		Ledger: backtest.Run(s, q, backtestCapital, backtestFee),
+	}
+	sim := r.Ledger.Simulate(backtest.MonteCarlo{Method: backtest.Bootstrap, Runs: 1000, Seed: 1})
+	r.MonteCarlo = &sim
+	if len(args) == 4 {
+		return report.Markdown(os.Stdout, r)
+	}
+	f, err := os.Create(args[4])
+	if err != nil {
+		return err
+	}
+	write := report.Markdown
+	if filepath.Ext(args[4]) == ".html" {
+		write = report.HTML
+	}
+	if err := write(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
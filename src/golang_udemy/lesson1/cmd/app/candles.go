@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/calendar"
+	"golang_udemy/lesson1/marketpb"
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/rbac"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func init() {
+	commands["candles"] = candlesCmd
+	permissions["candles"] = func(args []string) rbac.Permission { return rbac.ReadMarket }
+}
+
+const candlesUsage = `usage: app candles <subcommand>
+  export <symbol> <period, e.g. d or 60> <from> <to> <file>
+  convert <from file> <to file>
+  gaps <symbol> <period> <from> <to> [calendar, e.g. nyse or crypto]
+
+The format follows the file extension: .pb for length-delimited protobuf,
+.json or .csv. A .csv file is read as the symbol named by its base name.
+
+gaps lists where the candles lack bars the calendar says should be
+there. The calendar defaults to crypto for pairs such as BTC-USD and
+nyse for anything else.`
+
+func candlesCmd(db *sql.DB, args []string) error {
+	switch {
+	case len(args) == 6 && args[0] == "export":
+		q, err := fetchCandles(args[1:5])
+		if err != nil {
+			return err
+		}
+		return writeCandles(args[5], q, quote.Period(args[2]))
+	case (len(args) == 5 || len(args) == 6) && args[0] == "gaps":
+		c, err := calendar.For(args[1])
+		if len(args) == 6 {
+			c, err = calendar.Get(args[5])
+		}
+		if err != nil {
+			return err
+		}
+		q, err := fetchCandles(args[1:5])
+		if err != nil {
+			return err
+		}
+		gaps, err := c.Gaps(q, quote.Period(args[2]))
+		for _, g := range gaps {
+			fmt.Printf("%s\t%s\t%d\n", g.After.Format(time.RFC3339), g.Before.Format(time.RFC3339), g.Missing)
+		}
+		return err
+	case len(args) == 3 && args[0] == "convert":
+		q, period, err := readCandles(args[1])
+		if err != nil {
+			return err
+		}
+		return writeCandles(args[2], q, period)
+	}
+	return errors.New(candlesUsage)
+}
+
+// fetchCandles fetches the candles of symbol, period, from and to in args.
+func fetchCandles(args []string) (quote.Quote, error) {
+	req := provider.Request{Symbol: args[0], Period: quote.Period(args[1])}
+	var err error
+	if req.From, err = time.Parse("2006-01-02", args[2]); err != nil {
+		return quote.Quote{}, errors.New(candlesUsage)
+	}
+	if req.To, err = time.Parse("2006-01-02", args[3]); err != nil {
+		return quote.Quote{}, errors.New(candlesUsage)
+	}
+	p, err := market()
+	if err != nil {
+		return quote.Quote{}, err
+	}
+	return p.Fetch(req)
+}
+
+func readCandles(path string) (quote.Quote, quote.Period, error) {
+	switch filepath.Ext(path) {
+	case ".pb":
+		f, err := os.Open(path)
+		if err != nil {
+			return quote.Quote{}, "", err
+		}
+		defer f.Close()
+		return marketpb.ReadQuote(marketpb.NewReader(f))
+	case ".json":
+		q, err := quote.NewQuoteFromJSONFile(path)
+		return q, "", err
+	case ".csv":
+		q, err := quote.NewQuoteFromCSVFile(strings.TrimSuffix(filepath.Base(path), ".csv"), path)
+		return q, "", err
+	}
+	return quote.Quote{}, "", fmt.Errorf("app: unknown candle file type %s", path)
+}
+
+func writeCandles(path string, q quote.Quote, period quote.Period) error {
+	switch filepath.Ext(path) {
+	case ".pb":
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := marketpb.WriteQuote(marketpb.NewWriter(f), q, period); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case ".json":
+		return q.WriteJSON(path, false)
+	case ".csv":
+		return q.WriteCSV(path)
+	}
+	return fmt.Errorf("app: unknown candle file type %s", path)
+}
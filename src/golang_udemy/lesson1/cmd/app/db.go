@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/dbadmin"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["db"] = dbCmd
+	permissions["db"] = func(args []string) rbac.Permission { return rbac.ManageDatabase }
+}
+
+const dbUsage = `usage: app db <subcommand>
+  backup <file>
+  restore [-unversioned] <file>
+  check
+  vacuum
+  version
+  dump [file]
+  load <file>
+  schedule <dir> <every, e.g. 1h> [keep] [max age, e.g. 720h]`
+
+func dbCmd(db *sql.DB, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if len(args) == 0 {
+		return errors.New(dbUsage)
+	}
+	switch {
+	case args[0] == "backup" && len(args) == 2:
+		return dbadmin.Backup(ctx, db, args[1])
+	case args[0] == "restore" && len(args) == 2:
+		return dbadmin.Restore(ctx, db, args[1])
+	case args[0] == "restore" && len(args) == 3 && args[1] == "-unversioned":
+		return dbadmin.RestoreUnversioned(ctx, db, args[2])
+	case args[0] == "check" && len(args) == 1:
+		problems, err := dbadmin.Check(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems found", len(problems))
+		}
+		fmt.Println("ok")
+		return nil
+	case args[0] == "vacuum" && len(args) == 1:
+		return dbadmin.Vacuum(ctx, db)
+	case args[0] == "version" && len(args) == 1:
+		v, err := dbadmin.Version(ctx, db)
+		fmt.Printf("schema version %d, code expects %d\n", v, dbadmin.SchemaVersion)
+		return err
+	case args[0] == "dump" && len(args) <= 2:
+		if len(args) == 1 {
+			return dbadmin.Dump(ctx, db, os.Stdout)
+		}
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		if err := dbadmin.Dump(ctx, db, f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case args[0] == "load" && len(args) == 2:
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return dbadmin.Load(ctx, db, f)
+	case args[0] == "schedule" && len(args) >= 3 && len(args) <= 5:
+		every, err := time.ParseDuration(args[2])
+		if err != nil {
+			return err
+		}
+		var r dbadmin.Retention
+		if len(args) > 3 {
+			if r.Keep, err = strconv.Atoi(args[3]); err != nil {
+				return fmt.Errorf("bad keep %q", args[3])
+			}
+		}
+		if len(args) > 4 {
+			if r.MaxAge, err = time.ParseDuration(args[4]); err != nil {
+				return err
+			}
+		}
+		err = dbadmin.Schedule(ctx, db, args[1], every, r)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+	return errors.New(dbUsage)
+}
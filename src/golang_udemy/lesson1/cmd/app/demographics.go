@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang_udemy/lesson1/demographics"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["demographics"] = demographicsCmd
+	permissions["demographics"] = func(args []string) rbac.Permission { return rbac.ReadPersons }
+}
+
+const demographicsUsage = `usage: app demographics <subcommand>
+  summary [group by] [percentile]...
+  histogram <width or edges, e.g. 10 or 0,18,65> [group by]`
+
+func demographicsCmd(db *sql.DB, args []string) error {
+	s, err := demographics.New(db)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(demographicsUsage)
+	}
+	switch {
+	case args[0] == "summary":
+		group := ""
+		if len(args) > 1 {
+			group = args[1]
+		}
+		var ps []float64
+		for _, a := range args[min(len(args), 2):] {
+			p, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return fmt.Errorf("bad percentile %q", a)
+			}
+			ps = append(ps, p)
+		}
+		list, err := s.Summarize(group, ps...)
+		for _, sum := range list {
+			fmt.Printf("%s\tn=%d\tmin=%d\tmax=%d\tmean=%.2f\tmedian=%.1f", sum.Group, sum.Count, sum.Min, sum.Max, sum.Mean, sum.Median)
+			for _, p := range ps {
+				k := "p" + strconv.FormatFloat(p, 'f', -1, 64)
+				fmt.Printf("\t%s=%.1f", k, sum.Percentiles[k])
+			}
+			fmt.Println()
+		}
+		return err
+	case args[0] == "histogram" && (len(args) == 2 || len(args) == 3):
+		var edges []int
+		if strings.Contains(args[1], ",") {
+			edges, err = demographics.Edges(args[1], "", "")
+		} else {
+			edges, err = demographics.Edges("", args[1], "")
+		}
+		if err != nil {
+			return err
+		}
+		group := ""
+		if len(args) == 3 {
+			group = args[2]
+		}
+		list, err := s.Histogram(group, edges)
+		for _, h := range list {
+			if h.Group != "" {
+				fmt.Println(h.Group)
+			}
+			for _, b := range h.Bins {
+				hi := "+"
+				if b.Hi != nil {
+					hi = "-" + strconv.Itoa(*b.Hi-1)
+				}
+				fmt.Printf("  %3d%-4s %5d %s\n", b.Lo, hi, b.Count, strings.Repeat("#", b.Count))
+			}
+		}
+		return err
+	}
+	return errors.New(demographicsUsage)
+}
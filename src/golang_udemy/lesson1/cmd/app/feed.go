@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang_udemy/lesson1/feed"
+	"golang_udemy/lesson1/rbac"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func init() {
+	commands["feed"] = feedCmd
+	permissions["feed"] = func(args []string) rbac.Permission { return rbac.ReadMarket }
+}
+
+const feedUsage = `usage: app feed <udp addr> <cert file> <key file> <minutes: 1, 5, 15, 30 or 60> <symbol>...
+
+Polls the candles of each symbol every period and pushes them to QUIC
+feed clients. Candles come from the same provider as app serve.`
+
+// minutes maps the periods app feed takes to go-quote's, which are in
+// seconds.
+var minutes = map[string]quote.Period{
+	"1":  quote.Min1,
+	"5":  quote.Min5,
+	"15": quote.Min15,
+	"30": quote.Min30,
+	"60": quote.Min60,
+}
+
+func feedCmd(db *sql.DB, args []string) error {
+	if len(args) < 5 {
+		return errors.New(feedUsage)
+	}
+	cert, err := tls.LoadX509KeyPair(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	period, ok := minutes[args[3]]
+	if !ok {
+		return errors.New(feedUsage)
+	}
+	every, _ := time.ParseDuration(args[3] + "m")
+	p, err := market()
+	if err != nil {
+		return err
+	}
+	hub := feed.NewHub()
+	s, err := feed.Listen(args[0], &tls.Config{Certificates: []tls.Certificate{cert}}, hub)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	go feed.Poll(context.Background(), hub, p, period, every, args[4:]...)
+	fmt.Println("feeding on", s.Addr())
+	return s.Serve()
+}
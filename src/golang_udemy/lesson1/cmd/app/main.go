@@ -0,0 +1,161 @@
+// Command app manages the lesson1 database from the command line.
+//
+//	app [-db example.sql] <command> [args...]
+//
+// The caller authenticates with an API key in $APP_API_KEY, or with the
+// account $APP_USER and its password in $APP_PASSWORD. Commands are
+// checked against the roles of that person, and nothing is allowed until
+// the first admin has been made with 'app role bootstrap', except
+// loading or restoring into an empty database. An API key must also
+// carry the scope the command needs, and administrative commands need a
+// password.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/rbac"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// commands maps a command name to its implementation.
+var commands = map[string]func(db *sql.DB, args []string) error{}
+
+// permissions gives the permission a command needs for its arguments.
+var permissions = map[string]func(args []string) rbac.Permission{}
+
+// caller is who authenticated, nil if no credentials were given.
+var caller *account.Caller
+
+// actor is recorded as the author of changes: the caller's name, or
+// $USER when no one has authenticated. Changes to persons fail when
+// neither is set rather than being recorded as made by no one.
+var actor = os.Getenv("USER")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: app [-db file] <command> [args...]")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+name)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	path := flag.String("db", "example.sql", "SQLite database file")
+	flag.Usage = usage
+	flag.Parse()
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+	}
+	db, err := sql.Open("sqlite3", *path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	if err := authenticate(db); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := authorize(db, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := cmd(db, flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// authenticate sets caller from the credentials in the environment.
+func authenticate(db *sql.DB) error {
+	key, user := os.Getenv("APP_API_KEY"), os.Getenv("APP_USER")
+	if key == "" && user == "" {
+		return nil
+	}
+	accounts, err := account.NewStore(db)
+	if err != nil {
+		return err
+	}
+	var c account.Caller
+	if key != "" {
+		var k account.Key
+		c.Person, k, err = accounts.Verify(key)
+		c.Key = &k
+	} else {
+		c.Person, err = accounts.Login(user, os.Getenv("APP_PASSWORD"))
+	}
+	if err != nil {
+		return err
+	}
+	caller, actor = &c, c.Person.Name
+	return nil
+}
+
+// authorize checks that the caller may run args.
+func authorize(db *sql.DB, args []string) error {
+	if len(args) > 1 && args[0] == "role" && args[1] == "bootstrap" {
+		return nil // refused by rbac once anyone has a role
+	}
+	// don't create the rbac tables just to check; 'db load' wants an empty database
+	var tables, n int
+	err := db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE name = 'role_assignments') FROM sqlite_master`).Scan(&tables, &n)
+	if err != nil {
+		return err
+	}
+	if tables == 0 && len(args) > 1 && args[0] == "db" && (args[1] == "load" || args[1] == "restore") {
+		return nil
+	}
+	if n == 0 {
+		return errNoAdmin
+	}
+	e, err := rbac.NewEnforcer(db, rbac.DefaultPolicy)
+	if err != nil {
+		return err
+	}
+	if ok, err := e.Bootstrapped(); err != nil {
+		return err
+	} else if !ok {
+		return errNoAdmin
+	}
+	perm, ok := permissions[args[0]]
+	if !ok {
+		return fmt.Errorf("app: no permission defined for %s", args[0])
+	}
+	if caller == nil {
+		return errors.New("app: set APP_API_KEY, or APP_USER and APP_PASSWORD")
+	}
+	need := perm(args[1:])
+	if sc, ok := scopes[need]; caller.Key != nil && (!ok || !caller.Allows(sc)) {
+		return fmt.Errorf("app: api key may not %s", need)
+	}
+	return e.Check(caller.Person.ID, need, "app "+strings.Join(args, " "))
+}
+
+var errNoAdmin = errors.New("app: no one has a role yet; make the first admin with 'app role bootstrap'")
+
+// scopes gives the API key scope covering a permission. Permissions
+// missing here need a password.
+var scopes = map[rbac.Permission]account.Scope{
+	rbac.ReadMarket:      account.ScopeRead,
+	rbac.ReadOwn:         account.ScopeRead,
+	rbac.ReadPersons:     account.ScopeRead,
+	rbac.EditWatchlists:  account.ScopeWatchlists,
+	rbac.EditAlerts:      account.ScopeAlerts,
+	rbac.EditStrategies:  account.ScopeTrade,
+	rbac.PlaceLiveOrders: account.ScopeTrade,
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/persons"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["person"] = personCmd
+	permissions["person"] = func(args []string) rbac.Permission {
+		if len(args) > 0 {
+			switch args[0] {
+			case "add", "set", "delete", "restore":
+				return rbac.EditPersons
+			}
+		}
+		return rbac.ReadPersons
+	}
+}
+
+const personUsage = `usage: app person <subcommand>
+  list [-deleted]
+  show <id> [as of, e.g. 2024-01-02T15:04:05Z]
+  add <name> <age>
+  set <id> <name> <age>
+  delete <id>
+  restore <id>
+  search <query>...
+  history <id>
+  changes [since, e.g. 24h]`
+
+func personCmd(db *sql.DB, args []string) error {
+	repo, err := persons.NewRepository(db)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(personUsage)
+	}
+	var id int64
+	if len(args) > 1 && args[0] != "list" && args[0] != "add" && args[0] != "changes" && args[0] != "search" {
+		if id, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("bad person id %q", args[1])
+		}
+	}
+	switch {
+	case args[0] == "list" && len(args) <= 2:
+		list, err := repo.List(len(args) == 2 && args[1] == "-deleted")
+		for _, rec := range list {
+			printPerson(rec)
+		}
+		return err
+	case args[0] == "show" && len(args) == 2:
+		rec, err := repo.Get(id)
+		if err == nil {
+			printPerson(rec)
+		}
+		return err
+	case args[0] == "show" && len(args) == 3:
+		t, err := time.Parse(time.RFC3339, args[2])
+		if err != nil {
+			return err
+		}
+		rec, err := repo.GetAsOf(id, t)
+		if err == nil {
+			printPerson(rec)
+		}
+		return err
+	case args[0] == "add" && len(args) == 3:
+		age, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("bad age %q", args[2])
+		}
+		rec, err := repo.Create(mylib.Person{Name: args[1], Age: age}, actor)
+		if err == nil {
+			printPerson(rec)
+		}
+		return err
+	case args[0] == "set" && len(args) == 4:
+		age, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("bad age %q", args[3])
+		}
+		_, err = repo.Update(id, mylib.Person{Name: args[2], Age: age}, actor)
+		return err
+	case args[0] == "delete" && len(args) == 2:
+		return repo.Delete(id, actor)
+	case args[0] == "restore" && len(args) == 2:
+		_, err := repo.Restore(id, actor)
+		return err
+	case args[0] == "search" && len(args) > 1:
+		hits, err := repo.Search(strings.Join(args[1:], " "), 20)
+		for _, h := range hits {
+			fmt.Printf("%d\t%s\t%d\t%.3f\n", h.ID, h.Snippet, h.Age, h.Rank)
+		}
+		return err
+	case args[0] == "history" && len(args) == 2:
+		list, err := repo.History(id)
+		printChanges(list)
+		return err
+	case args[0] == "changes" && len(args) <= 2:
+		var since time.Time
+		if len(args) == 2 {
+			d, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			since = time.Now().Add(-d)
+		}
+		list, err := repo.ListChanges(since)
+		printChanges(list)
+		return err
+	}
+	return errors.New(personUsage)
+}
+
+func printPerson(rec persons.Record) {
+	deleted := ""
+	if rec.Deleted {
+		deleted = "\tdeleted"
+	}
+	fmt.Printf("%d\t%s\t%d%s\n", rec.ID, rec.Name, rec.Age, deleted)
+}
+
+func printChanges(list []persons.Change) {
+	for _, c := range list {
+		fmt.Printf("%d\t%s\t%s\t%d\t%s\t%s\t%d\n", c.Version, c.At.Format(time.RFC3339), c.Op,
+			c.Record.ID, c.By, c.Record.Name, c.Record.Age)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/rbac"
+	"golang_udemy/lesson1/refresh"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+func init() {
+	commands["refresh"] = refreshCmd
+	permissions["refresh"] = func(args []string) rbac.Permission { return rbac.ManageUniverse }
+}
+
+const refreshUsage = `usage: app refresh <every, e.g. 1h or 0 for once> <backfill, e.g. 720h> <symbol>[:period]...
+
+Keeps the candles table up to date with the candles of each symbol, daily
+unless a go-quote period such as 60 follows it. Symbols with no candles
+yet are backfilled; after that each refresh starts at the last stored
+bar. Candles come from the same provider as app serve, two at a time.`
+
+func refreshCmd(db *sql.DB, args []string) error {
+	if len(args) < 3 {
+		return errors.New(refreshUsage)
+	}
+	every, err := time.ParseDuration(args[0])
+	if err != nil {
+		return errors.New(refreshUsage)
+	}
+	backfill, err := time.ParseDuration(args[1])
+	if err != nil {
+		return errors.New(refreshUsage)
+	}
+	p, err := market()
+	if err != nil {
+		return err
+	}
+	store, err := refresh.NewStore(db)
+	if err != nil {
+		return err
+	}
+	var jobs []refresh.Job
+	for _, arg := range args[2:] {
+		symbol, period, ok := strings.Cut(arg, ":")
+		if !ok {
+			period = string(quote.Daily)
+		}
+		jobs = append(jobs, refresh.Job{Source: p.Name(), Symbol: symbol, Period: quote.Period(period), Every: every, Backfill: backfill})
+	}
+	s := refresh.New(store, refresh.DefaultRetry, refresh.Source{Provider: p, Concurrency: 2})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if every == 0 {
+		return s.RefreshAll(ctx, jobs...)
+	}
+	err = s.Run(ctx, jobs...)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
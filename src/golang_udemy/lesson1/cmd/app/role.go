@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/rbac"
+)
+
+func init() {
+	commands["role"] = roleCmd
+	permissions["role"] = func(args []string) rbac.Permission {
+		if len(args) > 0 && args[0] == "denials" {
+			return rbac.ReadAudit
+		}
+		return rbac.ManageRoles
+	}
+}
+
+const roleUsage = `usage: app role <subcommand>
+  list
+  assign <person id> <role>
+  unassign <person id> <role>
+  denials [since, e.g. 24h]
+  bootstrap <username> <name> <age> <password>
+
+bootstrap adds an account and makes it the first admin. Until then no
+other command is allowed, and once anyone has a role it is refused.`
+
+func roleCmd(db *sql.DB, args []string) error {
+	e, err := rbac.NewEnforcer(db, rbac.DefaultPolicy)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(roleUsage)
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		m, err := e.Assignments()
+		var ids []int64
+		for id := range m {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			fmt.Printf("%d\t%v\n", id, m[id])
+		}
+		return err
+	case (args[0] == "assign" || args[0] == "unassign") && len(args) == 3:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad person id %q", args[1])
+		}
+		if args[0] == "assign" {
+			return e.Assign(id, rbac.Role(args[2]))
+		}
+		return e.Unassign(id, rbac.Role(args[2]))
+	case args[0] == "bootstrap" && len(args) == 5:
+		age, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("bad age %q", args[3])
+		}
+		if ok, err := e.Bootstrapped(); ok || err != nil {
+			if err == nil {
+				err = rbac.ErrBootstrapped
+			}
+			return err
+		}
+		s, err := account.NewStore(db)
+		if err != nil {
+			return err
+		}
+		rec, err := s.Register(mylib.Person{Name: args[2], Age: age}, args[1], args[4])
+		if err != nil {
+			return err
+		}
+		if err := e.Bootstrap(rec.ID); err != nil {
+			return err
+		}
+		fmt.Println(rec.ID)
+		return nil
+	case args[0] == "denials" && len(args) <= 2:
+		var since time.Time
+		if len(args) == 2 {
+			d, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			since = time.Now().Add(-d)
+		}
+		list, err := e.Denials(since)
+		for _, d := range list {
+			fmt.Printf("%s\t%d\t%s\t%s\n", d.Time.Format(time.RFC3339), d.Person, d.Permission, d.Resource)
+		}
+		return err
+	}
+	return errors.New(roleUsage)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/rbac"
+	"golang_udemy/lesson1/server"
+)
+
+func init() {
+	commands["serve"] = serveCmd
+	permissions["serve"] = func(args []string) rbac.Permission { return rbac.ManageDatabase }
+}
+
+const serveUsage = `usage: app serve [addr, default :8080]
+
+Candles come from Tiingo when $TIINGO_TOKEN is set, Coinbase otherwise.`
+
+func serveCmd(db *sql.DB, args []string) error {
+	if len(args) > 1 {
+		return errors.New(serveUsage)
+	}
+	addr := ":8080"
+	if len(args) == 1 {
+		addr = args[0]
+	}
+	p, err := market()
+	if err != nil {
+		return err
+	}
+	h, err := server.New(db, p)
+	if err != nil {
+		return err
+	}
+	fmt.Println("listening on", addr)
+	srv := &http.Server{Addr: addr, Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
+
+// market returns the candle provider, Tiingo when $TIINGO_TOKEN is set
+// and Coinbase otherwise, rate limited and behind a circuit breaker.
+func market() (provider.Provider, error) {
+	p := provider.Coinbase()
+	if token := os.Getenv("TIINGO_TOKEN"); token != "" {
+		p = provider.Tiingo(token)
+	}
+	b, err := provider.NewBucket(2, 5)
+	if err != nil {
+		return nil, err
+	}
+	return provider.WithBreaker(provider.RateLimited(p, b), 5, time.Minute), nil
+}
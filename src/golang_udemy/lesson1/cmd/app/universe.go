@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"golang_udemy/lesson1/rbac"
+	"golang_udemy/lesson1/universe"
+)
+
+func init() {
+	commands["universe"] = universeCmd
+	permissions["universe"] = func(args []string) rbac.Permission {
+		if len(args) == 0 {
+			return rbac.ReadMarket
+		}
+		switch args[0] {
+		case "import":
+			return rbac.ManageUniverse
+		case "watch", "unwatch":
+			return rbac.EditWatchlists
+		case "watchlists", "show":
+			return rbac.ReadOwn
+		}
+		return rbac.ReadMarket
+	}
+}
+
+const universeUsage = `usage: app universe <subcommand>
+  markets
+  symbols [market]
+  import <market> [file]
+  watchlists
+  show <watchlist>
+  watch <watchlist> <symbol>...
+  unwatch <watchlist> <symbol>...
+
+Watchlist commands act on the caller's own watchlists.`
+
+func universeCmd(db *sql.DB, args []string) error {
+	s, err := universe.NewStore(db)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(universeUsage)
+	}
+	switch {
+	case args[0] == "markets":
+		markets, err := s.Markets()
+		for _, m := range markets {
+			fmt.Println(m)
+		}
+		return err
+	case args[0] == "symbols":
+		market := ""
+		if len(args) > 1 {
+			market = args[1]
+		}
+		syms, err := s.Symbols(market)
+		for _, sym := range syms {
+			fmt.Printf("%s\t%s\t%s\t%s\t%d\n", sym.Symbol, sym.Market, sym.Exchange, sym.QuoteCurrency, sym.Precision)
+		}
+		return err
+	case args[0] == "import" && len(args) == 2:
+		return s.ImportMarket(args[1])
+	case args[0] == "import" && len(args) == 3:
+		return s.ImportFile(args[1], args[2])
+	}
+	if caller == nil {
+		return errors.New("app: watchlists belong to the caller; set APP_API_KEY, or APP_USER and APP_PASSWORD")
+	}
+	owner := caller.Person.ID
+	switch {
+	case args[0] == "watchlists" && len(args) == 1:
+		names, err := s.Watchlists(owner)
+		for _, n := range names {
+			fmt.Println(n)
+		}
+		return err
+	case args[0] == "show" && len(args) == 2:
+		syms, err := s.Watchlist(owner, args[1])
+		for _, sym := range syms {
+			fmt.Println(sym.Symbol)
+		}
+		return err
+	case args[0] == "watch" && len(args) > 2:
+		if err := s.CreateWatchlist(owner, args[1]); err != nil {
+			return err
+		}
+		return s.Watch(owner, args[1], args[2:]...)
+	case args[0] == "unwatch" && len(args) > 2:
+		return s.Unwatch(owner, args[1], args[2:]...)
+	}
+	return errors.New(universeUsage)
+}
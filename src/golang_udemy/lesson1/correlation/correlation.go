@@ -0,0 +1,248 @@
+/*
+correlation computes return correlation and covariance matrices and
+rolling betas across several candle series.
+*/
+package correlation
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+	talib "github.com/markcheno/go-talib"
+)
+
+// Align keeps the dates every quote has a bar for and returns the
+// closes of each quote on those dates.
+func Align(quotes []quote.Quote) ([]time.Time, [][]float64) {
+	if len(quotes) == 0 {
+		return nil, nil
+	}
+	count := map[int64]int{}
+	for _, q := range quotes {
+		for _, d := range q.Date {
+			count[d.Unix()]++
+		}
+	}
+	var dates []time.Time
+	for _, d := range quotes[0].Date {
+		if count[d.Unix()] == len(quotes) {
+			dates = append(dates, d)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	closes := make([][]float64, len(quotes))
+	for i, q := range quotes {
+		at := map[int64]float64{}
+		for j, d := range q.Date {
+			at[d.Unix()] = q.Close[j]
+		}
+		closes[i] = make([]float64, len(dates))
+		for j, d := range dates {
+			closes[i][j] = at[d.Unix()]
+		}
+	}
+	return dates, closes
+}
+
+// Returns turns a price series into simple bar-to-bar returns. A bar
+// after a zero price, which has no return, counts as 0.
+func Returns(prices []float64) []float64 {
+	if len(prices) < 2 {
+		return nil
+	}
+	r := make([]float64, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] != 0 {
+			r[i-1] = prices[i]/prices[i-1] - 1
+		}
+	}
+	return r
+}
+
+// Matrix is a symmetric table of values between symbols.
+type Matrix struct {
+	Symbols []string    `json:"symbols"`
+	Values  [][]float64 `json:"values"`
+}
+
+// Result holds the matrices of one analysis.
+type Result struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Bars        int       `json:"bars"`
+	Correlation Matrix    `json:"correlation"`
+	Covariance  Matrix    `json:"covariance"`
+}
+
+// Analyze aligns quotes and computes the correlation and covariance of
+// their returns over the last window bars (all bars if window is 0).
+// Symbols are ordered so that correlated ones sit next to each other.
+func Analyze(quotes []quote.Quote, window int) Result {
+	dates, closes := Align(quotes)
+	if window > 0 && len(dates) > window+1 {
+		dates = dates[len(dates)-window-1:]
+		for i := range closes {
+			closes[i] = closes[i][len(closes[i])-window-1:]
+		}
+	}
+	returns := make([][]float64, len(closes))
+	for i := range closes {
+		returns[i] = Returns(closes[i])
+	}
+
+	n := len(quotes)
+	res := Result{Bars: len(dates) - 1}
+	if len(dates) > 0 {
+		res.From, res.To = dates[0], dates[len(dates)-1]
+	}
+	if res.Bars < 0 {
+		res.Bars = 0
+	}
+	symbols := make([]string, n)
+	cov := square(n)
+	corr := square(n)
+	for i := 0; i < n; i++ {
+		symbols[i] = quotes[i].Symbol
+		for j := 0; j <= i; j++ {
+			cov[i][j] = covariance(returns[i], returns[j])
+			cov[j][i] = cov[i][j]
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			// a flat series has no correlation with anything
+			if cov[i][i] > 0 && cov[j][j] > 0 {
+				corr[i][j] = cov[i][j] / math.Sqrt(cov[i][i]*cov[j][j])
+			}
+		}
+	}
+	res.Correlation = Matrix{symbols, corr}
+	res.Covariance = Matrix{symbols, cov}
+
+	order := Cluster(res.Correlation)
+	res.Correlation = res.Correlation.Reorder(order)
+	res.Covariance = res.Covariance.Reorder(order)
+	return res
+}
+
+// Beta is the beta of a symbol against a benchmark at one date.
+type Beta struct {
+	Date time.Time `json:"date"`
+	Beta float64   `json:"beta"`
+}
+
+// RollingBeta returns talib's beta of asset against benchmark over
+// each window of bars, on the dates both have. window must be at least
+// 2; dates where a zero price leaves beta undefined are skipped.
+func RollingBeta(asset, benchmark quote.Quote, window int) []Beta {
+	dates, closes := Align([]quote.Quote{asset, benchmark})
+	if window < 2 || len(dates) <= window {
+		return nil
+	}
+	beta := talib.Beta(closes[0], closes[1], window)
+	out := make([]Beta, 0, len(dates)-window)
+	for i := window; i < len(dates); i++ {
+		if math.IsNaN(beta[i]) || math.IsInf(beta[i], 0) {
+			continue
+		}
+		out = append(out, Beta{dates[i], beta[i]})
+	}
+	return out
+}
+
+// Reorder returns m with its rows and columns in the given order.
+func (m Matrix) Reorder(order []int) Matrix {
+	out := Matrix{make([]string, len(order)), square(len(order))}
+	for i, oi := range order {
+		out.Symbols[i] = m.Symbols[oi]
+		for j, oj := range order {
+			out.Values[i][j] = m.Values[oi][oj]
+		}
+	}
+	return out
+}
+
+// WriteCSV writes m with a header row and a leading symbol column.
+func (m Matrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{""}, m.Symbols...))
+	for i, row := range m.Values {
+		rec := []string{m.Symbols[i]}
+		for _, v := range row {
+			rec = append(rec, strconv.FormatFloat(v, 'f', 6, 64))
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Cluster orders the symbols of a correlation matrix by average-linkage
+// hierarchical clustering on the distance 1 - correlation.
+func Cluster(corr Matrix) []int {
+	type cluster struct{ members []int }
+	clusters := make([]cluster, len(corr.Symbols))
+	for i := range clusters {
+		clusters[i] = cluster{[]int{i}}
+	}
+	dist := func(a, b cluster) float64 {
+		sum := 0.0
+		for _, i := range a.members {
+			for _, j := range b.members {
+				sum += 1 - corr.Values[i][j]
+			}
+		}
+		return sum / float64(len(a.members)*len(b.members))
+	}
+	for len(clusters) > 1 {
+		bi, bj, best := 0, 1, math.Inf(1)
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				if d := dist(clusters[i], clusters[j]); d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		merged := cluster{append(append([]int{}, clusters[bi].members...), clusters[bj].members...)}
+		clusters[bi] = merged
+		clusters = append(clusters[:bj], clusters[bj+1:]...)
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+	return clusters[0].members
+}
+
+func covariance(a, b []float64) float64 {
+	n := len(a)
+	if n < 2 {
+		return 0
+	}
+	ma, mb := mean(a), mean(b)
+	sum := 0.0
+	for i := range a {
+		sum += (a[i] - ma) * (b[i] - mb)
+	}
+	return sum / float64(n-1)
+}
+
+func mean(s []float64) float64 {
+	sum := 0.0
+	for _, v := range s {
+		sum += v
+	}
+	return sum / float64(len(s))
+}
+
+func square(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+	}
+	return m
+}
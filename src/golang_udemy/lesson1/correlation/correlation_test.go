@@ -0,0 +1,126 @@
+package correlation
+
+import (
+	"bytes"
+	"database/sql"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/universe"
+
+	quote "github.com/markcheno/go-quote"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var base = []float64{100, 102, 101, 105, 104, 108, 107, 111, 115, 112}
+
+// series builds a quote whose closes are f applied to base.
+func series(symbol string, f func(float64) float64) quote.Quote {
+	q := quote.NewQuote(symbol, len(base))
+	for i, c := range base {
+		q.Date[i] = time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		q.Close[i] = f(c)
+	}
+	return q
+}
+
+func quotes() []quote.Quote {
+	return []quote.Quote{
+		series("A", func(c float64) float64 { return c }),
+		series("C", func(c float64) float64 { return 1000 / c }),
+		series("B", func(c float64) float64 { return 3 * c }),
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	res := Analyze(quotes(), 0)
+	if got := strings.Join(res.Correlation.Symbols, ","); got != "A,B,C" && got != "C,A,B" {
+		t.Error("Expected A and B next to each other, got", got)
+	}
+	idx := map[string]int{}
+	for i, s := range res.Correlation.Symbols {
+		idx[s] = i
+	}
+	if c := res.Correlation.Values[idx["A"]][idx["B"]]; math.Abs(c-1) > 1e-9 {
+		t.Error("Expected correlation 1, got", c)
+	}
+	if c := res.Correlation.Values[idx["A"]][idx["C"]]; c > -0.9 {
+		t.Error("Expected strong negative correlation, got", c)
+	}
+	if res.Bars != 9 {
+		t.Error("Expected 9 return bars, got", res.Bars)
+	}
+
+	var buf bytes.Buffer
+	res.Correlation.WriteCSV(&buf)
+	if !strings.HasPrefix(buf.String(), ","+strings.Join(res.Correlation.Symbols, ",")+"\n") {
+		t.Error("Unexpected CSV", buf.String())
+	}
+}
+
+func TestRollingBeta(t *testing.T) {
+	q := quotes()
+	betas := RollingBeta(q[2], q[0], 5)
+	if len(betas) != 5 || math.Abs(betas[len(betas)-1].Beta-1) > 1e-9 {
+		t.Error("Expected beta 1, got", betas)
+	}
+	if betas := RollingBeta(q[2], q[0], -1); betas != nil {
+		t.Error("Expected no betas for a negative window, got", betas)
+	}
+
+	zero := series("Z", func(c float64) float64 { return c })
+	zero.Close[3] = 0
+	for _, b := range RollingBeta(zero, q[0], 3) {
+		if math.IsNaN(b.Beta) || math.IsInf(b.Beta, 0) {
+			t.Error("Expected finite betas, got", b)
+		}
+	}
+	for _, r := range Returns(zero.Close) {
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			t.Error("Expected finite returns, got", r)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+	s, _ := universe.NewStore(db)
+	s.Import("test", []string{"A", "B", "C"})
+	const bob = 1
+	s.CreateWatchlist(bob, "all")
+	s.Watch(bob, "all", "A", "B", "C")
+
+	byName := map[string]quote.Quote{}
+	for _, q := range quotes() {
+		byName[q.Symbol] = q
+	}
+	p := provider.Func{ProviderName: "fake", Get: func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		return byName[symbol], nil
+	}}
+	owner := func(*http.Request) (int64, error) { return bob, nil }
+	h := NewHandler(s, p, owner)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/correlation?watchlist=all&from=2024-01-01&to=2024-01-10&benchmark=A&beta_window=5", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"betas":{"A":`) {
+		t.Error("Unexpected response", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/correlation?watchlist=nope&from=2024-01-01&to=2024-01-10", nil))
+	if w.Code != 404 {
+		t.Error("Expected 404, got", w.Code)
+	}
+	for _, bw := range []string{"-1", "0", "1", "x"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/correlation?watchlist=all&from=2024-01-01&to=2024-01-10&benchmark=A&beta_window="+bw, nil))
+		if w.Code != 400 {
+			t.Error("Expected 400 for beta_window", bw, "got", w.Code)
+		}
+	}
+}
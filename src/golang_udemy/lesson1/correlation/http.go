@@ -0,0 +1,105 @@
+package correlation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/universe"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// NewHandler serves
+//
+//	GET /correlation?watchlist=&from=&to=&period=d&window=&benchmark=&beta_window=20&format=json|csv
+//
+// fetching the candles of the owner's watchlist (and the optional
+// benchmark) through p. The CSV format returns the correlation matrix only.
+func NewHandler(s *universe.Store, p provider.Provider, owner universe.OwnerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		person, err := owner(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		syms, err := s.Watchlist(person, q.Get("watchlist"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, universe.ErrNoWatchlist) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		req := provider.Request{Period: quote.Period(q.Get("period"))}
+		if req.Period == "" {
+			req.Period = quote.Daily
+		}
+		if req.From, err = time.Parse("2006-01-02", q.Get("from")); err != nil {
+			http.Error(w, "bad from date", http.StatusBadRequest)
+			return
+		}
+		if req.To, err = time.Parse("2006-01-02", q.Get("to")); err != nil {
+			http.Error(w, "bad to date", http.StatusBadRequest)
+			return
+		}
+		window := 0
+		if v := q.Get("window"); v != "" {
+			if window, err = strconv.Atoi(v); err != nil || window < 0 {
+				http.Error(w, "bad window", http.StatusBadRequest)
+				return
+			}
+		}
+		betaWindow := 20
+		if v := q.Get("beta_window"); v != "" {
+			if betaWindow, err = strconv.Atoi(v); err != nil || betaWindow < 2 {
+				http.Error(w, "beta_window must be at least 2", http.StatusBadRequest)
+				return
+			}
+		}
+
+		fetch := func(symbol string) (quote.Quote, error) {
+			req.Symbol = symbol
+			return p.Fetch(req)
+		}
+		var quotes []quote.Quote
+		for _, sym := range syms {
+			qt, err := fetch(sym.Symbol)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			quotes = append(quotes, qt)
+		}
+		res := struct {
+			Result
+			Benchmark string            `json:"benchmark,omitempty"`
+			Betas     map[string][]Beta `json:"betas,omitempty"`
+		}{Result: Analyze(quotes, window)}
+
+		if q.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			res.Correlation.WriteCSV(w)
+			return
+		}
+		if bench := q.Get("benchmark"); bench != "" {
+			bq, err := fetch(bench)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			res.Benchmark = bench
+			res.Betas = map[string][]Beta{}
+			for _, qt := range quotes {
+				res.Betas[qt.Symbol] = RollingBeta(qt, bq, betaWindow)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	})
+}
@@ -0,0 +1,187 @@
+/*
+dbadmin backs up, restores and checks the SQLite database while it is
+in use, through SQLite's online backup API.
+*/
+package dbadmin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// SchemaVersion is the PRAGMA user_version of a database fully migrated
+// by the code in this tree. Bump it with every migration.
+//
+//	1: persons have ids, a deleted flag and person_history
+const SchemaVersion = 1
+
+// stepPages is how many pages a backup copies before letting writers in.
+const stepPages = 256
+
+// Backup copies db to the file path while db stays usable.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("dbadmin: %s already exists", path)
+	}
+	dest, err := sql.Open("sqlite3", fileURI(path, "rwc"))
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	if err := copyDB(ctx, dest, db); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// Restore replaces the contents of db with the backup at path, after
+// checking that the backup is intact and that this code can migrate it.
+// A backup without a schema version is refused; see RestoreUnversioned.
+func Restore(ctx context.Context, db *sql.DB, path string) error {
+	return restore(ctx, db, path, false)
+}
+
+// RestoreUnversioned is Restore for a backup that may have no schema
+// version, such as one of a database the persons package never opened.
+// It is migrated when next opened like any unversioned database.
+func RestoreUnversioned(ctx context.Context, db *sql.DB, path string) error {
+	return restore(ctx, db, path, true)
+}
+
+func restore(ctx context.Context, db *sql.DB, path string, unversioned bool) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	src, err := sql.Open("sqlite3", fileURI(path, "ro"))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if problems, err := Check(ctx, src); err != nil {
+		return err
+	} else if len(problems) > 0 {
+		return fmt.Errorf("dbadmin: backup %s is damaged: %v", path, problems)
+	}
+	v, err := Version(ctx, src)
+	if err != nil {
+		return err
+	}
+	if v > SchemaVersion {
+		return fmt.Errorf("dbadmin: backup %s has schema version %d, newer than %d", path, v, SchemaVersion)
+	}
+	if v < 1 && !unversioned {
+		return fmt.Errorf("dbadmin: backup %s has no schema version; restore it as unversioned if it is a database of this app", path)
+	}
+	return copyDB(ctx, db, src)
+}
+
+// fileURI returns the SQLite URI opening path in mode, escaping any
+// ? or # in it that would otherwise start the query or fragment.
+func fileURI(path, mode string) string {
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=" + mode}
+	return u.String()
+}
+
+// copyDB copies the main database of src over that of dest.
+func copyDB(ctx context.Context, dest, src *sql.DB) error {
+	dc, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+	sc, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	return dc.Raw(func(d interface{}) error {
+		return sc.Raw(func(s interface{}) error {
+			dconn, ok1 := d.(*sqlite3.SQLiteConn)
+			sconn, ok2 := s.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("dbadmin: not a sqlite3 database")
+			}
+			b, err := dconn.Backup("main", sconn, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := b.Step(stepPages)
+				if err != nil {
+					b.Finish()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+				select {
+				case <-ctx.Done():
+					b.Finish()
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+	})
+}
+
+// Version returns the schema version recorded in db.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&v)
+	return v, err
+}
+
+// Check runs SQLite's integrity and foreign key checks and returns the
+// problems they report; none means the database is sound.
+func Check(ctx context.Context, db *sql.DB) ([]string, error) {
+	var problems []string
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fk int
+		if err := rows.Scan(&table, &rowid, &parent, &fk); err != nil {
+			return nil, err
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s", table, rowid.Int64, parent))
+	}
+	return problems, rows.Err()
+}
+
+// Vacuum rebuilds db to reclaim free space.
+func Vacuum(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `VACUUM`)
+	return err
+}
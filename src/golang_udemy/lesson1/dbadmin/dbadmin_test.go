@@ -0,0 +1,171 @@
+package dbadmin
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openFile(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openFile(t, filepath.Join(dir, "live.sql"))
+	db.Exec(`CREATE TABLE persons(name STRING, age INT); INSERT INTO persons VALUES('Nancy', 30); PRAGMA user_version = 1`)
+
+	backup := filepath.Join(dir, "backup.sql")
+	if err := Backup(ctx, db, backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := Backup(ctx, db, backup); err == nil {
+		t.Error("Expected an existing backup not to be overwritten")
+	}
+	db.Exec(`DELETE FROM persons`)
+	if err := Restore(ctx, db, backup); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM persons`).Scan(&n)
+	if n != 1 {
+		t.Error("Expected Nancy to be restored, got", n)
+	}
+
+	newer := filepath.Join(dir, "newer.sql")
+	openFile(t, newer).Exec(`PRAGMA user_version = 99`)
+	if err := Restore(ctx, db, newer); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Error("Expected a newer schema to be refused, got", err)
+	}
+	unversioned := filepath.Join(dir, "unversioned.sql")
+	openFile(t, unversioned).Exec(`CREATE TABLE persons(name TEXT)`)
+	if err := Restore(ctx, db, unversioned); err == nil || !strings.Contains(err.Error(), "no schema version") {
+		t.Error("Expected a database without a version to be refused, got", err)
+	}
+
+	odd := filepath.Join(dir, "a?b#c.sql")
+	if err := Backup(ctx, db, odd); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(ctx, db, odd); err != nil {
+		t.Error("Expected a path with ? and # to restore, got", err)
+	}
+	if err := RestoreUnversioned(ctx, db, unversioned); err != nil {
+		t.Error("Expected a database without a version to restore when asked, got", err)
+	}
+	if v, _ := Version(ctx, db); v != 0 {
+		t.Error("Expected the restored database to be unversioned, got", v)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db := openFile(t, filepath.Join(t.TempDir(), "live.sql"))
+	db.Exec(`CREATE TABLE a(id INTEGER PRIMARY KEY); CREATE TABLE b(a_id INT REFERENCES a(id)); INSERT INTO b VALUES(7)`)
+	problems, err := Check(context.Background(), db)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0], "missing a") {
+		t.Error("Expected a dangling reference, got", problems, err)
+	}
+	if err := Vacuum(context.Background(), db); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < 5; d++ {
+		at := now.Add(-time.Duration(d) * 24 * time.Hour)
+		os.WriteFile(filepath.Join(dir, backupPrefix+at.Format(backupLayout)+backupSuffix), nil, 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644)
+
+	removed, err := Prune(dir, Retention{Keep: 4, MaxAge: 60 * time.Hour}, now)
+	if err != nil || len(removed) != 2 {
+		t.Fatal("Expected 2 backups removed, got", removed, err)
+	}
+	left, _ := os.ReadDir(dir)
+	if len(left) != 4 {
+		t.Error("Expected 3 backups and the notes left, got", len(left))
+	}
+	// the newest backup survives any policy
+	Prune(dir, Retention{MaxAge: time.Nanosecond}, now.Add(time.Hour))
+	if left, _ = os.ReadDir(dir); len(left) != 2 {
+		t.Error("Expected the newest backup and the notes, got", len(left))
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openFile(t, filepath.Join(dir, "live.sql"))
+	_, err := db.Exec(`
+		CREATE TABLE symbols(symbol STRING PRIMARY KEY, precision INT);
+		CREATE TABLE notes(body TEXT, data BLOB, score REAL);
+		CREATE INDEX notes_score ON notes(score);
+		CREATE TRIGGER notes_ai AFTER INSERT ON notes BEGIN UPDATE notes SET score = score + 1 WHERE rowid = new.rowid; END;
+		CREATE VIRTUAL TABLE notes_fts USING fts4(body);
+		CREATE TRIGGER notes_fts_ai AFTER INSERT ON notes BEGIN INSERT INTO notes_fts(body) VALUES(new.body); END;
+		CREATE TABLE notes_fts_archive(body TEXT);
+		INSERT INTO notes_fts_archive VALUES('kept');
+		INSERT INTO symbols VALUES('ETH-USD', 2), ('BTC-USD', 2);
+		INSERT INTO notes VALUES('it''s', x'00ff', 0.1), (NULL, NULL, 1.0/3);
+		PRAGMA user_version = 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second strings.Builder
+	if err := Dump(ctx, db, &first); err != nil {
+		t.Fatal(err)
+	}
+	dump := first.String()
+	if strings.Index(dump, `'BTC-USD'`) > strings.Index(dump, `'ETH-USD'`) {
+		t.Error("Expected rows in primary key order\n", dump)
+	}
+	if strings.Contains(dump, "notes_fts(") || strings.Contains(dump, "notes_fts_content") || strings.Contains(dump, "notes_fts_ai") ||
+		!strings.Contains(dump, `VALUES('it''s', X'00FF', 1.1)`) {
+		t.Error("Unexpected dump\n", dump)
+	}
+	if !strings.Contains(dump, `VALUES('kept')`) {
+		t.Error("Expected a real table named like a shadow table to be kept\n", dump)
+	}
+	if strings.Index(dump, "CREATE TRIGGER") < strings.Index(dump, "INSERT INTO \"notes\"") {
+		t.Error("Expected triggers after the data\n", dump)
+	}
+
+	copied := openFile(t, filepath.Join(dir, "copy.sql"))
+	if err := Load(ctx, copied, strings.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Dump(ctx, copied, &second); err != nil {
+		t.Fatal(err)
+	}
+	if second.String() != dump {
+		t.Error("Expected the loaded copy to dump the same, got\n", second.String())
+	}
+	if err := Load(ctx, copied, strings.NewReader(dump)); err == nil {
+		t.Error("Expected loading into a non-empty database to fail")
+	}
+
+	broken := openFile(t, filepath.Join(dir, "broken.sql"))
+	if err := Load(ctx, broken, strings.NewReader("CREATE TABLE a(x);\nINSERT INTO nope VALUES(1);")); err == nil {
+		t.Error("Expected a bad dump to fail")
+	}
+	var n int
+	broken.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&n)
+	if n != 0 {
+		t.Error("Expected a failed load to leave nothing behind, got", n, "objects")
+	}
+}
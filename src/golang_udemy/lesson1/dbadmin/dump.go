@@ -0,0 +1,204 @@
+package dbadmin
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// shadows lists the suffixes of the shadow tables each virtual table
+// module keeps next to a table it creates.
+var shadows = map[string][]string{
+	"fts5": {"data", "idx", "content", "docsize", "config"},
+	"fts4": {"content", "segments", "segdir", "docsize", "stat"},
+	"fts3": {"content", "segments", "segdir", "docsize", "stat"},
+}
+
+var usingRE = regexp.MustCompile(`(?i)\bUSING\s+(\w+)`)
+
+// module returns the lower-cased module name of a CREATE VIRTUAL TABLE
+// statement.
+func module(sql string) string {
+	m := usingRE.FindStringSubmatch(sql)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+type object struct {
+	typ, name, table, sql string
+}
+
+// Dump writes db as plain SQL: the schema version, then each table,
+// sorted by name, with its rows as INSERTs in primary key order, then
+// indexes, views and triggers. The same database always dumps to the
+// same text.
+//
+// FTS5 and other virtual tables, their shadow tables and the triggers
+// that feed them are left out; the code that creates them rebuilds
+// them when it next opens the database.
+func Dump(ctx context.Context, db *sql.DB, w io.Writer) error {
+	rows, err := db.QueryContext(ctx, `SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, name`)
+	if err != nil {
+		return err
+	}
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.typ, &o.name, &o.table, &o.sql); err != nil {
+			rows.Close()
+			return err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	derived := map[string]bool{}
+	for _, o := range objects {
+		if o.typ != "table" || !strings.HasPrefix(strings.ToUpper(o.sql), "CREATE VIRTUAL TABLE") {
+			continue
+		}
+		derived[o.name] = true
+		for _, suffix := range shadows[module(o.sql)] {
+			derived[o.name+"_"+suffix] = true
+		}
+	}
+	skip := func(o object) bool {
+		if o.typ != "trigger" {
+			return derived[o.name]
+		}
+		for name := range derived {
+			if regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`).MatchString(o.sql) {
+				return true
+			}
+		}
+		return false
+	}
+
+	v, err := Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- lesson1 database dump; load with 'app db load'\nPRAGMA user_version = %d;\n", v)
+	for _, o := range objects {
+		if skip(o) {
+			continue
+		}
+		fmt.Fprintf(bw, "\n%s;\n", o.sql)
+		if o.typ == "table" {
+			if err := dumpRows(ctx, db, bw, o.name); err != nil {
+				return err
+			}
+		}
+	}
+	if err := dumpRows(ctx, db, bw, "sqlite_sequence"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// dumpRows writes the rows of table as INSERTs in primary key order, or
+// rowid order for tables without one.
+func dumpRows(ctx context.Context, db *sql.DB, w io.Writer, table string) error {
+	var cols, keys []string
+	rows, err := db.QueryContext(ctx, `SELECT name, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return err
+	}
+	pks := map[int]string{}
+	for rows.Next() {
+		var name string
+		var pk int
+		if err := rows.Scan(&name, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		cols = append(cols, name)
+		if pk > 0 {
+			pks[pk] = ident(name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	for i := 1; i <= len(pks); i++ {
+		keys = append(keys, pks[i])
+	}
+	if len(keys) == 0 {
+		keys = []string{"rowid"}
+	}
+	if table == "sqlite_sequence" {
+		keys = []string{"name"}
+		fmt.Fprintf(w, "\nDELETE FROM sqlite_sequence;\n")
+	}
+
+	quoted := make([]string, len(cols))
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = ident(c)
+		quoted[i] = "quote(" + ident(c) + ")"
+	}
+	rows, err = db.QueryContext(ctx, `SELECT `+strings.Join(quoted, ` || ', ' || `)+` FROM `+ident(table)+
+		` ORDER BY `+strings.Join(keys, ", "))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	prefix := "INSERT INTO " + ident(table) + "(" + strings.Join(names, ", ") + ") VALUES("
+	for rows.Next() {
+		var values string
+		if err := rows.Scan(&values); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s%s);\n", prefix, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ident quotes an SQL identifier.
+func ident(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// Load runs a dump made by Dump against db inside one transaction, so
+// either all of it is loaded or none. db must not have any tables yet.
+func Load(ctx context.Context, db *sql.DB, r io.Reader) error {
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE name NOT LIKE 'sqlite_%'`).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("dbadmin: database is not empty")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
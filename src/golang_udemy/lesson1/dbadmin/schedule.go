@@ -0,0 +1,102 @@
+package dbadmin
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Retention says which scheduled backups to keep. The newest backup is
+// always kept.
+type Retention struct {
+	// Keep is how many of the newest backups to keep; 0 keeps all.
+	Keep int
+	// MaxAge removes backups older than this; 0 keeps them forever.
+	MaxAge time.Duration
+}
+
+const (
+	backupPrefix = "backup-"
+	backupSuffix = ".sqlite"
+	backupLayout = "20060102T150405Z"
+)
+
+// Schedule backs db up into dir every interval until ctx is done,
+// pruning old backups by r after each one. Failures are logged and
+// retried at the next tick.
+func Schedule(ctx context.Context, db *sql.DB, dir string, every time.Duration, r Retention) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		if path, err := BackupTo(ctx, db, dir, time.Now()); err != nil {
+			log.Printf("backup: %v", err)
+		} else {
+			log.Printf("backup: wrote %s", path)
+		}
+		if removed, err := Prune(dir, r, time.Now()); err != nil {
+			log.Printf("backup: prune: %v", err)
+		} else if len(removed) > 0 {
+			log.Printf("backup: removed %s", strings.Join(removed, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// BackupTo backs db up into dir under a name carrying now.
+func BackupTo(ctx context.Context, db *sql.DB, dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupLayout)+backupSuffix)
+	return path, Backup(ctx, db, path)
+}
+
+// Prune removes the backups in dir that r no longer keeps, as of now,
+// and returns their paths.
+func Prune(dir string, r Retention, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), backupPrefix)
+		stamp, ok2 := strings.CutSuffix(stamp, backupSuffix)
+		if !ok || !ok2 {
+			continue
+		}
+		at, err := time.Parse(backupLayout, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(dir, e.Name()), at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+
+	var removed []string
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		if (r.Keep > 0 && i >= r.Keep) || (r.MaxAge > 0 && now.Sub(b.at) > r.MaxAge) {
+			if err := os.Remove(b.path); err != nil {
+				return removed, err
+			}
+			removed = append(removed, b.path)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,218 @@
+/*
+demographics analyses the ages of persons: summaries with mean, median
+and percentiles, and histograms, either over everyone or grouped by a
+column of the persons table.
+
+Counts, means and histogram bins are computed by SQLite. Medians and
+percentiles, which SQLite lacks, are computed from the ages with
+mylib.
+*/
+package demographics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/persons"
+)
+
+var (
+	ErrBadGroup = errors.New("demographics: cannot group by that")
+	ErrBadEdges = errors.New("demographics: bad bin edges")
+)
+
+// MaxBins is the most bins a histogram may have.
+const MaxBins = 1000
+
+// Summary describes the ages of one group.
+type Summary struct {
+	Group       string             `json:"group,omitempty"`
+	Count       int                `json:"count"`
+	Min         int                `json:"min"`
+	Max         int                `json:"max"`
+	Mean        float64            `json:"mean"`
+	Median      float64            `json:"median"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// Bin counts the ages from Lo up to but not including Hi. The last bin
+// of a histogram has no upper bound and a nil Hi.
+type Bin struct {
+	Lo    int  `json:"lo"`
+	Hi    *int `json:"hi"`
+	Count int  `json:"count"`
+}
+
+// Histogram is the age distribution of one group.
+type Histogram struct {
+	Group string `json:"group,omitempty"`
+	Bins  []Bin  `json:"bins"`
+}
+
+// Width returns bin edges every width years from 0 up to upTo, or as
+// many of them as MaxBins allows.
+func Width(width, upTo int) []int {
+	var edges []int
+	for e := 0; e <= upTo && len(edges) < MaxBins; e += width {
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// Service runs the analyses over the persons table.
+type Service struct {
+	db *sql.DB
+}
+
+// New makes sure the persons table is current.
+func New(db *sql.DB) (*Service, error) {
+	if _, err := persons.NewRepository(db); err != nil {
+		return nil, err
+	}
+	return &Service{db}, nil
+}
+
+// groupColumn checks that by names a column of persons and returns the
+// SQL expression to group on.
+func (s *Service) groupColumn(by string) (string, error) {
+	if by == "" {
+		return `''`, nil
+	}
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('persons') WHERE name = ? AND name NOT IN ('id', 'deleted')`,
+		strings.ToLower(by)).Scan(&n)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", fmt.Errorf("%w: %q", ErrBadGroup, by)
+	}
+	return `CAST(` + strings.ToLower(by) + ` AS TEXT)`, nil
+}
+
+// Summarize describes the ages of live persons, grouped by the column
+// groupBy unless it is empty. percentiles are between 0 and 100 and are
+// reported under keys such as "p90".
+func (s *Service) Summarize(groupBy string, percentiles ...float64) ([]Summary, error) {
+	col, err := s.groupColumn(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT ` + col + `, COUNT(*), MIN(age), MAX(age), AVG(age) FROM persons
+		WHERE deleted = 0 AND age IS NOT NULL GROUP BY 1 ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	var list []Summary
+	for rows.Next() {
+		var sum Summary
+		var g sql.NullString
+		if err := rows.Scan(&g, &sum.Count, &sum.Min, &sum.Max, &sum.Mean); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sum.Group = g.String
+		list = append(list, sum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ages, err := s.ages(col)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		a := ages[list[i].Group]
+		list[i].Median = mylib.Median(a)
+		for _, p := range percentiles {
+			if list[i].Percentiles == nil {
+				list[i].Percentiles = map[string]float64{}
+			}
+			list[i].Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = mylib.Percentile(a, p)
+		}
+	}
+	return list, nil
+}
+
+// ages returns the ages of live persons by group.
+func (s *Service) ages(col string) (map[string][]int, error) {
+	rows, err := s.db.Query(`SELECT ` + col + `, age FROM persons WHERE deleted = 0 AND age IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ages := map[string][]int{}
+	for rows.Next() {
+		var g sql.NullString
+		var age int
+		if err := rows.Scan(&g, &age); err != nil {
+			return nil, err
+		}
+		ages[g.String] = append(ages[g.String], age)
+	}
+	return ages, rows.Err()
+}
+
+// Histogram counts the ages of live persons between edges, grouped by
+// the column groupBy unless it is empty. Ages below the first edge are
+// left out; the last bin is open ended. There may be up to MaxBins
+// edges.
+func (s *Service) Histogram(groupBy string, edges []int) ([]Histogram, error) {
+	for i := 1; i < len(edges); i++ {
+		if edges[i] <= edges[i-1] {
+			return nil, fmt.Errorf("%w: edges must increase", ErrBadEdges)
+		}
+	}
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("%w: none given", ErrBadEdges)
+	}
+	if len(edges) > MaxBins {
+		return nil, fmt.Errorf("%w: more than %d", ErrBadEdges, MaxBins)
+	}
+	col, err := s.groupColumn(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	var bin strings.Builder
+	bin.WriteString("CASE")
+	for i := len(edges) - 1; i >= 0; i-- {
+		fmt.Fprintf(&bin, " WHEN age >= %d THEN %d", edges[i], i)
+	}
+	bin.WriteString(" END")
+	rows, err := s.db.Query(`SELECT `+col+`, `+bin.String()+` AS bin, COUNT(*) FROM persons
+		WHERE deleted = 0 AND age >= ? GROUP BY 1, 2 ORDER BY 1, 2`, edges[0])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Histogram
+	for rows.Next() {
+		var g sql.NullString
+		var i, n int
+		if err := rows.Scan(&g, &i, &n); err != nil {
+			return nil, err
+		}
+		if len(list) == 0 || list[len(list)-1].Group != g.String {
+			list = append(list, Histogram{Group: g.String, Bins: bins(edges)})
+		}
+		list[len(list)-1].Bins[i].Count += n
+	}
+	return list, rows.Err()
+}
+
+func bins(edges []int) []Bin {
+	b := make([]Bin, len(edges))
+	for i, e := range edges {
+		b[i].Lo = e
+		if i+1 < len(edges) {
+			b[i].Hi = &edges[i+1]
+		}
+	}
+	return b
+}
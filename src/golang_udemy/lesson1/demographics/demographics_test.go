@@ -0,0 +1,115 @@
+package demographics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/persons"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newService(t *testing.T) *Service {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	repo, err := persons.NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []mylib.Person{{Name: "Ann", Age: 10}, {Name: "Ann", Age: 20}, {Name: "Bob", Age: 30}, {Name: "Cid", Age: 40}, {Name: "Dee", Age: 70}} {
+		repo.Create(p, "test")
+	}
+	gone, _ := repo.Create(mylib.Person{Name: "Eve", Age: 99}, "test")
+	repo.Delete(gone.ID, "test")
+	s, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSummarize(t *testing.T) {
+	s := newService(t)
+	list, err := s.Summarize("", 25, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Count != 5 || list[0].Mean != 34 || list[0].Median != 30 || list[0].Max != 70 {
+		t.Fatal("Unexpected summary", list)
+	}
+	if list[0].Percentiles["p25"] != 20 || list[0].Percentiles["p90"] != 58 {
+		t.Error("Unexpected percentiles", list[0].Percentiles)
+	}
+
+	list, _ = s.Summarize("name")
+	if len(list) != 4 || list[0].Group != "Ann" || list[0].Median != 15 {
+		t.Error("Expected Ann's median of 15 first of 4 groups, got", list)
+	}
+	if _, err := s.Summarize("password_hash; DROP TABLE persons"); !errors.Is(err, ErrBadGroup) {
+		t.Error("Expected bad group, got", err)
+	}
+}
+
+func TestNullGroup(t *testing.T) {
+	s := newService(t)
+	// rows migrated from the old table may have no name
+	if _, err := s.db.Exec(`INSERT INTO persons(name, age) VALUES(NULL, 50)`); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Summarize("name")
+	if err != nil || len(list) != 5 || list[0].Group != "" || list[0].Count != 1 {
+		t.Error("Expected the unnamed person in a group of its own, got", list, err)
+	}
+	if _, err := s.Histogram("name", []int{0, 50}); err != nil {
+		t.Error("Expected a histogram by name, got", err)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	s := newService(t)
+	list, err := s.Histogram("", []int{18, 40, 65})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ lo, hi, count int }{{18, 40, 2}, {40, 65, 1}, {65, -1, 1}}
+	if len(list) != 1 || len(list[0].Bins) != 3 {
+		t.Fatal("Unexpected histogram", list)
+	}
+	for i, w := range want {
+		b := list[0].Bins[i]
+		if b.Lo != w.lo || b.Count != w.count || (b.Hi == nil) != (w.hi < 0) || b.Hi != nil && *b.Hi != w.hi {
+			t.Errorf("Expected bin %d to be %v, got %+v", i, w, b)
+		}
+	}
+	if _, err := s.Histogram("", []int{10, 10}); err == nil {
+		t.Error("Expected repeated edges to fail")
+	}
+
+	w := httptest.NewRecorder()
+	NewHandler(s).ServeHTTP(w, httptest.NewRequest("GET", "/demographics/histogram?width=50", nil))
+	if !strings.Contains(w.Body.String(), `{"lo":0,"hi":50,"count":4}`) {
+		t.Error("Unexpected response", w.Body)
+	}
+	w = httptest.NewRecorder()
+	NewHandler(s).ServeHTTP(w, httptest.NewRequest("GET", "/demographics/histogram?edges=-10,0", nil))
+	if body := w.Body.String(); !strings.Contains(body, `{"lo":-10,"hi":0,"count":0}`) || !strings.Contains(body, `"hi":null`) {
+		t.Error("Expected an upper edge of 0 and an open last bin, got", body)
+	}
+	for _, q := range []string{"width=1&max=1000", "width=1&max=1000000000", "max=-1", "edges=" + strings.Repeat("1,", MaxBins) + "1"} {
+		w = httptest.NewRecorder()
+		NewHandler(s).ServeHTTP(w, httptest.NewRequest("GET", "/demographics/histogram?"+q, nil))
+		if w.Code != 400 {
+			t.Error("Expected 400 for", q[:min(len(q), 30)], "got", w.Code)
+		}
+	}
+	if w := Width(1, 5000); len(w) != MaxBins {
+		t.Error("Expected Width to stop at MaxBins, got", len(w))
+	}
+}
@@ -0,0 +1,99 @@
+package demographics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang_udemy/lesson1/reply"
+)
+
+// NewHandler serves the analyses:
+//
+//	GET /demographics/summary?group_by=&percentiles=25,75,90
+//	GET /demographics/histogram?group_by=&width=10&max=100
+//	GET /demographics/histogram?group_by=&edges=0,18,30,50,65
+func NewHandler(s *Service) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /demographics/summary", func(w http.ResponseWriter, r *http.Request) {
+		var ps []float64
+		for _, f := range split(r.URL.Query().Get("percentiles")) {
+			p, err := strconv.ParseFloat(f, 64)
+			if err != nil || p < 0 || p > 100 {
+				http.Error(w, "bad percentile "+f, http.StatusBadRequest)
+				return
+			}
+			ps = append(ps, p)
+		}
+		list, err := s.Summarize(r.URL.Query().Get("group_by"), ps...)
+		reply.JSON(w, list, err, codes...)
+	})
+	mux.HandleFunc("GET /demographics/histogram", func(w http.ResponseWriter, r *http.Request) {
+		edges, err := Edges(r.URL.Query().Get("edges"), r.URL.Query().Get("width"), r.URL.Query().Get("max"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list, err := s.Histogram(r.URL.Query().Get("group_by"), edges)
+		reply.JSON(w, list, err, codes...)
+	})
+	return mux
+}
+
+// Edges parses bin edges given either as a comma separated list or as
+// a width and an upper limit, which defaults to 100. Either way there
+// may be at most MaxBins of them.
+func Edges(list, width, upTo string) ([]int, error) {
+	if list != "" {
+		fields := split(list)
+		if len(fields) > MaxBins {
+			return nil, fmt.Errorf("more than %d bin edges", MaxBins)
+		}
+		var edges []int
+		for _, f := range fields {
+			e, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, errors.New("bad bin edge " + f)
+			}
+			edges = append(edges, e)
+		}
+		return edges, nil
+	}
+	if width == "" {
+		width = "10"
+	}
+	if upTo == "" {
+		upTo = "100"
+	}
+	wd, err := strconv.Atoi(width)
+	if err != nil || wd < 1 {
+		return nil, errors.New("bad bin width " + width)
+	}
+	m, err := strconv.Atoi(upTo)
+	if err != nil || m < 0 {
+		return nil, errors.New("bad bin limit " + upTo)
+	}
+	if m/wd >= MaxBins {
+		return nil, fmt.Errorf("more than %d bins", MaxBins)
+	}
+	return Width(wd, m), nil
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	f := strings.Split(s, ",")
+	for i := range f {
+		f[i] = strings.TrimSpace(f[i])
+	}
+	return f
+}
+
+// codes are the statuses of the errors the handlers return.
+var codes = []reply.Code{
+	{Err: ErrBadGroup, Status: http.StatusBadRequest},
+	{Err: ErrBadEdges, Status: http.StatusBadRequest},
+}
@@ -0,0 +1,295 @@
+package dsl
+
+import (
+	"math"
+	"strings"
+
+	"golang_udemy/lesson1/patterns"
+
+	quote "github.com/markcheno/go-quote"
+	talib "github.com/markcheno/go-talib"
+)
+
+// Type is the type of an expression.
+type Type int
+
+const (
+	// Number is a price or indicator series, constants included.
+	Number Type = iota
+	// Bool is a per-bar condition.
+	Bool
+)
+
+func (t Type) String() string {
+	if t == Bool {
+		return "bool"
+	}
+	return "number"
+}
+
+// value is a type checked expression ready to be evaluated.
+// Bars still warming up evaluate to NaN, which makes every
+// comparison and crossover on them false.
+type value struct {
+	typ     Type
+	num     func(q quote.Quote) []float64
+	boolean func(q quote.Quote) []bool
+}
+
+var fields = map[string]func(q quote.Quote) []float64{
+	"open":   func(q quote.Quote) []float64 { return q.Open },
+	"high":   func(q quote.Quote) []float64 { return q.High },
+	"low":    func(q quote.Quote) []float64 { return q.Low },
+	"close":  func(q quote.Quote) []float64 { return q.Close },
+	"volume": func(q quote.Quote) []float64 { return q.Volume },
+}
+
+// indicator is a talib function of one series and a bar count,
+// together with the number of leading bars it leaves unset and the
+// fewest bars it computes anything over; talib returns zeros below that.
+type indicator struct {
+	calc     func(in []float64, n int) []float64
+	lookback func(n int) int
+	min      int
+}
+
+var indicators = map[string]indicator{
+	"sma": {talib.Sma, func(n int) int { return n - 1 }, 1},
+	"ema": {talib.Ema, func(n int) int { return n - 1 }, 1},
+	"rsi": {talib.Rsi, func(n int) int { return n }, 2},
+}
+
+type checker struct {
+	src string
+}
+
+func (c *checker) errorf(n node, format string, args ...interface{}) error {
+	return errorAt(c.src, n.pos(), format, args...)
+}
+
+func (c *checker) compile(n node) (value, error) {
+	switch n := n.(type) {
+	case numberNode:
+		return value{typ: Number, num: func(q quote.Quote) []float64 {
+			return fill(len(q.Close), n.value)
+		}}, nil
+	case identNode:
+		f, ok := fields[n.name]
+		if !ok {
+			return value{}, c.errorf(n, "unknown series %q", n.name)
+		}
+		return value{typ: Number, num: f}, nil
+	case unaryNode:
+		x, err := c.compile(n.x)
+		if err != nil {
+			return value{}, err
+		}
+		if n.op == "not" {
+			if err := c.want(n.x, x, Bool); err != nil {
+				return value{}, err
+			}
+			return value{typ: Bool, boolean: func(q quote.Quote) []bool {
+				b := x.boolean(q)
+				out := make([]bool, len(b))
+				for i := range b {
+					out[i] = !b[i]
+				}
+				return out
+			}}, nil
+		}
+		if err := c.want(n.x, x, Number); err != nil {
+			return value{}, err
+		}
+		return value{typ: Number, num: func(q quote.Quote) []float64 {
+			return mapNum(fill(len(q.Close), 0), x.num(q), arith["-"])
+		}}, nil
+	case binaryNode:
+		return c.binary(n)
+	case callNode:
+		return c.call(n)
+	}
+	panic("dsl: unknown node")
+}
+
+func (c *checker) want(n node, v value, t Type) error {
+	if v.typ != t {
+		return c.errorf(n, "expected %s, found %s", t, v.typ)
+	}
+	return nil
+}
+
+func (c *checker) binary(n binaryNode) (value, error) {
+	x, err := c.compile(n.x)
+	if err != nil {
+		return value{}, err
+	}
+	y, err := c.compile(n.y)
+	if err != nil {
+		return value{}, err
+	}
+	operand := Number
+	if n.op == "and" || n.op == "or" {
+		operand = Bool
+	}
+	if err := c.want(n.x, x, operand); err != nil {
+		return value{}, err
+	}
+	if err := c.want(n.y, y, operand); err != nil {
+		return value{}, err
+	}
+	switch n.op {
+	case "and", "or":
+		and := n.op == "and"
+		return value{typ: Bool, boolean: func(q quote.Quote) []bool {
+			a, b := x.boolean(q), y.boolean(q)
+			out := make([]bool, len(a))
+			for i := range a {
+				if and {
+					out[i] = a[i] && b[i]
+				} else {
+					out[i] = a[i] || b[i]
+				}
+			}
+			return out
+		}}, nil
+	case "+", "-", "*", "/":
+		op := arith[n.op]
+		return value{typ: Number, num: func(q quote.Quote) []float64 {
+			return mapNum(x.num(q), y.num(q), op)
+		}}, nil
+	}
+	cmp := compare[n.op]
+	return value{typ: Bool, boolean: func(q quote.Quote) []bool {
+		a, b := x.num(q), y.num(q)
+		out := make([]bool, len(a))
+		for i := range a {
+			out[i] = !math.IsNaN(a[i]) && !math.IsNaN(b[i]) && cmp(a[i], b[i])
+		}
+		return out
+	}}, nil
+}
+
+var arith = map[string]func(a, b float64) float64{
+	"+": func(a, b float64) float64 { return a + b },
+	"-": func(a, b float64) float64 { return a - b },
+	"*": func(a, b float64) float64 { return a * b },
+	"/": func(a, b float64) float64 { return a / b },
+}
+
+var compare = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+func (c *checker) call(n callNode) (value, error) {
+	if n.name == "crossover" || n.name == "crossunder" {
+		if len(n.args) != 2 {
+			return value{}, c.errorf(n, "%s takes 2 arguments, found %d", n.name, len(n.args))
+		}
+		x, err := c.compile(n.args[0])
+		if err != nil {
+			return value{}, err
+		}
+		y, err := c.compile(n.args[1])
+		if err != nil {
+			return value{}, err
+		}
+		if err := c.want(n.args[0], x, Number); err != nil {
+			return value{}, err
+		}
+		if err := c.want(n.args[1], y, Number); err != nil {
+			return value{}, err
+		}
+		over := n.name == "crossover"
+		return value{typ: Bool, boolean: func(q quote.Quote) []bool {
+			return crosses(x.num(q), y.num(q), over)
+		}}, nil
+	}
+
+	if p := patterns.Pattern(strings.TrimPrefix(n.name, "cdl_")); strings.HasPrefix(n.name, "cdl_") && patterns.Known(p) {
+		if len(n.args) != 0 {
+			return value{}, c.errorf(n, "%s takes no arguments, found %d", n.name, len(n.args))
+		}
+		return value{typ: Number, num: func(q quote.Quote) []float64 {
+			return patterns.Series(q, p, patterns.DefaultOptions())
+		}}, nil
+	}
+
+	ind, ok := indicators[n.name]
+	if !ok {
+		return value{}, c.errorf(n, "unknown function %q", n.name)
+	}
+	if len(n.args) != 2 {
+		return value{}, c.errorf(n, "%s takes 2 arguments, found %d", n.name, len(n.args))
+	}
+	x, err := c.compile(n.args[0])
+	if err != nil {
+		return value{}, err
+	}
+	if err := c.want(n.args[0], x, Number); err != nil {
+		return value{}, err
+	}
+	lit, ok := n.args[1].(numberNode)
+	if !ok || lit.value < 1 || lit.value != math.Trunc(lit.value) {
+		return value{}, c.errorf(n.args[1], "%s needs a whole number of bars", n.name)
+	}
+	bars := int(lit.value)
+	if bars < ind.min {
+		return value{}, c.errorf(n.args[1], "%s needs at least %d bars, found %d", n.name, ind.min, bars)
+	}
+	return value{typ: Number, num: func(q quote.Quote) []float64 {
+		return apply(x.num(q), bars, ind)
+	}}, nil
+}
+
+// apply runs ind on the defined tail of in and marks the warm-up bars NaN.
+func apply(in []float64, n int, ind indicator) []float64 {
+	start := 0
+	for start < len(in) && math.IsNaN(in[start]) {
+		start++
+	}
+	out := fill(len(in), math.NaN())
+	if len(in)-start <= ind.lookback(n) {
+		return out
+	}
+	res := ind.calc(in[start:], n)
+	for i := ind.lookback(n); i < len(res); i++ {
+		out[start+i] = res[i]
+	}
+	return out
+}
+
+func crosses(a, b []float64, over bool) []bool {
+	out := make([]bool, len(a))
+	for i := 1; i < len(a); i++ {
+		if math.IsNaN(a[i-1]) || math.IsNaN(b[i-1]) || math.IsNaN(a[i]) || math.IsNaN(b[i]) {
+			continue
+		}
+		if over {
+			out[i] = a[i-1] <= b[i-1] && a[i] > b[i]
+		} else {
+			out[i] = a[i-1] >= b[i-1] && a[i] < b[i]
+		}
+	}
+	return out
+}
+
+func fill(n int, v float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func mapNum(a, b []float64, f func(a, b float64) float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = f(a[i], b[i])
+	}
+	return out
+}
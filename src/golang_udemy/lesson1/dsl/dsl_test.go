@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/alert"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+var _ alert.Condition = (*Condition)(nil)
+
+func series(closes ...float64) quote.Quote {
+	q := quote.NewQuote("BTC-USD", len(closes))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range closes {
+		q.Date[i] = start.AddDate(0, 0, i)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i] = c, c, c, c
+	}
+	return q
+}
+
+func TestStrategy(t *testing.T) {
+	s, err := Compile(`
+		buy when crossover(close, sma(close, 3)) and rsi(close, 2) < 101
+		sell when crossunder(close, sma(close, 3))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Signals(series(10, 9, 8, 7, 9, 10, 11, 8))
+	want := []Signal{Hold, Hold, Hold, Hold, Buy, Hold, Hold, Sell}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Error("Expected", want, "got", got)
+			break
+		}
+	}
+}
+
+func TestCondition(t *testing.T) {
+	c, err := CompileCondition("-close + 2 * high > -5 and not (close >= 6)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.Check(series(7, 5)); !ok {
+		t.Error("Expected condition to hold")
+	}
+	if ok, _ := c.Check(series(5, 7)); ok {
+		t.Error("Expected condition not to hold")
+	}
+}
+
+func TestPatternFunction(t *testing.T) {
+	c, err := CompileCondition("cdl_engulfing() > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := series(9, 10)
+	q.Open[0], q.Open[1] = 10, 8.8
+	q.High[0], q.High[1] = 10.5, 11
+	q.Low[0], q.Low[1] = 8.5, 8.5
+	if ok, _ := c.Check(q); !ok {
+		t.Error("Expected bullish engulfing")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"buy when close":                      "1:10: expected bool, found number",
+		"buy when foo(close, 3) > 1":          "1:10: unknown function \"foo\"",
+		"buy when sma(close, 2.5) > 1":        "1:21: sma needs a whole number of bars",
+		"buy when rsi(close, 1) < 70":         "1:21: rsi needs at least 2 bars, found 1",
+		"buy when close > 1\nsell close < 1":  "2:6: expected \"when\", found \"close\"",
+		"buy when close > 1 and (close < 2":   "1:34: expected \")\", found end of input",
+		"buy when crossover(close) or 1 > 2":  "1:10: crossover takes 2 arguments, found 1",
+		"buy when close > 1 and volume $ 2":   "1:31: unexpected '$'",
+		"buy when cdl_doji(close) > 0":        "1:10: cdl_doji takes no arguments, found 1",
+		"buy when close > 1 and close < open": "",
+	} {
+		_, err := Compile(src)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != want {
+			t.Errorf("%q: Expected %q, got %q", src, want, got)
+		}
+	}
+}
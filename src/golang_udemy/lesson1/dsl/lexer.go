@@ -0,0 +1,120 @@
+/*
+dsl is a small expression language for strategies over candle series:
+
+	buy when crossover(ema(close, 12), ema(close, 26)) and rsi(close, 14) < 70
+	sell when crossunder(ema(close, 12), ema(close, 26))
+
+Candlestick patterns are available as cdl_<name>(), e.g. cdl_engulfing(),
+yielding 100, -100 or 0 per bar.
+*/
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Error is a compile error at a position of the source.
+type Error struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+func errorAt(src string, pos int, format string, args ...interface{}) *Error {
+	line, col := 1, 1
+	for _, r := range src[:pos] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &Error{line, col, fmt.Sprintf(format, args...)}
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, strings.ToLower(src[i:j]), i})
+			i = j
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case strings.ContainsRune("<>=!", c):
+			j := i + 1
+			if j < len(src) && src[j] == '=' {
+				j++
+			}
+			op := src[i:j]
+			if op == "=" || op == "!" {
+				return nil, errorAt(src, i, "unexpected %q", op)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i = j
+		case strings.ContainsRune("+-*/", c):
+			toks = append(toks, token{tokOp, string(c), i})
+			i++
+		default:
+			return nil, errorAt(src, i, "unexpected %q", c)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
@@ -0,0 +1,207 @@
+package dsl
+
+import "strconv"
+
+type node interface {
+	pos() int
+}
+
+type numberNode struct {
+	at    int
+	value float64
+}
+
+type identNode struct {
+	at   int
+	name string
+}
+
+type callNode struct {
+	at   int
+	name string
+	args []node
+}
+
+type unaryNode struct {
+	at int
+	op string
+	x  node
+}
+
+type binaryNode struct {
+	at   int
+	op   string
+	x, y node
+}
+
+func (n numberNode) pos() int { return n.at }
+func (n identNode) pos() int  { return n.at }
+func (n callNode) pos() int   { return n.at }
+func (n unaryNode) pos() int  { return n.at }
+func (n binaryNode) pos() int { return n.at }
+
+type parser struct {
+	src  string
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isWord(words ...string) bool {
+	t := p.peek()
+	if t.kind != tokIdent && t.kind != tokOp {
+		return false
+	}
+	for _, w := range words {
+		if t.text == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return errorAt(p.src, t.pos, format, args...)
+}
+
+func (p *parser) expect(kind tokenKind, text string) (token, error) {
+	t := p.next()
+	if t.kind != kind || (text != "" && t.text != text) {
+		return t, p.errorf(t, "expected %q, found %s", text, t)
+	}
+	return t, nil
+}
+
+// expr parses or-expressions, the loosest binding level.
+func (p *parser) expr() (node, error) {
+	return p.binary(0)
+}
+
+var levels = [][]string{
+	{"or"},
+	{"and"},
+	{"<", "<=", ">", ">=", "==", "!="},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(levels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord(levels[level]...) {
+		op := p.next()
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryNode{op.pos, op.text, x, y}
+		// comparisons do not chain
+		if level == 2 {
+			break
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.isWord("not", "-") {
+		op := p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op.pos, op.text, x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "bad number %s", t)
+		}
+		return numberNode{t.pos, v}, nil
+	case tokLParen:
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokIdent:
+		if keywords[t.text] {
+			return nil, p.errorf(t, "unexpected keyword %s", t)
+		}
+		if p.peek().kind != tokLParen {
+			return identNode{t.pos, t.text}, nil
+		}
+		p.next()
+		call := callNode{at: t.pos, name: t.text}
+		for p.peek().kind != tokRParen {
+			if len(call.args) > 0 {
+				if _, err := p.expect(tokComma, ","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+		return call, nil
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+var keywords = map[string]bool{"buy": true, "sell": true, "when": true, "and": true, "or": true, "not": true}
+
+// rule is one "buy when ..." or "sell when ..." line.
+type rule struct {
+	action string
+	cond   node
+}
+
+func (p *parser) rules() ([]rule, error) {
+	var rules []rule
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		if t.kind != tokIdent || (t.text != "buy" && t.text != "sell") {
+			return nil, p.errorf(t, "expected \"buy\" or \"sell\", found %s", t)
+		}
+		if _, err := p.expect(tokIdent, "when"); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule{t.text, cond})
+	}
+	if len(rules) == 0 {
+		return nil, errorAt(p.src, len(p.src), "empty strategy")
+	}
+	return rules, nil
+}
@@ -0,0 +1,140 @@
+package dsl
+
+import quote "github.com/markcheno/go-quote"
+
+// Signal is what a strategy wants to do on a bar.
+type Signal int
+
+const (
+	Hold Signal = iota
+	Buy
+	Sell
+)
+
+func (s Signal) String() string {
+	switch s {
+	case Buy:
+		return "buy"
+	case Sell:
+		return "sell"
+	}
+	return "hold"
+}
+
+// Strategy is a compiled set of buy and sell rules.
+type Strategy struct {
+	Source string
+	buy    []value
+	sell   []value
+}
+
+// Compile parses and type checks a strategy.
+func Compile(src string) (*Strategy, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, toks: toks}
+	rules, err := p.rules()
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{src}
+	s := &Strategy{Source: src}
+	for _, r := range rules {
+		v, err := c.compile(r.cond)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.want(r.cond, v, Bool); err != nil {
+			return nil, err
+		}
+		if r.action == "buy" {
+			s.buy = append(s.buy, v)
+		} else {
+			s.sell = append(s.sell, v)
+		}
+	}
+	return s, nil
+}
+
+// Signals returns one signal per bar of q. When a buy and a sell rule
+// hold on the same bar they cancel out.
+func (s *Strategy) Signals(q quote.Quote) []Signal {
+	out := make([]Signal, len(q.Close))
+	buy, sell := anyHolds(s.buy, q), anyHolds(s.sell, q)
+	for i := range out {
+		switch {
+		case buy[i] && !sell[i]:
+			out[i] = Buy
+		case sell[i] && !buy[i]:
+			out[i] = Sell
+		}
+	}
+	return out
+}
+
+// Signal returns the signal on the last bar of q.
+func (s *Strategy) Signal(q quote.Quote) Signal {
+	sig := s.Signals(q)
+	if len(sig) == 0 {
+		return Hold
+	}
+	return sig[len(sig)-1]
+}
+
+func anyHolds(vs []value, q quote.Quote) []bool {
+	out := make([]bool, len(q.Close))
+	for _, v := range vs {
+		for i, b := range v.boolean(q) {
+			out[i] = out[i] || b
+		}
+	}
+	return out
+}
+
+// Condition is a compiled boolean expression. It satisfies
+// alert.Condition so DSL expressions can drive alert rules.
+type Condition struct {
+	Source string
+	v      value
+}
+
+// CompileCondition parses and type checks a boolean expression.
+func CompileCondition(src string) (*Condition, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	c := &checker{src}
+	v, err := c.compile(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.want(n, v, Bool); err != nil {
+		return nil, err
+	}
+	return &Condition{src, v}, nil
+}
+
+// Eval returns the condition for every bar of q.
+func (c *Condition) Eval(q quote.Quote) []bool {
+	return c.v.boolean(q)
+}
+
+// Check reports whether the condition holds on the last bar of q.
+func (c *Condition) Check(q quote.Quote) (bool, string) {
+	b := c.Eval(q)
+	if len(b) == 0 || !b[len(b)-1] {
+		return false, ""
+	}
+	return true, c.Source
+}
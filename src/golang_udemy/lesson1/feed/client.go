@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"golang_udemy/lesson1/marketpb"
+
+	"github.com/quic-go/quic-go"
+)
+
+// MaxBackoff is the longest a Client waits between attempts to
+// reconnect.
+const MaxBackoff = 5 * time.Second
+
+// Client receives candles from a feed Server. It keeps reconnecting until
+// closed, so the channels Subscribe returns survive server restarts;
+// updates published while the client was disconnected are missed.
+type Client struct {
+	addr    string
+	tlsConf *tls.Config
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	conn *quic.Conn
+	subs map[string]chan marketpb.Candle
+}
+
+// Dial returns a client of the feed server at addr. It connects in the
+// background, and Subscribe works before it has.
+func Dial(addr string, tlsConf *tls.Config) *Client {
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{ALPN}
+	c := &Client{addr: addr, tlsConf: tlsConf, subs: map[string]chan marketpb.Candle{}}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+// Subscribe returns the candles of symbol. Subscribing to a symbol
+// twice returns the same channel. It is closed by Close.
+func (c *Client) Subscribe(symbol string) <-chan marketpb.Candle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.subs[symbol]; ok {
+		return ch
+	}
+	ch := make(chan marketpb.Candle, Buffer)
+	c.subs[symbol] = ch
+	if c.conn != nil && c.ctx.Err() == nil {
+		c.start(c.conn, symbol, ch)
+	}
+	return ch
+}
+
+// Close disconnects and closes every subscription channel.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.cancel()
+	c.mu.Unlock()
+	c.wg.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for symbol, ch := range c.subs {
+		close(ch)
+		delete(c.subs, symbol)
+	}
+	return nil
+}
+
+// run connects, subscribes to every symbol, waits for the connection to
+// drop and starts over, backing off while the server is unreachable.
+func (c *Client) run() {
+	defer c.wg.Done()
+	backoff := 100 * time.Millisecond
+	for c.ctx.Err() == nil {
+		conn, err := quic.DialAddr(c.ctx, c.addr, c.tlsConf, config)
+		if err != nil {
+			select {
+			case <-time.After(backoff):
+			case <-c.ctx.Done():
+			}
+			backoff = min(2*backoff, MaxBackoff)
+			continue
+		}
+		backoff = 100 * time.Millisecond
+		c.mu.Lock()
+		c.conn = conn
+		for symbol, ch := range c.subs {
+			c.start(conn, symbol, ch)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-conn.Context().Done():
+		case <-c.ctx.Done():
+			conn.CloseWithError(0, "client closing")
+		}
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+	}
+}
+
+// start subscribes to symbol on conn, passing its candles to ch until
+// the stream or the connection ends. c.mu must be held.
+func (c *Client) start(conn *quic.Conn, symbol string, ch chan marketpb.Candle) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		st, err := conn.OpenStreamSync(c.ctx)
+		if err != nil {
+			return
+		}
+		defer st.CancelRead(0)
+		if err := marketpb.NewWriter(st).Write(&marketpb.Subscription{Symbol: symbol}); err != nil {
+			return
+		}
+		r := marketpb.NewReader(st)
+		for {
+			var candle marketpb.Candle
+			if err := r.Read(&candle); err != nil {
+				return
+			}
+			select {
+			case ch <- candle:
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+}
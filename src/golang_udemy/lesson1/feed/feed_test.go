@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/marketpb"
+)
+
+// certs returns a self-signed server config for 127.0.0.1 and a client
+// config that trusts it.
+func certs(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+func serve(t *testing.T, addr string, conf *tls.Config, hub *Hub) *Server {
+	s, err := Listen(addr, conf, hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	return s
+}
+
+// receive publishes candles for symbol until one arrives on ch.
+func receive(t *testing.T, hub *Hub, symbol string, ch <-chan marketpb.Candle, close float64) {
+	t.Helper()
+	deadline := time.After(10 * time.Second)
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case c := <-ch:
+			if c.Close == close {
+				return
+			}
+		case <-tick.C:
+			hub.Publish(symbol, marketpb.Candle{Time: time.Now(), Close: close})
+		case <-deadline:
+			t.Fatal("No candle for", symbol)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	updates, cancel := hub.Subscribe("AAPL")
+	hub.Publish("AAPL", marketpb.Candle{Close: 1})
+	hub.Publish("MSFT", marketpb.Candle{Close: 2})
+	if c := <-updates; c.Close != 1 {
+		t.Error("Expected 1, got", c.Close)
+	}
+	for range Buffer + 1 {
+		hub.Publish("AAPL", marketpb.Candle{})
+	}
+	if len(updates) != Buffer {
+		t.Error("Expected a full buffer, got", len(updates))
+	}
+	cancel()
+	if n := hub.Subscribers("AAPL"); n != 0 {
+		t.Error("Expected no subscribers, got", n)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	serverConf, clientConf := certs(t)
+	hub := NewHub()
+	s := serve(t, "127.0.0.1:0", serverConf, hub)
+	addr := s.Addr().String()
+
+	c := Dial(addr, clientConf)
+	defer c.Close()
+	aapl := c.Subscribe("AAPL")
+	msft := c.Subscribe("MSFT")
+	if c.Subscribe("AAPL") != aapl {
+		t.Error("Expected the same channel for a second subscription")
+	}
+	receive(t, hub, "AAPL", aapl, 1)
+	receive(t, hub, "MSFT", msft, 2)
+
+	// The client subscribes again once the server is back.
+	s.Close()
+	s = serve(t, addr, serverConf, hub)
+	defer s.Close()
+	receive(t, hub, "AAPL", aapl, 3)
+	receive(t, hub, "MSFT", msft, 4)
+}
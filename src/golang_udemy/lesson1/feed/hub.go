@@ -0,0 +1,74 @@
+/*
+feed pushes candle updates to subscribers over QUIC.
+
+A client opens one QUIC stream per symbol and sends a
+marketpb.Subscription on it; the server answers on the same stream with
+length-delimited marketpb.Candle messages for as long as the stream
+stays open. Streams are independent, so a slow or lost packet for one
+symbol does not hold up the others as it would on a single TCP
+connection.
+
+Client reconnects after the connection drops and subscribes again to
+every symbol it had.
+*/
+package feed
+
+import (
+	"sync"
+
+	"golang_udemy/lesson1/marketpb"
+)
+
+// Buffer is how many updates a subscriber may fall behind by before it
+// starts missing them.
+const Buffer = 256
+
+// Hub fans the candles published for a symbol out to its subscribers.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan marketpb.Candle]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[chan marketpb.Candle]bool{}}
+}
+
+// Publish sends c to every subscriber of symbol. A subscriber Buffer
+// updates behind misses c rather than holding up the others.
+func (h *Hub) Publish(symbol string, c marketpb.Candle) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[symbol] {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the candles published for symbol from now on, until
+// cancel is called.
+func (h *Hub) Subscribe(symbol string) (updates <-chan marketpb.Candle, cancel func()) {
+	ch := make(chan marketpb.Candle, Buffer)
+	h.mu.Lock()
+	if h.subs[symbol] == nil {
+		h.subs[symbol] = map[chan marketpb.Candle]bool{}
+	}
+	h.subs[symbol][ch] = true
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[symbol], ch)
+		if len(h.subs[symbol]) == 0 {
+			delete(h.subs, symbol)
+		}
+	}
+}
+
+// Subscribers returns how many subscribers symbol has.
+func (h *Hub) Subscribers(symbol string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[symbol])
+}
@@ -0,0 +1,42 @@
+package feed
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"golang_udemy/lesson1/marketpb"
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Poll fetches the recent candles of symbols from p every interval and
+// publishes those newer than the last one published, until ctx is done.
+// A failed fetch is logged and retried on the next tick.
+func Poll(ctx context.Context, hub *Hub, p provider.Provider, period quote.Period, every time.Duration, symbols ...string) {
+	last := map[string]time.Time{}
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		now := time.Now()
+		for _, symbol := range symbols {
+			q, err := p.Fetch(provider.Request{Symbol: symbol, Period: period, From: now.AddDate(0, 0, -1), To: now})
+			if err != nil {
+				log.Printf("feed: %s: %v", symbol, err)
+				continue
+			}
+			for _, c := range marketpb.FromQuote(q, period).Candles {
+				if c.Time.After(last[symbol]) {
+					hub.Publish(symbol, c)
+					last[symbol] = c.Time
+				}
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang_udemy/lesson1/marketpb"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ALPN is the application protocol the feed negotiates in TLS.
+const ALPN = "lesson1-feed"
+
+// config keeps idle connections open, so a quiet symbol does not look
+// like a dead server.
+var config = &quic.Config{MaxIdleTimeout: 30 * time.Second, KeepAlivePeriod: 10 * time.Second}
+
+// Server serves the candles published on a Hub.
+type Server struct {
+	hub *Hub
+	udp *net.UDPConn
+	tr  *quic.Transport
+	ln  *quic.Listener
+
+	mu    sync.Mutex
+	conns map[*quic.Conn]bool
+}
+
+// Listen listens for feed clients on the UDP address addr.
+func Listen(addr string, tlsConf *tls.Config, hub *Hub) (*Server, error) {
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{ALPN}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	udp, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	tr := &quic.Transport{Conn: udp}
+	ln, err := tr.Listen(tlsConf, config)
+	if err != nil {
+		udp.Close()
+		return nil, err
+	}
+	return &Server{hub: hub, udp: udp, tr: tr, ln: ln, conns: map[*quic.Conn]bool{}}, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Serve accepts clients until Close, then returns nil.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.ln.Accept(context.Background())
+		if errors.Is(err, quic.ErrServerClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// Close stops accepting clients, drops the connected ones and frees the
+// address, so a new Server can listen on it straight away.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.CloseWithError(0, "server closing")
+	}
+	s.mu.Unlock()
+	s.tr.Close()
+	if cerr := s.udp.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *Server) serve(conn *quic.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	for {
+		st, err := conn.AcceptStream(conn.Context())
+		if err != nil {
+			return
+		}
+		go s.stream(st)
+	}
+}
+
+// stream sends the candles of the symbol a client subscribes to on st
+// until either side closes it.
+func (s *Server) stream(st *quic.Stream) {
+	defer st.Close()
+	var sub marketpb.Subscription
+	if err := marketpb.NewReader(st).Read(&sub); err != nil || sub.Symbol == "" {
+		st.CancelRead(1)
+		return
+	}
+	updates, cancel := s.hub.Subscribe(sub.Symbol)
+	defer cancel()
+	w := marketpb.NewWriter(st)
+	for {
+		select {
+		case c := <-updates:
+			if err := w.Write(&c); err != nil {
+				return
+			}
+		case <-st.Context().Done():
+			return
+		}
+	}
+}
@@ -3,7 +3,13 @@ module golang_udemy/lesson1
 go 1.25.2
 
 require (
-	github.com/markcheno/go-quote v0.0.0-20251022180205-ebbbbdb8e2b0 // indirect
-	github.com/markcheno/go-talib v0.0.0-20250114000313-ec55a20c902f // indirect
-	github.com/mattn/go-sqlite3 v1.14.33 // indirect
+	github.com/markcheno/go-quote v0.0.0-20251022180205-ebbbbdb8e2b0
+	github.com/markcheno/go-talib v0.0.0-20250114000313-ec55a20c902f
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/quic-go/quic-go v0.55.0
+	github.com/yuin/goldmark v1.7.13
+	golang.org/x/crypto v0.43.0
+	golang.org/x/net v0.46.0 // indirect
+	golang.org/x/sys v0.37.0 // indirect
+	google.golang.org/protobuf v1.36.10
 )
@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"database/sql"
+	"errors"
+	"runtime"
+	"testing"
+
+	"golang_udemy/lesson1/oms"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type exchange struct {
+	fills map[string][]oms.Fill
+	known map[string]bool
+	// crash stops the submitting goroutine as if the process died
+	crash bool
+}
+
+func (x *exchange) Submit(o oms.Order) (string, error) {
+	if x.crash {
+		runtime.Goexit()
+	}
+	x.known[o.ClientOrderID] = true
+	return "ex-" + o.ClientOrderID, nil
+}
+
+func (x *exchange) Find(o oms.Order) (string, error) {
+	if !x.known[o.ClientOrderID] {
+		return "", oms.ErrNotFound
+	}
+	return "ex-" + o.ClientOrderID, nil
+}
+
+func (x *exchange) Cancel(o oms.Order) error { return nil }
+
+func (x *exchange) Fills(o oms.Order) ([]oms.Fill, error) { return x.fills[o.ClientOrderID], nil }
+
+func open(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestRecover(t *testing.T) {
+	db := open(t)
+	j, _ := Open(db)
+	x := &exchange{fills: map[string][]oms.Fill{}, known: map[string]bool{}}
+	m, _ := oms.New(db, x)
+	Record(j, m)
+
+	j.Append(Config, map[string]int{"max_position": 3})
+	j.Append(Signal, "buy BTC-USD")
+	m.Place(oms.Order{ClientOrderID: "b1", Symbol: "BTC-USD", Side: oms.Buy, Quantity: 2})
+	m.ApplyFill(oms.Fill{FillID: "f1", ClientOrderID: "b1", Quantity: 2, Price: 100})
+	m.Place(oms.Order{ClientOrderID: "s1", Symbol: "BTC-USD", Side: oms.Sell, Quantity: 1})
+	// the process dies while submitting b2
+	x.crash = true
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Place(oms.Order{ClientOrderID: "b2", Symbol: "ETH-USD", Side: oms.Buy, Quantity: 1})
+	}()
+	<-done
+	x.crash = false
+
+	// the process restarts; the sell filled while it was down
+	j, err := Open(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ = oms.New(db, x)
+	Record(j, m)
+	x.fills["s1"] = []oms.Fill{{FillID: "f2", Quantity: 0.5, Price: 110}}
+	b, err := Recover(j, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Positions["BTC-USD"] != 1.5 {
+		t.Error("Expected position 1.5, got", b.Positions)
+	}
+	if len(b.Orders) != 2 || b.Orders["s1"].State != oms.PartiallyFilled || b.Orders["b2"].State != oms.Open {
+		t.Error("Expected s1 partially filled and b2 submitted, got", b.Orders)
+	}
+	if string(b.Config) != `{"max_position":3}` {
+		t.Error("Unexpected config", string(b.Config))
+	}
+	kinds := map[Kind]int{}
+	j.Replay(func(e Entry) error {
+		kinds[e.Kind]++
+		return nil
+	})
+	if kinds[Intent] != 3 || kinds[Ack] != 3 || kinds[Fill] != 2 {
+		t.Error("Expected 3 intents, 3 acks and 2 fills, got", kinds)
+	}
+}
+
+func TestRecordFails(t *testing.T) {
+	db := open(t)
+	j, _ := Open(db)
+	m, _ := oms.New(db, &exchange{known: map[string]bool{}})
+	Record(j, m)
+	db.Exec(`DROP TABLE journal`)
+	if _, err := m.Place(oms.Order{ClientOrderID: "b1", Symbol: "BTC-USD", Side: oms.Buy, Quantity: 1}); err == nil {
+		t.Error("Expected place to fail without a journal")
+	}
+	if _, err := m.Get("b1"); !errors.Is(err, oms.ErrNotFound) {
+		t.Error("Expected the unjournaled order not to be saved, got", err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	db := open(t)
+	j, _ := Open(db)
+	j.Append(Signal, "buy")
+	j.Append(Signal, "sell")
+	j.Append(Signal, "buy")
+	db.Exec(`UPDATE journal SET data = '"hold"' WHERE seq = 2`)
+
+	var ce *CorruptError
+	if err := j.Replay(func(Entry) error { return nil }); !errors.As(err, &ce) || ce.Seq != 2 {
+		t.Error("Expected entry 2 to be corrupt, got", err)
+	}
+
+	db.Exec(`UPDATE journal SET data = '"sell"' WHERE seq = 2`)
+	db.Exec(`DELETE FROM journal WHERE seq = 1`)
+	if err := j.Replay(func(Entry) error { return nil }); !errors.As(err, &ce) {
+		t.Error("Expected a gap to be detected, got", err)
+	}
+}
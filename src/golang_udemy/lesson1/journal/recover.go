@@ -0,0 +1,85 @@
+package journal
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"golang_udemy/lesson1/oms"
+)
+
+// Record appends every order event of m to j in the transaction that
+// makes the change. An order change that cannot be journaled fails.
+func Record(j *Journal, m *oms.Manager) {
+	m.Subscribe(func(tx *sql.Tx, e oms.Event) error {
+		_, err := j.AppendTx(tx, kindOf(e), e)
+		return err
+	})
+}
+
+func kindOf(e oms.Event) Kind {
+	switch {
+	case e.Fill != nil:
+		return Fill
+	case e.Ack:
+		return Ack
+	case e.From == "":
+		return Intent
+	}
+	return Order
+}
+
+// Book is the trading state rebuilt from the journal.
+type Book struct {
+	// Orders holds the orders that were still live, by client order id.
+	Orders map[string]oms.Order
+	// Positions holds the filled quantity per symbol, negative when short.
+	Positions map[string]float64
+	// Config is the payload of the latest config entry.
+	Config json.RawMessage
+}
+
+// Rebuild replays j into a Book.
+func Rebuild(j *Journal) (*Book, error) {
+	b := &Book{Orders: map[string]oms.Order{}, Positions: map[string]float64{}}
+	err := j.Replay(func(e Entry) error {
+		switch e.Kind {
+		case Config:
+			b.Config = e.Data
+		case Intent, Ack, Fill, Order:
+			var ev oms.Event
+			if err := json.Unmarshal(e.Data, &ev); err != nil {
+				return err
+			}
+			o := ev.Order
+			if e.Kind == Fill && ev.Fill != nil {
+				delta := ev.Fill.Quantity
+				if o.Side == oms.Sell {
+					delta = -delta
+				}
+				b.Positions[o.Symbol] += delta
+			}
+			if o.State.Terminal() {
+				delete(b.Orders, o.ClientOrderID)
+			} else {
+				b.Orders[o.ClientOrderID] = o
+			}
+		}
+		return nil
+	})
+	return b, err
+}
+
+// Recover rebuilds the book from j, reconciles m against the exchange,
+// which journals any fills missed while the process was down and settles
+// orders a crash left pending mid-submit, and returns the book as it
+// stands afterwards. m must already be recorded to j.
+func Recover(j *Journal, m *oms.Manager) (*Book, error) {
+	// refuse to trade on a corrupt log
+	if _, err := Rebuild(j); err != nil {
+		return nil, err
+	}
+	if err := m.Reconcile(); err != nil {
+		return nil, err
+	}
+	return Rebuild(j)
+}
@@ -0,0 +1,219 @@
+package marketpb
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var protoToken = regexp.MustCompile(`//[^\n]*|"[^"]*"|[A-Za-z_][\w.]*|\d+|[{}=;]`)
+
+var scalars = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+}
+
+// parseProto reads the subset of the proto3 language market.proto uses:
+// top-level messages of scalar, message and enum fields, and enums. It
+// fails on anything else, so the file cannot grow past what is checked.
+func parseProto(src string) (*descriptorpb.FileDescriptorProto, error) {
+	var toks []string
+	for _, t := range protoToken.FindAllString(src, -1) {
+		if len(t) < 2 || t[:2] != "//" {
+			toks = append(toks, t)
+		}
+	}
+	fd := &descriptorpb.FileDescriptorProto{Name: proto.String("market.proto"), Syntax: proto.String("proto3")}
+	enums := map[string]bool{}
+	var pending []*descriptorpb.FieldDescriptorProto
+	next := func() string {
+		if len(toks) == 0 {
+			return ""
+		}
+		t := toks[0]
+		toks = toks[1:]
+		return t
+	}
+	expect := func(want string) error {
+		if got := next(); got != want {
+			return fmt.Errorf("expected %q, found %q", want, got)
+		}
+		return nil
+	}
+	number := func() (int32, error) {
+		t := next()
+		n, err := strconv.ParseInt(t, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("expected a number, found %q", t)
+		}
+		return int32(n), nil
+	}
+	for len(toks) > 0 {
+		switch t := next(); t {
+		case "syntax", "option":
+			for next() != ";" {
+			}
+		case "package":
+			fd.Package = proto.String(next())
+			if err := expect(";"); err != nil {
+				return nil, err
+			}
+		case "enum":
+			e := &descriptorpb.EnumDescriptorProto{Name: proto.String(next())}
+			enums[e.GetName()] = true
+			if err := expect("{"); err != nil {
+				return nil, err
+			}
+			for toks[0] != "}" {
+				name := next()
+				if err := expect("="); err != nil {
+					return nil, err
+				}
+				n, err := number()
+				if err != nil {
+					return nil, err
+				}
+				e.Value = append(e.Value, &descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(n)})
+				if err := expect(";"); err != nil {
+					return nil, err
+				}
+			}
+			next()
+			fd.EnumType = append(fd.EnumType, e)
+		case "message":
+			m := &descriptorpb.DescriptorProto{Name: proto.String(next())}
+			if err := expect("{"); err != nil {
+				return nil, err
+			}
+			for toks[0] != "}" {
+				f := &descriptorpb.FieldDescriptorProto{Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+				typ := next()
+				if typ == "repeated" {
+					f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+					typ = next()
+				}
+				f.Name = proto.String(next())
+				f.JsonName = proto.String(f.GetName())
+				if err := expect("="); err != nil {
+					return nil, err
+				}
+				n, err := number()
+				if err != nil {
+					return nil, err
+				}
+				f.Number = proto.Int32(n)
+				if err := expect(";"); err != nil {
+					return nil, err
+				}
+				if s, ok := scalars[typ]; ok {
+					f.Type = s.Enum()
+				} else {
+					// resolved once every enum is known
+					f.TypeName = proto.String(typ)
+					pending = append(pending, f)
+				}
+				m.Field = append(m.Field, f)
+			}
+			next()
+			fd.MessageType = append(fd.MessageType, m)
+		default:
+			return nil, fmt.Errorf("unexpected %q", t)
+		}
+	}
+	for _, f := range pending {
+		if enums[f.GetTypeName()] {
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		} else {
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		}
+		f.TypeName = proto.String("." + fd.GetPackage() + "." + f.GetTypeName())
+	}
+	return fd, nil
+}
+
+// loadProto builds the descriptor of market.proto.
+func loadProto(t *testing.T) protoreflect.FileDescriptor {
+	src, err := os.ReadFile("market.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdp, err := parseProto(string(src))
+	if err != nil {
+		t.Fatal("market.proto:", err)
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatal("market.proto:", err)
+	}
+	return fd
+}
+
+// TestDescriptor checks the hand-written encodings against the protobuf
+// runtime reading market.proto: each sample, with every field set, must
+// decode there with every field known and set, and what the runtime
+// encodes must decode here to the sample again.
+func TestDescriptor(t *testing.T) {
+	fd := loadProto(t)
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	candle := Candle{at, 1, 2, 0.5, 1.5, 100}
+	samples := map[string]Message{
+		"Candle":       &candle,
+		"CandleBatch":  &CandleBatch{"BTC-USD", "60", []Candle{candle, {Time: at.Add(time.Minute), Close: 3}}},
+		"Order":        &Order{"c1", "ex-1", "BTC-USD", SideSell, 2, 100, 1, 99.5, "partially_filled", "why", at, at.Add(time.Second)},
+		"Fill":         &Fill{"f1", "c1", 1, 99.5, at},
+		"Signal":       &Signal{"BTC-USD", at, ActionBuy, "sma"},
+		"Subscription": &Subscription{"BTC-USD"},
+	}
+	msgs := fd.Messages()
+	if msgs.Len() != len(samples) {
+		t.Errorf("Expected a sample for each of the %d messages of market.proto, have %d", msgs.Len(), len(samples))
+	}
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+		name := string(md.Name())
+		sample, ok := samples[name]
+		if !ok {
+			t.Errorf("%s: no Go type", name)
+			continue
+		}
+		dyn := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(sample.Append(nil), dyn); err != nil {
+			t.Errorf("%s: runtime cannot decode ours: %v", name, err)
+			continue
+		}
+		if len(dyn.GetUnknown()) > 0 {
+			t.Errorf("%s: fields unknown to market.proto: %x", name, dyn.GetUnknown())
+		}
+		fields := md.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			if f := fields.Get(j); !dyn.Has(f) {
+				t.Errorf("%s: field %s is not written or has the wrong type", name, f.Name())
+			}
+		}
+
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(dyn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back := reflect.New(reflect.TypeOf(sample).Elem()).Interface().(Message)
+		if err := back.Unmarshal(b); err != nil {
+			t.Errorf("%s: cannot decode the runtime's encoding: %v", name, err)
+		} else if !reflect.DeepEqual(back, sample) {
+			t.Errorf("%s: Expected %+v back from the runtime, got %+v", name, sample, back)
+		}
+	}
+}
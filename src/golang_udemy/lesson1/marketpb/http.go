@@ -0,0 +1,76 @@
+package marketpb
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// NewHandler serves
+//
+//	GET /candles?symbol=&period=d&from=&to=&format=json|csv|protobuf
+//
+// fetching candles through p. The range defaults to the last 180 days.
+// format=protobuf, or an Accept header naming application/x-protobuf,
+// streams length-delimited CandleBatch messages of up to BatchSize
+// candles.
+func NewHandler(p provider.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		req := provider.Request{Symbol: q.Get("symbol"), Period: quote.Period(q.Get("period")), To: time.Now()}
+		if req.Symbol == "" {
+			http.Error(w, "missing symbol", http.StatusBadRequest)
+			return
+		}
+		if req.Period == "" {
+			req.Period = quote.Daily
+		}
+		var err error
+		if s := q.Get("to"); s != "" {
+			if req.To, err = time.Parse("2006-01-02", s); err != nil {
+				http.Error(w, "bad to date", http.StatusBadRequest)
+				return
+			}
+		}
+		req.From = req.To.AddDate(0, 0, -180)
+		if s := q.Get("from"); s != "" {
+			if req.From, err = time.Parse("2006-01-02", s); err != nil {
+				http.Error(w, "bad from date", http.StatusBadRequest)
+				return
+			}
+		}
+		format := q.Get("format")
+		if format == "" && strings.Contains(r.Header.Get("Accept"), "application/x-protobuf") {
+			format = "protobuf"
+		}
+		if format != "" && format != "json" && format != "csv" && format != "protobuf" {
+			http.Error(w, "bad format "+format, http.StatusBadRequest)
+			return
+		}
+
+		candles, err := p.Fetch(req)
+		if provider.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		switch format {
+		case "protobuf":
+			w.Header().Set("Content-Type", ContentType)
+			WriteQuote(NewWriter(w), candles, req.Period)
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte(candles.CSV()))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(candles.JSON(false)))
+		}
+	})
+}
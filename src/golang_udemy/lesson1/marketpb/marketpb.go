@@ -0,0 +1,451 @@
+/*
+marketpb encodes candles, orders, fills, signals and feed subscriptions
+in the protobuf wire format described by market.proto, and streams them
+length-delimited.
+
+There is no protoc in the build, so the types are plain structs encoded
+by hand with protowire. They follow proto3: zero values are not written,
+and unknown fields are skipped on decoding, so old readers accept newer
+messages.
+*/
+package marketpb
+
+import (
+	"math"
+	"time"
+
+	"golang_udemy/lesson1/dsl"
+	"golang_udemy/lesson1/oms"
+
+	quote "github.com/markcheno/go-quote"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Message is a type with a protobuf encoding.
+type Message interface {
+	// Append appends the encoded message to b.
+	Append(b []byte) []byte
+	// Unmarshal replaces the message with the one encoded in b.
+	Unmarshal(b []byte) error
+}
+
+// Candle is one bar of a symbol.
+type Candle struct {
+	Time                           time.Time
+	Open, High, Low, Close, Volume float64
+}
+
+func (c *Candle) Append(b []byte) []byte {
+	b = appendInt(b, 1, nanos(c.Time))
+	b = appendDouble(b, 2, c.Open)
+	b = appendDouble(b, 3, c.High)
+	b = appendDouble(b, 4, c.Low)
+	b = appendDouble(b, 5, c.Close)
+	return appendDouble(b, 6, c.Volume)
+}
+
+func (c *Candle) Unmarshal(b []byte) error {
+	*c = Candle{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return timestamp(typ, b, &c.Time)
+		case 2:
+			return double(typ, b, &c.Open)
+		case 3:
+			return double(typ, b, &c.High)
+		case 4:
+			return double(typ, b, &c.Low)
+		case 5:
+			return double(typ, b, &c.Close)
+		case 6:
+			return double(typ, b, &c.Volume)
+		}
+		return skip
+	})
+}
+
+// CandleBatch is a run of candles of one symbol.
+type CandleBatch struct {
+	Symbol  string
+	Period  quote.Period
+	Candles []Candle
+}
+
+func (cb *CandleBatch) Append(b []byte) []byte {
+	b = appendString(b, 1, cb.Symbol)
+	b = appendString(b, 2, string(cb.Period))
+	var c []byte
+	for i := range cb.Candles {
+		c = cb.Candles[i].Append(c[:0])
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, c)
+	}
+	return b
+}
+
+func (cb *CandleBatch) Unmarshal(b []byte) error {
+	*cb = CandleBatch{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return str(typ, b, &cb.Symbol)
+		case 2:
+			return str(typ, b, (*string)(&cb.Period))
+		case 3:
+			var c Candle
+			n := embedded(typ, b, &c)
+			cb.Candles = append(cb.Candles, c)
+			return n
+		}
+		return skip
+	})
+}
+
+// FromQuote returns the bars of q at period as one batch.
+func FromQuote(q quote.Quote, period quote.Period) CandleBatch {
+	cb := CandleBatch{Symbol: q.Symbol, Period: period, Candles: make([]Candle, len(q.Close))}
+	for i := range q.Close {
+		cb.Candles[i] = Candle{q.Date[i], q.Open[i], q.High[i], q.Low[i], q.Close[i], q.Volume[i]}
+	}
+	return cb
+}
+
+// Quote returns the candles of cb as a go-quote Quote.
+func (cb *CandleBatch) Quote() quote.Quote {
+	q := quote.NewQuote(cb.Symbol, len(cb.Candles))
+	for i, c := range cb.Candles {
+		q.Date[i], q.Open[i], q.High[i], q.Low[i], q.Close[i], q.Volume[i] = c.Time, c.Open, c.High, c.Low, c.Close, c.Volume
+	}
+	return q
+}
+
+// Side is the side of an order, as market.proto numbers it.
+type Side int32
+
+const (
+	SideUnspecified Side = iota
+	SideBuy
+	SideSell
+)
+
+// Order mirrors oms.Order.
+type Order struct {
+	ClientOrderID, ExchangeOrderID, Symbol string
+	Side                                   Side
+	Quantity, Price, Filled, AvgPrice      float64
+	State, Reason                          string
+	CreatedAt, UpdatedAt                   time.Time
+}
+
+func (o *Order) Append(b []byte) []byte {
+	b = appendString(b, 1, o.ClientOrderID)
+	b = appendString(b, 2, o.ExchangeOrderID)
+	b = appendString(b, 3, o.Symbol)
+	b = appendInt(b, 4, int64(o.Side))
+	b = appendDouble(b, 5, o.Quantity)
+	b = appendDouble(b, 6, o.Price)
+	b = appendDouble(b, 7, o.Filled)
+	b = appendDouble(b, 8, o.AvgPrice)
+	b = appendString(b, 9, o.State)
+	b = appendString(b, 10, o.Reason)
+	b = appendInt(b, 11, nanos(o.CreatedAt))
+	return appendInt(b, 12, nanos(o.UpdatedAt))
+}
+
+func (o *Order) Unmarshal(b []byte) error {
+	*o = Order{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return str(typ, b, &o.ClientOrderID)
+		case 2:
+			return str(typ, b, &o.ExchangeOrderID)
+		case 3:
+			return str(typ, b, &o.Symbol)
+		case 4:
+			return enum(typ, b, &o.Side)
+		case 5:
+			return double(typ, b, &o.Quantity)
+		case 6:
+			return double(typ, b, &o.Price)
+		case 7:
+			return double(typ, b, &o.Filled)
+		case 8:
+			return double(typ, b, &o.AvgPrice)
+		case 9:
+			return str(typ, b, &o.State)
+		case 10:
+			return str(typ, b, &o.Reason)
+		case 11:
+			return timestamp(typ, b, &o.CreatedAt)
+		case 12:
+			return timestamp(typ, b, &o.UpdatedAt)
+		}
+		return skip
+	})
+}
+
+// NewOrder converts an OMS order.
+func NewOrder(o oms.Order) Order {
+	side := SideUnspecified
+	switch o.Side {
+	case oms.Buy:
+		side = SideBuy
+	case oms.Sell:
+		side = SideSell
+	}
+	return Order{o.ClientOrderID, o.ExchangeOrderID, o.Symbol, side, o.Quantity, o.Price, o.Filled, o.AvgPrice,
+		string(o.State), o.Reason, o.CreatedAt, o.UpdatedAt}
+}
+
+// OMS converts o back to an OMS order.
+func (o *Order) OMS() oms.Order {
+	var side oms.Side
+	switch o.Side {
+	case SideBuy:
+		side = oms.Buy
+	case SideSell:
+		side = oms.Sell
+	}
+	return oms.Order{ClientOrderID: o.ClientOrderID, ExchangeOrderID: o.ExchangeOrderID, Symbol: o.Symbol, Side: side,
+		Quantity: o.Quantity, Price: o.Price, Filled: o.Filled, AvgPrice: o.AvgPrice,
+		State: oms.State(o.State), Reason: o.Reason, CreatedAt: o.CreatedAt, UpdatedAt: o.UpdatedAt}
+}
+
+// Fill mirrors oms.Fill.
+type Fill struct {
+	FillID, ClientOrderID string
+	Quantity, Price       float64
+	Time                  time.Time
+}
+
+func (f *Fill) Append(b []byte) []byte {
+	b = appendString(b, 1, f.FillID)
+	b = appendString(b, 2, f.ClientOrderID)
+	b = appendDouble(b, 3, f.Quantity)
+	b = appendDouble(b, 4, f.Price)
+	return appendInt(b, 5, nanos(f.Time))
+}
+
+func (f *Fill) Unmarshal(b []byte) error {
+	*f = Fill{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return str(typ, b, &f.FillID)
+		case 2:
+			return str(typ, b, &f.ClientOrderID)
+		case 3:
+			return double(typ, b, &f.Quantity)
+		case 4:
+			return double(typ, b, &f.Price)
+		case 5:
+			return timestamp(typ, b, &f.Time)
+		}
+		return skip
+	})
+}
+
+// NewFill converts an OMS fill.
+func NewFill(f oms.Fill) Fill {
+	return Fill(f)
+}
+
+// OMS converts f back to an OMS fill.
+func (f *Fill) OMS() oms.Fill {
+	return oms.Fill(*f)
+}
+
+// Action is what a signal asks for, as market.proto numbers it.
+type Action int32
+
+const (
+	ActionHold Action = iota
+	ActionBuy
+	ActionSell
+)
+
+// Signal is what a strategy asked for on a symbol at Time.
+type Signal struct {
+	Symbol   string
+	Time     time.Time
+	Action   Action
+	Strategy string
+}
+
+func (s *Signal) Append(b []byte) []byte {
+	b = appendString(b, 1, s.Symbol)
+	b = appendInt(b, 2, nanos(s.Time))
+	b = appendInt(b, 3, int64(s.Action))
+	return appendString(b, 4, s.Strategy)
+}
+
+func (s *Signal) Unmarshal(b []byte) error {
+	*s = Signal{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return str(typ, b, &s.Symbol)
+		case 2:
+			return timestamp(typ, b, &s.Time)
+		case 3:
+			return enum(typ, b, &s.Action)
+		case 4:
+			return str(typ, b, &s.Strategy)
+		}
+		return skip
+	})
+}
+
+// NewSignal converts the signal a strategy gave for symbol at t.
+func NewSignal(symbol string, t time.Time, sig dsl.Signal, strategy string) Signal {
+	action := ActionHold
+	switch sig {
+	case dsl.Buy:
+		action = ActionBuy
+	case dsl.Sell:
+		action = ActionSell
+	}
+	return Signal{symbol, t, action, strategy}
+}
+
+// DSL converts the action of s back to a dsl.Signal.
+func (s *Signal) DSL() dsl.Signal {
+	switch s.Action {
+	case ActionBuy:
+		return dsl.Buy
+	case ActionSell:
+		return dsl.Sell
+	}
+	return dsl.Hold
+}
+
+// Subscription opens a feed stream for the candles of Symbol.
+type Subscription struct {
+	Symbol string
+}
+
+func (s *Subscription) Append(b []byte) []byte {
+	return appendString(b, 1, s.Symbol)
+}
+
+func (s *Subscription) Unmarshal(b []byte) error {
+	*s = Subscription{}
+	return decode(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 {
+			return str(typ, b, &s.Symbol)
+		}
+		return skip
+	})
+}
+
+// skip is returned by a field decoder for a field it does not know, or
+// that comes with an unexpected wire type.
+const skip = math.MinInt32
+
+// decode calls f with every field of the message b and the bytes from
+// its value on. f returns how many bytes the value took, a negative
+// protowire error, or skip.
+func decode(b []byte, f func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n = f(num, typ, b)
+		if n == skip {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func str(typ protowire.Type, b []byte, s *string) int {
+	if typ != protowire.BytesType {
+		return skip
+	}
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n
+}
+
+func double(typ protowire.Type, b []byte, f *float64) int {
+	if typ != protowire.Fixed64Type {
+		return skip
+	}
+	v, n := protowire.ConsumeFixed64(b)
+	*f = math.Float64frombits(v)
+	return n
+}
+
+func enum[T ~int32](typ protowire.Type, b []byte, e *T) int {
+	if typ != protowire.VarintType {
+		return skip
+	}
+	v, n := protowire.ConsumeVarint(b)
+	*e = T(int32(v))
+	return n
+}
+
+func timestamp(typ protowire.Type, b []byte, t *time.Time) int {
+	if typ != protowire.VarintType {
+		return skip
+	}
+	v, n := protowire.ConsumeVarint(b)
+	if v != 0 {
+		*t = time.Unix(0, int64(v)).UTC()
+	}
+	return n
+}
+
+func embedded(typ protowire.Type, b []byte, m Message) int {
+	if typ != protowire.BytesType {
+		return skip
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n
+	}
+	if err := m.Unmarshal(v); err != nil {
+		return -1
+	}
+	return n
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendDouble(b []byte, num protowire.Number, f float64) []byte {
+	if f == 0 && !math.Signbit(f) {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(f))
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// nanos returns t as Unix nanoseconds, or 0 for the zero time.
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,111 @@
+package marketpb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/dsl"
+	"golang_udemy/lesson1/oms"
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func candles(n int) quote.Quote {
+	q := quote.NewQuote("BTC-USD", n)
+	for i := 0; i < n; i++ {
+		q.Date[i] = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i], q.Volume[i] = 1, 2, 0.5, float64(i), 10
+	}
+	return q
+}
+
+func TestEncoding(t *testing.T) {
+	c := Candle{Time: time.Unix(0, 1), Close: 1}
+	// time = 1 as a varint, close = 1.0 as a double
+	if got := hex.EncodeToString(c.Append(nil)); got != "0801"+"29000000000000f03f" {
+		t.Error("Unexpected encoding", got)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	o := NewOrder(oms.Order{ClientOrderID: "b1", Symbol: "BTC-USD", Side: oms.Sell, Quantity: 2, State: oms.Open, CreatedAt: now})
+	var o2 Order
+	if err := o2.Unmarshal(o.Append(nil)); err != nil || o2.OMS() != o.OMS() {
+		t.Error("Expected the order back, got", o2, err)
+	}
+	f := NewFill(oms.Fill{FillID: "f1", ClientOrderID: "b1", Quantity: 1, Price: -0.5, Time: now})
+	var f2 Fill
+	if err := f2.Unmarshal(f.Append(nil)); err != nil || f2 != f {
+		t.Error("Expected the fill back, got", f2, err)
+	}
+	s := NewSignal("BTC-USD", now, dsl.Sell, "sma")
+	var s2 Signal
+	if err := s2.Unmarshal(s.Append(nil)); err != nil || s2 != s || s2.DSL() != dsl.Sell {
+		t.Error("Expected the signal back, got", s2, err)
+	}
+
+	// a newer writer's field 99 is skipped
+	b := protowire.AppendTag(s.Append(nil), 99, protowire.BytesType)
+	b = protowire.AppendString(b, "extra")
+	if err := s2.Unmarshal(b); err != nil || s2 != s {
+		t.Error("Expected an unknown field to be skipped, got", s2, err)
+	}
+	if err := s2.Unmarshal(b[:len(b)-2]); err == nil {
+		t.Error("Expected a truncated message to fail")
+	}
+}
+
+func TestStream(t *testing.T) {
+	q := candles(2*BatchSize + 1)
+	var buf bytes.Buffer
+	if err := WriteQuote(NewWriter(&buf), q, quote.Min1); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	var cb CandleBatch
+	if err := r.Read(&cb); err != nil || len(cb.Candles) != BatchSize || cb.Period != quote.Min1 {
+		t.Fatal("Expected a full first batch, got", len(cb.Candles), cb.Period, err)
+	}
+
+	got, period, err := ReadQuote(NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil || period != quote.Min1 || len(got.Close) != len(q.Close) {
+		t.Fatal("Expected every candle back, got", len(got.Close), period, err)
+	}
+	if got.Symbol != "BTC-USD" || !got.Date[2000].Equal(q.Date[2000]) || got.Close[2000] != 2000 || got.Low[0] != 0.5 {
+		t.Error("Unexpected candles", got.Symbol, got.Date[2000], got.Close[2000])
+	}
+
+	if _, _, err := ReadQuote(NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))); err != io.ErrUnexpectedEOF {
+		t.Error("Expected a cut stream to fail, got", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	p := provider.Func{ProviderName: "fake", Get: func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		return candles(3), nil
+	}}
+	h := NewHandler(p)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/candles?symbol=BTC-USD&from=2024-01-01&to=2024-01-02", nil)
+	r.Header.Set("Accept", "application/x-protobuf")
+	h.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != ContentType {
+		t.Fatal("Expected protobuf, got", w.Code, w.Header(), w.Body)
+	}
+	q, _, err := ReadQuote(NewReader(w.Body))
+	if err != nil || len(q.Close) != 3 {
+		t.Error("Expected 3 candles, got", q, err)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/candles?symbol=BTC-USD&format=xml", nil))
+	if w.Code != 400 {
+		t.Error("Expected 400 for an unknown format, got", w.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package marketpb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	quote "github.com/markcheno/go-quote"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentType is the media type of a length-delimited stream.
+const ContentType = "application/x-protobuf; delimited=true"
+
+// MaxMessage is the largest message a Reader accepts.
+const MaxMessage = 16 << 20
+
+// BatchSize is how many candles WriteQuote puts in each batch.
+const BatchSize = 1000
+
+// Writer writes messages each preceded by its length as a varint, the
+// framing of protobuf's writeDelimitedTo.
+type Writer struct {
+	w        io.Writer
+	msg, out []byte
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes m.
+func (w *Writer) Write(m Message) error {
+	w.msg = m.Append(w.msg[:0])
+	w.out = protowire.AppendVarint(w.out[:0], uint64(len(w.msg)))
+	w.out = append(w.out, w.msg...)
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+// Reader reads messages written by a Writer.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read reads the next message into m. It returns io.EOF at the end of a
+// stream that ends between messages, and io.ErrUnexpectedEOF for one
+// that ends inside a message.
+func (r *Reader) Read(m Message) error {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	if size > MaxMessage {
+		return fmt.Errorf("marketpb: message of %d bytes is over the limit", size)
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return m.Unmarshal(r.buf)
+}
+
+// WriteQuote writes the bars of q at period as batches of BatchSize.
+func WriteQuote(w *Writer, q quote.Quote, period quote.Period) error {
+	all := FromQuote(q, period)
+	for start := 0; start == 0 || start < len(all.Candles); start += BatchSize {
+		cb := all
+		cb.Candles = all.Candles[start:min(start+BatchSize, len(all.Candles))]
+		if err := w.Write(&cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadQuote reads batches up to the end of r and joins them into one
+// quote, returning it with the period of the first batch.
+func ReadQuote(r *Reader) (quote.Quote, quote.Period, error) {
+	var all CandleBatch
+	for first := true; ; first = false {
+		var cb CandleBatch
+		err := r.Read(&cb)
+		if err == io.EOF && !first {
+			break
+		}
+		if err == io.EOF {
+			return quote.NewQuote("", 0), "", errors.New("marketpb: no candles")
+		}
+		if err != nil {
+			return quote.NewQuote("", 0), "", err
+		}
+		if first {
+			all.Symbol, all.Period = cb.Symbol, cb.Period
+		} else if cb.Symbol != all.Symbol {
+			return quote.NewQuote("", 0), "", fmt.Errorf("marketpb: batch of %s in a stream of %s", cb.Symbol, all.Symbol)
+		}
+		all.Candles = append(all.Candles, cb.Candles...)
+	}
+	return all.Quote(), all.Period, nil
+}
@@ -0,0 +1,281 @@
+/*
+metrics keeps counters, histograms and gauges in memory and serves them
+in the Prometheus text exposition format, so a Prometheus server can
+scrape them. Metrics are registered once, usually in package variables;
+registering a name twice or passing the wrong number of label values is
+a programming error and panics.
+*/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are histogram bounds in seconds suited to request and query
+// latencies.
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the other packages register their metrics in.
+var Default = NewRegistry()
+
+type metric interface {
+	header() (name, help, kind string)
+	// samples writes the samples of the metric, one per line.
+	samples(w *bufio.Writer)
+}
+
+// Registry is a set of metrics that can be served together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.metrics[name] = m
+}
+
+// ServeHTTP writes every metric of r, sorted by name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	var names []string
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]metric, len(names))
+	for i, name := range names {
+		list[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := bufio.NewWriter(w)
+	for _, m := range list {
+		name, help, kind := m.header()
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+		m.samples(b)
+	}
+	b.Flush()
+}
+
+// family holds the values of one metric by label values.
+type family struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*value
+	// buckets are a histogram's upper bounds; nil for a counter
+	buckets []float64
+}
+
+// value is a counter's count, or a histogram's observations.
+type value struct {
+	v      float64
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// get returns the value for the label values, creating it if needed.
+// f.mu must be held.
+func (f *family) get(values []string) *value {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v, ok := f.values[key]
+	if !ok {
+		v = &value{counts: make([]uint64, len(f.buckets))}
+		f.values[key] = v
+	}
+	return v
+}
+
+// each calls fn with the label pairs and value of every set of label
+// values, sorted. f.mu must be held.
+func (f *family) each(fn func(labels []string, v *value)) {
+	keys := make([]string, 0, len(f.values))
+	for k := range f.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var pairs []string
+		if len(f.labels) > 0 {
+			for i, v := range strings.Split(k, "\xff") {
+				pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+			}
+		}
+		fn(pairs, f.values[k])
+	}
+}
+
+// Counter is a count that only goes up, kept per set of label values.
+type Counter struct {
+	f family
+}
+
+// NewCounter registers a counter in r. By convention its name ends in
+// _total. A counter without labels is served from zero on.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family{name: name, help: help, labels: labels, values: map[string]*value{}}}
+	if len(labels) == 0 {
+		c.f.get(nil)
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the counter with the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the counter with the label
+// values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: " + c.f.name + " cannot go down")
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(values).v += v
+}
+
+func (c *Counter) header() (string, string, string) {
+	return c.f.name, c.f.help, "counter"
+}
+
+func (c *Counter) samples(w *bufio.Writer) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.each(func(labels []string, v *value) {
+		sample(w, c.f.name, labels, v.v)
+	})
+}
+
+// Histogram counts observations into buckets, per set of label values.
+type Histogram struct {
+	f family
+}
+
+// NewHistogram registers a histogram in r with the upper bounds buckets,
+// in increasing order; an implicit +Inf bucket follows them.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: " + name + " buckets are not sorted")
+	}
+	h := &Histogram{family{name: name, help: help, labels: labels, values: map[string]*value{}, buckets: buckets}}
+	r.register(name, h)
+	return h
+}
+
+// Observe records v in the histogram with the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(values)
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(h.f.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since observes the seconds since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) header() (string, string, string) {
+	return h.f.name, h.f.help, "histogram"
+}
+
+func (h *Histogram) samples(w *bufio.Writer) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	h.f.each(func(labels []string, s *value) {
+		le := func(bound string) []string {
+			return append(labels[:len(labels):len(labels)], `le="`+bound+`"`)
+		}
+		var n uint64
+		for i, bound := range h.f.buckets {
+			n += s.counts[i]
+			sample(w, h.f.name+"_bucket", le(formatFloat(bound)), float64(n))
+		}
+		sample(w, h.f.name+"_bucket", le("+Inf"), float64(s.count))
+		sample(w, h.f.name+"_sum", labels, s.sum)
+		sample(w, h.f.name+"_count", labels, float64(s.count))
+	})
+}
+
+type gaugeFunc struct {
+	name, help string
+	f          func() float64
+}
+
+// NewGaugeFunc registers a gauge in r whose value is whatever f returns
+// when the metrics are served.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, gaugeFunc{name, help, f})
+}
+
+func (g gaugeFunc) header() (string, string, string) {
+	return g.name, g.help, "gauge"
+}
+
+func (g gaugeFunc) samples(w *bufio.Writer) {
+	sample(w, g.name, nil, g.f())
+}
+
+func sample(w *bufio.Writer, name string, labels []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+	orders := r.NewCounter("orders_total", "Orders by state.", "state")
+	latency := r.NewHistogram("latency_seconds", "How long it took.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("workers", "Workers running.\nNow.", func() float64 { return 3 })
+
+	orders.Inc("filled")
+	orders.Add(2, `re"jected`)
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP latency_seconds How long it took.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+# HELP orders_total Orders by state.
+# TYPE orders_total counter
+orders_total{state="filled"} 1
+orders_total{state="re\"jected"} 2
+# HELP workers Workers running.\nNow.
+# TYPE workers gauge
+workers 3
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Error("Unexpected content type", ct)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c_total", "", "a")
+	for name, f := range map[string]func(){
+		"twice":        func() { r.NewCounter("c_total", "") },
+		"label values": func() { c.Inc() },
+		"negative":     func() { c.Add(-1, "x") },
+		"buckets":      func() { r.NewHistogram("h", "", []float64{1, 0.5}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic for", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -4,9 +4,9 @@ import "fmt"
 
 type Person struct {
 	// Name
-	Name string
+	Name string `json:"name"`
 	// Age
-	Age int
+	Age int `json:"age"`
 }
 
 func Say() {
@@ -3,6 +3,8 @@ mylib is my special lib.
 */
 package mylib
 
+import "sort"
+
 //Average returns the average of a series of number
 func Average(s []int) int {
 	total := 0
@@ -11,3 +13,29 @@ func Average(s []int) int {
 	}
 	return int(total / len(s))
 }
+
+// Median returns the middle value of s, or the mean of the two middle
+// values when len(s) is even. s must not be empty and is not modified.
+func Median(s []int) float64 {
+	return Percentile(s, 50)
+}
+
+// Percentile returns the p-th percentile (0 to 100) of s, interpolating
+// linearly between the two closest ranks. s must not be empty and is
+// not modified.
+func Percentile(s []int, p float64) float64 {
+	sorted := append([]int(nil), s...)
+	sort.Ints(sorted)
+	if p <= 0 {
+		return float64(sorted[0])
+	}
+	if p >= 100 {
+		return float64(sorted[len(sorted)-1])
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo+1 == len(sorted) {
+		return float64(sorted[lo])
+	}
+	return float64(sorted[lo]) + (rank-float64(lo))*float64(sorted[lo+1]-sorted[lo])
+}
@@ -14,3 +14,24 @@ func TestAverage(t *testing.T) {
 	}
 }
 
+func TestMedian(t *testing.T) {
+	if v := Median([]int{5, 1, 3}); v != 3 {
+		t.Error("Expected 3, got", v)
+	}
+	if v := Median([]int{4, 1, 3, 2}); v != 2.5 {
+		t.Error("Expected 2.5, got", v)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := []int{10, 20, 30, 40, 50}
+	if v := Percentile(s, 90); v != 46 {
+		t.Error("Expected 46, got", v)
+	}
+	if v := Percentile(s, 0); v != 10 {
+		t.Error("Expected 10, got", v)
+	}
+	if s[0] != 10 {
+		t.Error("Expected s to be left alone")
+	}
+}
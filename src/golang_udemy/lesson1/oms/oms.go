@@ -0,0 +1,517 @@
+package oms
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang_udemy/lesson1/metrics"
+)
+
+var ErrNotFound = errors.New("oms: order not found")
+
+// Side is the direction of an order.
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Order is an order and how much of it has been filled.
+// A zero Price means a market order.
+type Order struct {
+	ClientOrderID   string
+	ExchangeOrderID string
+	Symbol          string
+	Side            Side
+	Quantity        float64
+	Price           float64
+	Filled          float64
+	AvgPrice        float64
+	State           State
+	Reason          string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// Fill is an execution reported by the exchange.
+type Fill struct {
+	FillID        string
+	ClientOrderID string
+	Quantity      float64
+	Price         float64
+	Time          time.Time
+}
+
+// Event is published for every change of an order. Fill is set when a
+// fill caused it, and Ack when it records the exchange accepting the
+// order, which may come after fills that overtook the acknowledgement.
+type Event struct {
+	Order    Order
+	From, To State
+	Fill     *Fill `json:",omitempty"`
+	Ack      bool  `json:",omitempty"`
+	Time     time.Time
+}
+
+// Exchange is what the OMS needs from an exchange adapter.
+type Exchange interface {
+	// Submit sends o and returns the exchange's id for it.
+	// An error means the exchange rejected the order.
+	Submit(o Order) (string, error)
+	// Find looks o up by its client order id and returns the exchange's
+	// id for it, or an error wrapping ErrNotFound if it never arrived.
+	Find(o Order) (string, error)
+	// Cancel asks the exchange to cancel o. A nil error means it is cancelled.
+	Cancel(o Order) error
+	// Fills returns every fill of o the exchange knows of.
+	Fills(o Order) ([]Fill, error)
+}
+
+// ErrBadFill is returned for a fill of no quantity or of more than is
+// left of its order.
+var ErrBadFill = errors.New("oms: bad fill quantity")
+
+// ErrBusy is returned for an order that is waiting on the exchange.
+var ErrBusy = errors.New("oms: order has an exchange call in flight")
+
+// Manager places and tracks orders. The database is only touched while
+// mu is held; calls to the exchange are made without it, and busy keeps
+// a second call for the same order from starting meanwhile.
+type Manager struct {
+	db   *sql.DB
+	ex   Exchange
+	mu   sync.Mutex
+	busy map[string]bool
+	subs []func(*sql.Tx, Event) error
+	now  func() time.Time
+}
+
+// New creates the orders and order_fills tables in db if needed.
+func New(db *sql.DB, ex Exchange) (*Manager, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS orders(
+			client_order_id TEXT PRIMARY KEY,
+			exchange_order_id TEXT,
+			symbol TEXT,
+			side TEXT,
+			quantity REAL,
+			price REAL,
+			filled REAL,
+			avg_price REAL,
+			state TEXT,
+			reason TEXT,
+			created_at INT,
+			updated_at INT);
+		CREATE TABLE IF NOT EXISTS order_fills(
+			fill_id TEXT PRIMARY KEY,
+			client_order_id TEXT REFERENCES orders(client_order_id),
+			quantity REAL,
+			price REAL,
+			time INT)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{db: db, ex: ex, busy: map[string]bool{}, now: time.Now}, nil
+}
+
+// Subscribe registers f to receive every event inside the transaction
+// that makes the change, so what f writes through tx commits with it.
+// An error from f rolls the change back and fails the operation. f runs
+// while the manager is locked and must not call back into it.
+func (m *Manager) Subscribe(f func(tx *sql.Tx, e Event) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, f)
+}
+
+// Place records o and submits it to the exchange. Placing a client
+// order id that is already known returns the existing order untouched,
+// unless it was left pending by a submit that never finished: then the
+// exchange is asked whether it has it, and it is submitted if not.
+func (m *Manager) Place(o Order) (Order, error) {
+	if o.ClientOrderID == "" || o.Quantity <= 0 {
+		return o, fmt.Errorf("oms: order needs a client order id and a positive quantity")
+	}
+	m.mu.Lock()
+	existing, err := m.get(m.db, o.ClientOrderID)
+	switch {
+	case err == nil:
+		if existing.State != Pending || m.busy[o.ClientOrderID] {
+			m.mu.Unlock()
+			return existing, nil
+		}
+		m.busy[o.ClientOrderID] = true
+		m.mu.Unlock()
+		defer m.release(o.ClientOrderID)
+		return m.submit(existing, true)
+	case !errors.Is(err, ErrNotFound):
+		m.mu.Unlock()
+		return o, err
+	}
+
+	o.State, o.Filled, o.AvgPrice, o.ExchangeOrderID = Pending, 0, 0, ""
+	o.CreatedAt, o.UpdatedAt = m.now(), m.now()
+	o, err = m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		_, err := tx.Exec(`INSERT INTO orders(client_order_id, exchange_order_id, symbol, side, quantity, price,
+			filled, avg_price, state, reason, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			o.ClientOrderID, o.ExchangeOrderID, o.Symbol, o.Side, o.Quantity, o.Price,
+			o.Filled, o.AvgPrice, o.State, o.Reason, o.CreatedAt.UnixNano(), o.UpdatedAt.UnixNano())
+		*events = append(*events, Event{Order: o, To: Pending, Time: o.CreatedAt})
+		return o, err
+	})
+	if err != nil {
+		m.mu.Unlock()
+		return o, err
+	}
+	m.busy[o.ClientOrderID] = true
+	m.mu.Unlock()
+	defer m.release(o.ClientOrderID)
+	return m.submit(o, false)
+}
+
+// release clears the busy mark of an order once its exchange call and
+// the booking of the answer are done.
+func (m *Manager) release(clientOrderID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, clientOrderID)
+}
+
+// submit sends a pending order to the exchange, first asking whether it
+// already has it when lookup is set, and books the answer. The caller
+// must have marked the order busy and releases it afterwards.
+func (m *Manager) submit(o Order, lookup bool) (Order, error) {
+	var id string
+	var exErr error
+	if lookup {
+		id, exErr = m.ex.Find(o)
+	}
+	rejected := false
+	if !lookup || errors.Is(exErr, ErrNotFound) {
+		id, exErr = m.ex.Submit(o)
+		rejected = exErr != nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if exErr != nil && !rejected {
+		// the exchange could not tell: leave it pending for the next try
+		return o, exErr
+	}
+	return m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		cur, err := m.get(tx, o.ClientOrderID)
+		if err != nil {
+			return o, err
+		}
+		if rejected {
+			cur.Reason = exErr.Error()
+			return m.transition(tx, events, cur, Rejected)
+		}
+		cur.ExchangeOrderID = id
+		if cur.State != Pending {
+			// fills got here before the acknowledgement
+			cur.UpdatedAt = m.now()
+			*events = append(*events, Event{Order: cur, From: cur.State, To: cur.State, Ack: true, Time: cur.UpdatedAt})
+			return cur, m.save(tx, cur)
+		}
+		if cur, err = m.transition(tx, events, cur, Open); err == nil {
+			(*events)[len(*events)-1].Ack = true
+		}
+		return cur, err
+	})
+}
+
+// Cancel asks the exchange to cancel an order.
+func (m *Manager) Cancel(clientOrderID string) (Order, error) {
+	m.mu.Lock()
+	if m.busy[clientOrderID] {
+		m.mu.Unlock()
+		return Order{}, fmt.Errorf("%w: %s", ErrBusy, clientOrderID)
+	}
+	o, err := m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		o, err := m.get(tx, clientOrderID)
+		if err != nil {
+			return o, err
+		}
+		return m.transition(tx, events, o, CancelRequested)
+	})
+	if err != nil {
+		m.mu.Unlock()
+		return o, err
+	}
+	m.busy[clientOrderID] = true
+	m.mu.Unlock()
+	defer m.release(clientOrderID)
+
+	refused := m.ex.Cancel(o)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		o, err := m.get(tx, clientOrderID)
+		if err != nil || o.State.Terminal() {
+			// filled while we were asking
+			return o, err
+		}
+		if refused != nil {
+			// the exchange refused: go back to where the fills put us
+			o.Reason = refused.Error()
+			back := Open
+			if o.Filled > 0 {
+				back = PartiallyFilled
+			}
+			return m.transition(tx, events, o, back)
+		}
+		return m.transition(tx, events, o, Cancelled)
+	})
+}
+
+// Expire marks an order that has outlived its time in force.
+func (m *Manager) Expire(clientOrderID string) (Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		o, err := m.get(tx, clientOrderID)
+		if err != nil {
+			return o, err
+		}
+		return m.transition(tx, events, o, Expired)
+	})
+}
+
+// ApplyFill books f against its order. Fills already booked are ignored.
+func (m *Manager) ApplyFill(f Fill) (Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		return m.applyFill(tx, events, f)
+	})
+}
+
+// applyFill records f and moves its order in the same transaction, so a
+// fill is never booked without the order reflecting it.
+func (m *Manager) applyFill(tx *sql.Tx, events *[]Event, f Fill) (Order, error) {
+	o, err := m.get(tx, f.ClientOrderID)
+	if err != nil {
+		return o, err
+	}
+	var seen int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM order_fills WHERE fill_id = ?`, f.FillID).Scan(&seen); err != nil {
+		return o, err
+	}
+	if seen > 0 {
+		return o, nil
+	}
+	if !(f.Quantity > 0) || o.Filled+f.Quantity > o.Quantity*(1+1e-9) {
+		return o, fmt.Errorf("%w: %s of %g on %s with %g of %g filled",
+			ErrBadFill, f.FillID, f.Quantity, o.ClientOrderID, o.Filled, o.Quantity)
+	}
+	next := PartiallyFilled
+	if o.Filled+f.Quantity >= o.Quantity {
+		next = Filled
+	} else if o.State == CancelRequested {
+		next = CancelRequested
+	}
+	if !o.State.CanTransition(next) {
+		return o, &TransitionError{o.ClientOrderID, o.State, next}
+	}
+	if _, err := tx.Exec(`INSERT INTO order_fills(fill_id, client_order_id, quantity, price, time) VALUES(?, ?, ?, ?, ?)`,
+		f.FillID, f.ClientOrderID, f.Quantity, f.Price, f.Time.UnixNano()); err != nil {
+		return o, err
+	}
+	o.AvgPrice = (o.AvgPrice*o.Filled + f.Price*f.Quantity) / (o.Filled + f.Quantity)
+	o.Filled += f.Quantity
+	if o, err = m.transition(tx, events, o, next); err == nil {
+		(*events)[len(*events)-1].Fill = &f
+	}
+	return o, err
+}
+
+// Reconcile brings every live order in line with the exchange. Orders
+// left pending by an interrupted submit are looked up by client order id
+// and submitted if the exchange never got them; the fills of the others
+// that are missing here are booked. An order that fails is left for the
+// next pass and does not hold up the others; the errors are joined.
+func (m *Manager) Reconcile() error {
+	m.mu.Lock()
+	live, err := m.list(m.db, `WHERE state IN (?, ?, ?, ?)`, Pending, Open, PartiallyFilled, CancelRequested)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, o := range live {
+		if err := m.reconcile(o); err != nil {
+			errs = append(errs, fmt.Errorf("oms: reconcile %s: %w", o.ClientOrderID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcile brings o in line with the exchange, keeping it busy
+// meanwhile. An order busy with another call is skipped.
+func (m *Manager) reconcile(o Order) error {
+	m.mu.Lock()
+	if m.busy[o.ClientOrderID] {
+		m.mu.Unlock()
+		return nil
+	}
+	m.busy[o.ClientOrderID] = true
+	m.mu.Unlock()
+	defer m.release(o.ClientOrderID)
+
+	if o.State == Pending {
+		var err error
+		if o, err = m.submit(o, true); err != nil {
+			return err
+		}
+		if o.State.Terminal() {
+			return nil
+		}
+	}
+	fills, err := m.ex.Fills(o)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = m.update(func(tx *sql.Tx, events *[]Event) (Order, error) {
+		for _, f := range fills {
+			f.ClientOrderID = o.ClientOrderID
+			if _, err := m.applyFill(tx, events, f); err != nil {
+				return o, err
+			}
+		}
+		return o, nil
+	})
+	return err
+}
+
+// Get returns an order by client order id.
+func (m *Manager) Get(clientOrderID string) (Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(m.db, clientOrderID)
+}
+
+// Live returns the orders that are not in a final state.
+func (m *Manager) Live() ([]Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list(m.db, `WHERE state IN (?, ?, ?, ?)`, Pending, Open, PartiallyFilled, CancelRequested)
+}
+
+// update runs f in a transaction and publishes the events it produced
+// before committing. m.mu must be held.
+func (m *Manager) update(f func(tx *sql.Tx, events *[]Event) (Order, error)) (Order, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return Order{}, err
+	}
+	var events []Event
+	o, err := f(tx, &events)
+	for i := 0; err == nil && i < len(events); i++ {
+		err = m.publish(tx, events[i])
+	}
+	if err != nil {
+		tx.Rollback()
+		return o, err
+	}
+	if err := tx.Commit(); err != nil {
+		return o, err
+	}
+	for _, e := range events {
+		count(e)
+	}
+	return o, nil
+}
+
+var (
+	placed   = metrics.Default.NewCounter("oms_orders_placed_total", "Orders recorded, before they reach the exchange.")
+	filled   = metrics.Default.NewCounter("oms_orders_filled_total", "Orders filled in full.")
+	rejected = metrics.Default.NewCounter("oms_orders_rejected_total", "Orders the exchange rejected.")
+)
+
+// count adds a committed event to the order counters.
+func count(e Event) {
+	switch {
+	case e.From == e.To:
+	case e.To == Pending:
+		placed.Inc()
+	case e.To == Filled:
+		filled.Inc()
+	case e.To == Rejected:
+		rejected.Inc()
+	}
+}
+
+// transition moves o to next, saves it and queues the change in events.
+func (m *Manager) transition(tx *sql.Tx, events *[]Event, o Order, next State) (Order, error) {
+	if !o.State.CanTransition(next) {
+		return o, &TransitionError{o.ClientOrderID, o.State, next}
+	}
+	prev := o.State
+	o.State, o.UpdatedAt = next, m.now()
+	if err := m.save(tx, o); err != nil {
+		return o, err
+	}
+	*events = append(*events, Event{Order: o, From: prev, To: next, Time: o.UpdatedAt})
+	return o, nil
+}
+
+func (m *Manager) save(tx *sql.Tx, o Order) error {
+	_, err := tx.Exec(`UPDATE orders SET exchange_order_id = ?, filled = ?, avg_price = ?, state = ?,
+		reason = ?, updated_at = ? WHERE client_order_id = ?`,
+		o.ExchangeOrderID, o.Filled, o.AvgPrice, o.State, o.Reason, o.UpdatedAt.UnixNano(), o.ClientOrderID)
+	return err
+}
+
+func (m *Manager) publish(tx *sql.Tx, e Event) error {
+	for _, f := range m.subs {
+		if err := f(tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// querier is what get and list need from a *sql.DB or *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Manager) get(q querier, clientOrderID string) (Order, error) {
+	orders, err := m.list(q, `WHERE client_order_id = ?`, clientOrderID)
+	if err != nil {
+		return Order{}, err
+	}
+	if len(orders) == 0 {
+		return Order{}, fmt.Errorf("%w: %s", ErrNotFound, clientOrderID)
+	}
+	return orders[0], nil
+}
+
+func (m *Manager) list(q querier, where string, args ...interface{}) ([]Order, error) {
+	rows, err := q.Query(`SELECT client_order_id, exchange_order_id, symbol, side, quantity, price,
+		filled, avg_price, state, reason, created_at, updated_at FROM orders `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders []Order
+	for rows.Next() {
+		var o Order
+		var created, updated int64
+		if err := rows.Scan(&o.ClientOrderID, &o.ExchangeOrderID, &o.Symbol, &o.Side, &o.Quantity, &o.Price,
+			&o.Filled, &o.AvgPrice, &o.State, &o.Reason, &created, &updated); err != nil {
+			return nil, err
+		}
+		o.CreatedAt, o.UpdatedAt = time.Unix(0, created), time.Unix(0, updated)
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
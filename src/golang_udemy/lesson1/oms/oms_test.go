@@ -0,0 +1,298 @@
+package oms
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang_udemy/lesson1/metrics"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fakeExchange accepts orders unless told otherwise and reports the fills it is given.
+type fakeExchange struct {
+	reject    error
+	refuse    error
+	down      error
+	fills     map[string][]Fill
+	known     map[string]bool
+	submitted int
+}
+
+func (f *fakeExchange) Submit(o Order) (string, error) {
+	f.submitted++
+	if f.reject != nil {
+		return "", f.reject
+	}
+	if f.known == nil {
+		f.known = map[string]bool{}
+	}
+	f.known[o.ClientOrderID] = true
+	return "ex-" + o.ClientOrderID, nil
+}
+
+func (f *fakeExchange) Find(o Order) (string, error) {
+	if f.down != nil {
+		return "", f.down
+	}
+	if !f.known[o.ClientOrderID] {
+		return "", ErrNotFound
+	}
+	return "ex-" + o.ClientOrderID, nil
+}
+
+func (f *fakeExchange) Cancel(o Order) error { return f.refuse }
+
+func (f *fakeExchange) Fills(o Order) ([]Fill, error) { return f.fills[o.ClientOrderID], nil }
+
+func newManager(t *testing.T, ex Exchange) *Manager {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	m, err := New(db, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// stick leaves o pending, as a crash between saving and submitting would.
+func stick(t *testing.T, m *Manager, o Order) {
+	o.State = Pending
+	if _, err := m.db.Exec(`INSERT INTO orders(client_order_id, exchange_order_id, symbol, side, quantity, price,
+		filled, avg_price, state, reason, created_at, updated_at) VALUES(?, '', ?, ?, ?, ?, 0, 0, ?, '', 0, 0)`,
+		o.ClientOrderID, o.Symbol, o.Side, o.Quantity, o.Price, o.State); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	ex := &fakeExchange{fills: map[string][]Fill{}}
+	m := newManager(t, ex)
+	var events []State
+	m.Subscribe(func(tx *sql.Tx, e Event) error {
+		events = append(events, e.To)
+		return nil
+	})
+
+	o, err := m.Place(Order{ClientOrderID: "c1", Symbol: "BTC-USD", Side: Buy, Quantity: 2, Price: 100})
+	if err != nil || o.State != Open || o.ExchangeOrderID != "ex-c1" {
+		t.Fatal("Expected open order, got", o, err)
+	}
+	// placing the same client order id again is a no-op
+	if o, _ = m.Place(Order{ClientOrderID: "c1", Quantity: 5}); o.Quantity != 2 || ex.submitted != 1 {
+		t.Error("Expected idempotent place, got", o, ex.submitted)
+	}
+
+	ex.fills["c1"] = []Fill{{FillID: "f1", Quantity: 1, Price: 100}}
+	m.Reconcile()
+	m.Reconcile()
+	if o, _ = m.Get("c1"); o.State != PartiallyFilled || o.Filled != 1 {
+		t.Error("Expected one fill booked once, got", o)
+	}
+	ex.fills["c1"] = append(ex.fills["c1"], Fill{FillID: "f2", Quantity: 1, Price: 110})
+	m.Reconcile()
+	if o, _ = m.Get("c1"); o.State != Filled || o.AvgPrice != 105 {
+		t.Error("Expected filled at 105, got", o)
+	}
+
+	want := []State{Pending, Open, PartiallyFilled, Filled}
+	if len(events) != len(want) {
+		t.Fatal("Expected events", want, "got", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Error("Expected events", want, "got", events)
+			break
+		}
+	}
+
+	var te *TransitionError
+	if _, err := m.Cancel("c1"); !errors.As(err, &te) || te.From != Filled {
+		t.Error("Expected transition error cancelling a filled order, got", err)
+	}
+}
+
+func TestRejectAndCancel(t *testing.T) {
+	ex := &fakeExchange{reject: errors.New("insufficient funds")}
+	m := newManager(t, ex)
+	o, _ := m.Place(Order{ClientOrderID: "r1", Symbol: "ETH-USD", Side: Sell, Quantity: 1})
+	if o.State != Rejected || o.Reason != "insufficient funds" {
+		t.Error("Expected rejected, got", o)
+	}
+
+	ex.reject = nil
+	m.Place(Order{ClientOrderID: "c2", Symbol: "ETH-USD", Side: Sell, Quantity: 1})
+	ex.refuse = errors.New("too late")
+	if o, _ = m.Cancel("c2"); o.State != Open {
+		t.Error("Expected refused cancel to reopen, got", o.State)
+	}
+	ex.refuse = nil
+	if o, _ = m.Cancel("c2"); o.State != Cancelled {
+		t.Error("Expected cancelled, got", o.State)
+	}
+	if live, _ := m.Live(); len(live) != 0 {
+		t.Error("Expected no live orders, got", live)
+	}
+	if _, err := m.Get("nope"); !errors.Is(err, ErrNotFound) {
+		t.Error("Expected not found, got", err)
+	}
+}
+
+func TestStuckPending(t *testing.T) {
+	ex := &fakeExchange{fills: map[string][]Fill{}}
+	m := newManager(t, ex)
+
+	// the exchange never got p1: placing it again submits it
+	stick(t, m, Order{ClientOrderID: "p1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	if o, err := m.Place(Order{ClientOrderID: "p1", Quantity: 1}); err != nil || o.State != Open || ex.submitted != 1 {
+		t.Error("Expected p1 submitted on retry, got", o, err, ex.submitted)
+	}
+
+	// the exchange got p2 before the crash: reconcile adopts it without resubmitting
+	stick(t, m, Order{ClientOrderID: "p2", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	ex.known["p2"] = true
+	ex.fills["p2"] = []Fill{{FillID: "f1", Quantity: 1, Price: 100}}
+	if err := m.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := m.Get("p2"); o.State != Filled || o.ExchangeOrderID != "ex-p2" || ex.submitted != 1 {
+		t.Error("Expected p2 found and filled, got", o, ex.submitted)
+	}
+
+	// while the exchange cannot answer, the order stays pending
+	stick(t, m, Order{ClientOrderID: "p3", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	ex.down = errors.New("timeout")
+	if err := m.Reconcile(); err == nil {
+		t.Error("Expected reconcile to fail while the exchange is down")
+	}
+	if o, _ := m.Get("p3"); o.State != Pending {
+		t.Error("Expected p3 still pending, got", o.State)
+	}
+}
+
+func TestReconcileFailures(t *testing.T) {
+	ex := &fakeExchange{fills: map[string][]Fill{}}
+	m := newManager(t, ex)
+	stick(t, m, Order{ClientOrderID: "q1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	stick(t, m, Order{ClientOrderID: "q2", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	ex.down = errors.New("timeout")
+	if err := m.Reconcile(); err == nil {
+		t.Error("Expected reconcile to fail while the exchange is down")
+	}
+	// a failed pass leaves no order busy
+	ex.down = nil
+	if o, err := m.Place(Order{ClientOrderID: "q2", Quantity: 1}); err != nil || o.State != Open {
+		t.Error("Expected q2 to be submitted after the failed pass, got", o, err)
+	}
+
+	// a bad fill of one order does not keep the others from being booked
+	m.Place(Order{ClientOrderID: "q3", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	ex.fills["q2"] = []Fill{{FillID: "big", Quantity: 2, Price: 100}}
+	ex.fills["q3"] = []Fill{{FillID: "ok", Quantity: 1, Price: 100}}
+	if err := m.Reconcile(); !errors.Is(err, ErrBadFill) {
+		t.Error("Expected the overfill to be reported, got", err)
+	}
+	if o, _ := m.Get("q3"); o.State != Filled {
+		t.Error("Expected q3 filled despite q2, got", o.State)
+	}
+	if _, err := m.Cancel("q2"); err != nil {
+		t.Error("Expected q2 not to be left busy, got", err)
+	}
+}
+
+func TestBadFill(t *testing.T) {
+	m := newManager(t, &fakeExchange{})
+	m.Place(Order{ClientOrderID: "b1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	for _, f := range []Fill{{FillID: "zero"}, {FillID: "neg", Quantity: -1}, {FillID: "over", Quantity: 1.5}} {
+		f.ClientOrderID, f.Price = "b1", 100
+		if _, err := m.ApplyFill(f); !errors.Is(err, ErrBadFill) {
+			t.Errorf("Expected fill %s to be refused, got %v", f.FillID, err)
+		}
+	}
+	if o, _ := m.Get("b1"); o.Filled != 0 || o.State != Open {
+		t.Error("Expected nothing booked, got", o)
+	}
+}
+
+// slowExchange blocks in Submit until released.
+type slowExchange struct {
+	fakeExchange
+	entered, release chan struct{}
+}
+
+func (s *slowExchange) Submit(o Order) (string, error) {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.fakeExchange.Submit(o)
+}
+
+func TestExchangeOutsideLock(t *testing.T) {
+	ex := &slowExchange{entered: make(chan struct{}), release: make(chan struct{})}
+	m := newManager(t, ex)
+	done := make(chan Order)
+	go func() {
+		o, _ := m.Place(Order{ClientOrderID: "s1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+		done <- o
+	}()
+	<-ex.entered
+	if o, err := m.Get("s1"); err != nil || o.State != Pending {
+		t.Error("Expected pending order readable during submit, got", o, err)
+	}
+	if _, err := m.Cancel("s1"); !errors.Is(err, ErrBusy) {
+		t.Error("Expected busy cancelling during submit, got", err)
+	}
+	if o, _ := m.Place(Order{ClientOrderID: "s1", Quantity: 1}); o.State != Pending {
+		t.Error("Expected retry during submit to leave it alone, got", o.State)
+	}
+	close(ex.release)
+	if o := <-done; o.State != Open || ex.submitted != 1 {
+		t.Error("Expected one submit, got", o, ex.submitted)
+	}
+}
+
+// counter reads the value of an unlabelled counter from metrics.Default.
+func counter(t *testing.T, name string) float64 {
+	w := httptest.NewRecorder()
+	metrics.Default.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, name+" "); ok {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestCounters(t *testing.T) {
+	names := []string{"oms_orders_placed_total", "oms_orders_filled_total", "oms_orders_rejected_total"}
+	before := map[string]float64{}
+	for _, name := range names {
+		before[name] = counter(t, name)
+	}
+	ex := &fakeExchange{fills: map[string][]Fill{}}
+	m := newManager(t, ex)
+	m.Place(Order{ClientOrderID: "c1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	m.ApplyFill(Fill{FillID: "f1", ClientOrderID: "c1", Quantity: 1, Price: 100})
+	ex.reject = errors.New("insufficient funds")
+	m.Place(Order{ClientOrderID: "r1", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+	// a change rolled back is not counted
+	m.Subscribe(func(tx *sql.Tx, e Event) error { return errors.New("disk full") })
+	m.Place(Order{ClientOrderID: "c2", Symbol: "BTC-USD", Side: Buy, Quantity: 1})
+
+	want := map[string]float64{"oms_orders_placed_total": 2, "oms_orders_filled_total": 1, "oms_orders_rejected_total": 1}
+	for _, name := range names {
+		if got := counter(t, name) - before[name]; got != want[name] {
+			t.Errorf("Expected %s to go up by %g, got %g", name, want[name], got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+oms tracks orders through their lifecycle, persisting them in SQLite and
+publishing an event on every state change.
+*/
+package oms
+
+import "fmt"
+
+// State is where an order is in its lifecycle.
+type State string
+
+const (
+	Pending         State = "pending"
+	Open            State = "open"
+	PartiallyFilled State = "partially_filled"
+	Filled          State = "filled"
+	CancelRequested State = "cancel_requested"
+	Cancelled       State = "cancelled"
+	Rejected        State = "rejected"
+	Expired         State = "expired"
+)
+
+// transitions lists the states each state may move to.
+var transitions = map[State][]State{
+	Pending:         {Open, PartiallyFilled, Filled, Cancelled, Rejected},
+	Open:            {PartiallyFilled, Filled, CancelRequested, Cancelled, Expired},
+	PartiallyFilled: {PartiallyFilled, Filled, CancelRequested, Cancelled, Expired},
+	// fills can still arrive, or the exchange can refuse the cancel
+	CancelRequested: {CancelRequested, Open, PartiallyFilled, Filled, Cancelled, Expired},
+}
+
+// CanTransition reports whether an order in s may move to next.
+func (s State) CanTransition(next State) bool {
+	for _, t := range transitions[s] {
+		if t == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether s is a final state.
+func (s State) Terminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// TransitionError is returned for a state change the lifecycle forbids.
+type TransitionError struct {
+	ClientOrderID string
+	From, To      State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("oms: order %s cannot go from %s to %s", e.ClientOrderID, e.From, e.To)
+}
@@ -0,0 +1,35 @@
+package patterns
+
+import (
+	"fmt"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Condition holds when Pattern appears on the last bar. It satisfies
+// alert.Condition. Direction restricts it to bullish (1) or bearish (-1)
+// matches; 0 accepts both.
+type Condition struct {
+	Pattern   Pattern
+	Options   Options
+	Direction int
+}
+
+func (c Condition) Check(q quote.Quote) (bool, string) {
+	s := Series(q, c.Pattern, c.Options)
+	if len(s) == 0 {
+		return false, ""
+	}
+	v := s[len(s)-1]
+	if v == 0 || c.Direction > 0 && v < 0 || c.Direction < 0 && v > 0 {
+		return false, ""
+	}
+	if c.Pattern == Doji {
+		return true, string(Doji)
+	}
+	kind := "bullish"
+	if v < 0 {
+		kind = "bearish"
+	}
+	return true, fmt.Sprintf("%s %s", kind, c.Pattern)
+}
@@ -0,0 +1,212 @@
+/*
+patterns recognises common candlestick patterns in candle series.
+
+Like TA-Lib's CDL functions, each pattern yields one value per bar:
+100 for a bullish match, -100 for a bearish one and 0 otherwise.
+Doji, which has no direction, yields 100.
+*/
+package patterns
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Pattern names a candlestick pattern.
+type Pattern string
+
+const (
+	Doji               Pattern = "doji"
+	Hammer             Pattern = "hammer"
+	Engulfing          Pattern = "engulfing"
+	Harami             Pattern = "harami"
+	MorningStar        Pattern = "morningstar"
+	EveningStar        Pattern = "eveningstar"
+	ThreeWhiteSoldiers Pattern = "3whitesoldiers"
+	ThreeBlackCrows    Pattern = "3blackcrows"
+)
+
+// Options are the body and shadow thresholds, as fractions of the bar's
+// high-low range unless noted.
+type Options struct {
+	// DojiBody is the largest body of a doji.
+	DojiBody float64
+	// SmallBody is the largest body of a hammer or a star.
+	SmallBody float64
+	// LongBody is the smallest body of the long bars in harami, stars and
+	// three soldiers/crows.
+	LongBody float64
+	// LongShadow is the smallest lower shadow of a hammer, as a multiple of its body.
+	LongShadow float64
+	// SmallShadow is the largest opposite shadow of a hammer or a soldier/crow.
+	SmallShadow float64
+}
+
+// DefaultOptions returns the thresholds used when none are given.
+func DefaultOptions() Options {
+	return Options{DojiBody: 0.1, SmallBody: 0.3, LongBody: 0.6, LongShadow: 2, SmallShadow: 0.1}
+}
+
+// bar gives the shape of one candle.
+type bar struct {
+	o, h, l, c float64
+}
+
+func (b bar) body() float64   { return math.Abs(b.c - b.o) }
+func (b bar) rng() float64    { return b.h - b.l }
+func (b bar) upper() float64  { return b.h - math.Max(b.o, b.c) }
+func (b bar) lower() float64  { return math.Min(b.o, b.c) - b.l }
+func (b bar) white() bool     { return b.c > b.o }
+func (b bar) black() bool     { return b.c < b.o }
+func (b bar) top() float64    { return math.Max(b.o, b.c) }
+func (b bar) bottom() float64 { return math.Min(b.o, b.c) }
+
+func (b bar) long(o Options) bool  { return b.rng() > 0 && b.body() >= o.LongBody*b.rng() }
+func (b bar) small(o Options) bool { return b.body() <= o.SmallBody*b.rng() }
+
+type detector struct {
+	bars int
+	fn   func(b []bar, o Options) float64
+}
+
+var detectors = map[Pattern]detector{
+	Doji: {1, func(b []bar, o Options) float64 {
+		if b[0].rng() > 0 && b[0].body() <= o.DojiBody*b[0].rng() {
+			return 100
+		}
+		return 0
+	}},
+	Hammer: {3, func(b []bar, o Options) float64 {
+		h := b[2]
+		// a small body at the top of a long lower shadow, after a decline
+		if b[1].c < b[0].c && h.rng() > 0 && h.small(o) &&
+			h.lower() >= o.LongShadow*h.body() && h.upper() <= o.SmallShadow*h.rng() {
+			return 100
+		}
+		return 0
+	}},
+	Engulfing: {2, func(b []bar, o Options) float64 {
+		p, c := b[0], b[1]
+		switch {
+		case p.black() && c.white() && c.o <= p.c && c.c >= p.o && c.body() > p.body():
+			return 100
+		case p.white() && c.black() && c.o >= p.c && c.c <= p.o && c.body() > p.body():
+			return -100
+		}
+		return 0
+	}},
+	Harami: {2, func(b []bar, o Options) float64 {
+		p, c := b[0], b[1]
+		if !p.long(o) || c.top() >= p.top() || c.bottom() <= p.bottom() {
+			return 0
+		}
+		switch {
+		case p.black() && c.white():
+			return 100
+		case p.white() && c.black():
+			return -100
+		}
+		return 0
+	}},
+	MorningStar: {3, func(b []bar, o Options) float64 {
+		first, star, last := b[0], b[1], b[2]
+		if first.black() && first.long(o) && star.small(o) && star.top() < first.c &&
+			last.white() && last.c > (first.o+first.c)/2 {
+			return 100
+		}
+		return 0
+	}},
+	EveningStar: {3, func(b []bar, o Options) float64 {
+		first, star, last := b[0], b[1], b[2]
+		if first.white() && first.long(o) && star.small(o) && star.bottom() > first.c &&
+			last.black() && last.c < (first.o+first.c)/2 {
+			return -100
+		}
+		return 0
+	}},
+	ThreeWhiteSoldiers: {3, func(b []bar, o Options) float64 {
+		for i := range b {
+			if !b[i].white() || !b[i].long(o) || b[i].upper() > o.SmallShadow*b[i].rng() {
+				return 0
+			}
+			// each opens within the previous body and closes higher
+			if i > 0 && (b[i].c <= b[i-1].c || b[i].o < b[i-1].o || b[i].o > b[i-1].c) {
+				return 0
+			}
+		}
+		return 100
+	}},
+	ThreeBlackCrows: {3, func(b []bar, o Options) float64 {
+		for i := range b {
+			if !b[i].black() || !b[i].long(o) || b[i].lower() > o.SmallShadow*b[i].rng() {
+				return 0
+			}
+			if i > 0 && (b[i].c >= b[i-1].c || b[i].o > b[i-1].o || b[i].o < b[i-1].c) {
+				return 0
+			}
+		}
+		return -100
+	}},
+}
+
+// Names returns every known pattern.
+func Names() []Pattern {
+	var names []Pattern
+	for p := range detectors {
+		names = append(names, p)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Known reports whether p is a pattern this package detects.
+func Known(p Pattern) bool {
+	_, ok := detectors[p]
+	return ok
+}
+
+// Series returns the pattern value of every bar of q.
+func Series(q quote.Quote, p Pattern, o Options) []float64 {
+	out := make([]float64, len(q.Close))
+	d, ok := detectors[p]
+	if !ok {
+		return out
+	}
+	bars := make([]bar, len(q.Close))
+	for i := range bars {
+		bars[i] = bar{q.Open[i], q.High[i], q.Low[i], q.Close[i]}
+	}
+	for i := d.bars - 1; i < len(bars); i++ {
+		out[i] = d.fn(bars[i-d.bars+1:i+1], o)
+	}
+	return out
+}
+
+// Match is a pattern found on a bar.
+type Match struct {
+	Index   int
+	Date    time.Time
+	Pattern Pattern
+	Value   float64
+}
+
+// Detect returns every occurrence of the given patterns in q, in bar
+// order. With no patterns given it looks for all of them.
+func Detect(q quote.Quote, o Options, which ...Pattern) []Match {
+	if len(which) == 0 {
+		which = Names()
+	}
+	var matches []Match
+	for _, p := range which {
+		for i, v := range Series(q, p, o) {
+			if v != 0 {
+				matches = append(matches, Match{i, q.Date[i], p, v})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Index < matches[j].Index })
+	return matches
+}
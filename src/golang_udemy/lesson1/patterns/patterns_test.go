@@ -0,0 +1,57 @@
+package patterns
+
+import (
+	"testing"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// candles builds a quote from open, high, low, close quadruples.
+func candles(ohlc ...[4]float64) quote.Quote {
+	q := quote.NewQuote("TEST", len(ohlc))
+	for i, b := range ohlc {
+		q.Date[i] = time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i] = b[0], b[1], b[2], b[3]
+	}
+	return q
+}
+
+func TestPatterns(t *testing.T) {
+	o := DefaultOptions()
+	for _, tc := range []struct {
+		p    Pattern
+		q    quote.Quote
+		want float64
+	}{
+		{Doji, candles([4]float64{10, 12, 8, 10.1}), 100},
+		{Doji, candles([4]float64{10, 12, 8, 11.5}), 0},
+		{Hammer, candles([4]float64{12, 12, 11, 11}, [4]float64{11, 11, 10, 10}, [4]float64{9.8, 10, 7, 10}), 100},
+		{Engulfing, candles([4]float64{10, 10.5, 8.5, 9}, [4]float64{8.8, 11, 8.5, 10.5}), 100},
+		{Engulfing, candles([4]float64{9, 10.5, 8.5, 10}, [4]float64{10.2, 10.5, 8, 8.5}), -100},
+		{Harami, candles([4]float64{12, 12.2, 7.8, 8}, [4]float64{9, 10.5, 8.8, 10}), 100},
+		{MorningStar, candles([4]float64{12, 12.2, 7.8, 8}, [4]float64{7.5, 7.8, 7, 7.4}, [4]float64{7.6, 11, 7.5, 10.8}), 100},
+		{EveningStar, candles([4]float64{8, 12.2, 7.8, 12}, [4]float64{12.5, 13, 12.2, 12.6}, [4]float64{12.4, 12.5, 9, 9.2}), -100},
+		{ThreeWhiteSoldiers, candles([4]float64{10, 11.05, 9.9, 11}, [4]float64{10.5, 12.05, 10.4, 12}, [4]float64{11.5, 13.05, 11.4, 13}), 100},
+		{ThreeBlackCrows, candles([4]float64{13, 13.1, 11.95, 12}, [4]float64{12.5, 12.6, 10.95, 11}, [4]float64{11.5, 11.6, 9.95, 10}), -100},
+	} {
+		s := Series(tc.q, tc.p, o)
+		if got := s[len(s)-1]; got != tc.want {
+			t.Errorf("%s: Expected %v, got %v", tc.p, tc.want, got)
+		}
+	}
+}
+
+func TestDetectAndCondition(t *testing.T) {
+	q := candles([4]float64{10, 10.5, 8.5, 9}, [4]float64{8.8, 11, 8.5, 10.5})
+	matches := Detect(q, DefaultOptions())
+	if len(matches) != 1 || matches[0].Pattern != Engulfing || matches[0].Index != 1 {
+		t.Error("Expected bullish engulfing on bar 1, got", matches)
+	}
+	if ok, msg := (Condition{Engulfing, DefaultOptions(), 1}).Check(q); !ok || msg != "bullish engulfing" {
+		t.Error("Expected bullish engulfing, got", ok, msg)
+	}
+	if ok, _ := (Condition{Engulfing, DefaultOptions(), -1}).Check(q); ok {
+		t.Error("Expected no bearish match")
+	}
+}
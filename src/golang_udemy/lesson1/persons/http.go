@@ -0,0 +1,151 @@
+package persons
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/mylib"
+	"golang_udemy/lesson1/reply"
+)
+
+// ActorFunc works out who is making a change. universe.OwnerFunc and
+// account.Owner fit.
+type ActorFunc func(r *http.Request) (mylib.Person, error)
+
+// NewHandler serves persons and their history:
+//
+//	GET    /persons?deleted=1
+//	POST   /persons               {"name": ..., "age": ...}
+//	GET    /persons/search?q=&limit=20
+//	GET    /persons/changes?since=2006-01-02T15:04:05Z
+//	GET    /persons/{id}?as_of=2006-01-02T15:04:05Z
+//	PUT    /persons/{id}          {"name": ..., "age": ...}
+//	DELETE /persons/{id}
+//	POST   /persons/{id}/restore
+//	GET    /persons/{id}/history
+func NewHandler(repo *Repository, actor ActorFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /persons", func(w http.ResponseWriter, r *http.Request) {
+		list, err := repo.List(r.URL.Query().Get("deleted") != "")
+		reply.JSON(w, list, err, codes...)
+	})
+	mux.HandleFunc("GET /persons/search", func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if s := r.URL.Query().Get("limit"); s != "" {
+			var err error
+			if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+				http.Error(w, "bad limit", http.StatusBadRequest)
+				return
+			}
+		}
+		hits, err := repo.Search(r.URL.Query().Get("q"), limit)
+		reply.JSON(w, hits, err, codes...)
+	})
+	mux.HandleFunc("GET /persons/changes", func(w http.ResponseWriter, r *http.Request) {
+		since, err := parseTime(r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list, err := repo.ListChanges(since)
+		reply.JSON(w, list, err, codes...)
+	})
+	mux.HandleFunc("GET /persons/{id}", withID(func(w http.ResponseWriter, r *http.Request, id int64) {
+		asOf := r.URL.Query().Get("as_of")
+		if asOf == "" {
+			rec, err := repo.Get(id)
+			reply.JSON(w, rec, err, codes...)
+			return
+		}
+		t, err := parseTime(asOf)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec, err := repo.GetAsOf(id, t)
+		reply.JSON(w, rec, err, codes...)
+	}))
+	mux.HandleFunc("GET /persons/{id}/history", withID(func(w http.ResponseWriter, r *http.Request, id int64) {
+		list, err := repo.History(id)
+		if err == nil && len(list) == 0 {
+			err = ErrNotFound
+		}
+		reply.JSON(w, list, err, codes...)
+	}))
+
+	mux.HandleFunc("POST /persons", func(w http.ResponseWriter, r *http.Request) {
+		by, p, ok := read(w, r, actor)
+		if ok {
+			rec, err := repo.Create(p, by)
+			reply.JSON(w, rec, err, codes...)
+		}
+	})
+	mux.HandleFunc("PUT /persons/{id}", withID(func(w http.ResponseWriter, r *http.Request, id int64) {
+		by, p, ok := read(w, r, actor)
+		if ok {
+			rec, err := repo.Update(id, p, by)
+			reply.JSON(w, rec, err, codes...)
+		}
+	}))
+	mux.HandleFunc("DELETE /persons/{id}", withID(func(w http.ResponseWriter, r *http.Request, id int64) {
+		who, err := actor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		reply.JSON(w, nil, repo.Delete(id, who.Name), codes...)
+	}))
+	mux.HandleFunc("POST /persons/{id}/restore", withID(func(w http.ResponseWriter, r *http.Request, id int64) {
+		who, err := actor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		rec, err := repo.Restore(id, who.Name)
+		reply.JSON(w, rec, err, codes...)
+	}))
+	return mux
+}
+
+func withID(h func(w http.ResponseWriter, r *http.Request, id int64)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad person id", http.StatusBadRequest)
+			return
+		}
+		h(w, r, id)
+	}
+}
+
+// read returns the actor and the person in the request body.
+func read(w http.ResponseWriter, r *http.Request, actor ActorFunc) (string, mylib.Person, bool) {
+	who, err := actor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return "", mylib.Person{}, false
+	}
+	var p mylib.Person
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", p, false
+	}
+	return who.Name, p, true
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// codes are the statuses of the errors the handlers return.
+var codes = []reply.Code{
+	{Err: ErrNotFound, Status: http.StatusNotFound},
+	{Err: ErrDeleted, Status: http.StatusNotFound},
+	{Err: ErrNotDeleted, Status: http.StatusConflict},
+	{Err: ErrNoActor, Status: http.StatusBadRequest},
+}
@@ -0,0 +1,360 @@
+/*
+persons is the repository for the persons table.
+
+Every change writes a row to person_history holding who made it, when,
+and the person as it stood afterwards, so any earlier state can be read
+back. Deletes are soft: the row is only marked and can be restored.
+
+Name search uses SQLite FTS5 when built with -tags sqlite_fts5, and a
+plain LIKE scan otherwise. Fuzzy and diacritic-insensitive matching
+need the FTS5 build; substring matching also needs SQLite 3.34 or
+later, see setupSearch.
+*/
+package persons
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang_udemy/lesson1/metrics"
+	"golang_udemy/lesson1/mylib"
+)
+
+var (
+	ErrNotFound   = errors.New("persons: not found")
+	ErrDeleted    = errors.New("persons: deleted")
+	ErrNotDeleted = errors.New("persons: not deleted")
+	// ErrNoActor is returned for a change nobody is named as making.
+	ErrNoActor = errors.New("persons: no actor to record the change as made by")
+)
+
+// queryDuration times each repository method, database calls and all.
+var queryDuration = metrics.Default.NewHistogram("persons_query_duration_seconds",
+	"Time persons repository calls spent on the database, by method.", metrics.DefBuckets, "op")
+
+// Op is the kind of change a history row records.
+type Op string
+
+const (
+	Created  Op = "create"
+	Updated  Op = "update"
+	Deleted  Op = "delete"
+	Restored Op = "restore"
+)
+
+// Record is a stored person.
+type Record struct {
+	ID int64 `json:"id"`
+	mylib.Person
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Change is one row of a person's history.
+type Change struct {
+	Version int64     `json:"version"`
+	Op      Op        `json:"op"`
+	Record  Record    `json:"record"`
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+}
+
+// Repository reads and writes persons and their history.
+type Repository struct {
+	db  *sql.DB
+	now func() time.Time
+	// trigram is set when the FTS5 build has a persons_trigram index
+	trigram bool
+}
+
+// NewRepository creates the persons and person_history tables in db if
+// needed. A persons table from before history was kept is given ids and
+// a history row dated at the Unix epoch for each person.
+func NewRepository(db *sql.DB) (*Repository, error) {
+	r := &Repository{db: db, now: time.Now}
+	if err := r.migrate(); err != nil {
+		return nil, fmt.Errorf("persons: migrate: %w", err)
+	}
+	if err := r.setupSearch(); err != nil {
+		return nil, fmt.Errorf("persons: search: %w", err)
+	}
+	return r, nil
+}
+
+func (r *Repository) migrate() error {
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS persons(
+			id INTEGER PRIMARY KEY,
+			name TEXT,
+			age INT,
+			deleted INT NOT NULL DEFAULT 0);
+		CREATE TABLE IF NOT EXISTS person_history(
+			version INTEGER PRIMARY KEY,
+			person_id INT,
+			op TEXT,
+			name TEXT,
+			age INT,
+			deleted INT,
+			changed_by TEXT,
+			changed_at INT);
+		CREATE INDEX IF NOT EXISTS person_history_at ON person_history(changed_at)`)
+	if err != nil {
+		return err
+	}
+	var n int
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('persons') WHERE name = 'id'`).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if err := r.addIDs(); err != nil {
+			return err
+		}
+	}
+	// see dbadmin.SchemaVersion
+	var v int
+	if err := r.db.QueryRow(`PRAGMA user_version`).Scan(&v); err != nil || v >= 1 {
+		return err
+	}
+	_, err = r.db.Exec(`PRAGMA user_version = 1`)
+	return err
+}
+
+// addIDs rebuilds a persons table from before history was kept.
+func (r *Repository) addIDs() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`CREATE TABLE persons_new(
+			id INTEGER PRIMARY KEY,
+			name TEXT,
+			age INT,
+			deleted INT NOT NULL DEFAULT 0)`,
+		`INSERT INTO persons_new(id, name, age) SELECT rowid, name, age FROM persons`,
+		`DROP TABLE persons`,
+		`ALTER TABLE persons_new RENAME TO persons`,
+		`INSERT INTO person_history(person_id, op, name, age, deleted, changed_by, changed_at)
+			SELECT id, 'create', name, age, 0, 'migration', 0 FROM persons`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Create adds p on behalf of by.
+func (r *Repository) Create(p mylib.Person, by string) (Record, error) {
+	defer queryDuration.Since(time.Now(), "create")
+	tx, err := r.db.Begin()
+	if err != nil {
+		return Record{}, err
+	}
+	defer tx.Rollback()
+	rec, err := r.create(tx, p, by)
+	if err != nil {
+		return Record{}, err
+	}
+	return rec, tx.Commit()
+}
+
+// CreateTx adds p on behalf of by as part of tx, for callers that
+// write other rows along with the person.
+func (r *Repository) CreateTx(tx *sql.Tx, p mylib.Person, by string) (Record, error) {
+	defer queryDuration.Since(time.Now(), "create")
+	return r.create(tx, p, by)
+}
+
+func (r *Repository) create(tx *sql.Tx, p mylib.Person, by string) (Record, error) {
+	res, err := tx.Exec(`INSERT INTO persons(name, age) VALUES(?, ?)`, p.Name, p.Age)
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Person: p}
+	if rec.ID, err = res.LastInsertId(); err != nil {
+		return Record{}, err
+	}
+	return rec, r.record(tx, Created, rec, by)
+}
+
+// Update replaces the person id with p on behalf of by.
+func (r *Repository) Update(id int64, p mylib.Person, by string) (Record, error) {
+	defer queryDuration.Since(time.Now(), "update")
+	return r.change(id, Updated, by, func(rec *Record) error {
+		if rec.Deleted {
+			return fmt.Errorf("%w: %d", ErrDeleted, id)
+		}
+		rec.Person = p
+		return nil
+	})
+}
+
+// Delete marks the person id as deleted on behalf of by.
+func (r *Repository) Delete(id int64, by string) error {
+	defer queryDuration.Since(time.Now(), "delete")
+	_, err := r.change(id, Deleted, by, func(rec *Record) error {
+		if rec.Deleted {
+			return fmt.Errorf("%w: %d", ErrDeleted, id)
+		}
+		rec.Deleted = true
+		return nil
+	})
+	return err
+}
+
+// Restore undoes the deletion of the person id on behalf of by.
+func (r *Repository) Restore(id int64, by string) (Record, error) {
+	defer queryDuration.Since(time.Now(), "restore")
+	return r.change(id, Restored, by, func(rec *Record) error {
+		if !rec.Deleted {
+			return fmt.Errorf("%w: %d", ErrNotDeleted, id)
+		}
+		rec.Deleted = false
+		return nil
+	})
+}
+
+// change applies f to the person id and saves and records the result.
+func (r *Repository) change(id int64, op Op, by string, f func(*Record) error) (Record, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return Record{}, err
+	}
+	defer tx.Rollback()
+	rec := Record{ID: id}
+	err = tx.QueryRow(`SELECT name, age, deleted FROM persons WHERE id = ?`, id).Scan(&rec.Name, &rec.Age, &rec.Deleted)
+	if err == sql.ErrNoRows {
+		return Record{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	if err := f(&rec); err != nil {
+		return Record{}, err
+	}
+	_, err = tx.Exec(`UPDATE persons SET name = ?, age = ?, deleted = ? WHERE id = ?`, rec.Name, rec.Age, rec.Deleted, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := r.record(tx, op, rec, by); err != nil {
+		return Record{}, err
+	}
+	return rec, tx.Commit()
+}
+
+func (r *Repository) record(tx *sql.Tx, op Op, rec Record, by string) error {
+	if by == "" {
+		return ErrNoActor
+	}
+	_, err := tx.Exec(`INSERT INTO person_history(person_id, op, name, age, deleted, changed_by, changed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`, rec.ID, op, rec.Name, rec.Age, rec.Deleted, by, r.now().UnixNano())
+	return err
+}
+
+// Get returns the person id unless it is deleted.
+func (r *Repository) Get(id int64) (Record, error) {
+	defer queryDuration.Since(time.Now(), "get")
+	list, err := r.list(`WHERE id = ?`, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(list) == 0 {
+		return Record{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	if list[0].Deleted {
+		return list[0], fmt.Errorf("%w: %d", ErrDeleted, id)
+	}
+	return list[0], nil
+}
+
+// ByName returns the first live person called name.
+func (r *Repository) ByName(name string) (Record, error) {
+	defer queryDuration.Since(time.Now(), "by_name")
+	list, err := r.list(`WHERE name = ? AND deleted = 0`, name)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(list) == 0 {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return list[0], nil
+}
+
+// List returns the persons, with the deleted ones if withDeleted is set.
+func (r *Repository) List(withDeleted bool) ([]Record, error) {
+	defer queryDuration.Since(time.Now(), "list")
+	if withDeleted {
+		return r.list(``)
+	}
+	return r.list(`WHERE deleted = 0`)
+}
+
+func (r *Repository) list(where string, args ...interface{}) ([]Record, error) {
+	rows, err := r.db.Query(`SELECT id, name, age, deleted FROM persons `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Record
+	for rows.Next() {
+		var rec Record
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Age, &rec.Deleted); err != nil {
+			return nil, err
+		}
+		list = append(list, rec)
+	}
+	return list, rows.Err()
+}
+
+// GetAsOf returns the person id as it stood at t.
+func (r *Repository) GetAsOf(id int64, t time.Time) (Record, error) {
+	defer queryDuration.Since(time.Now(), "get_as_of")
+	changes, err := r.changes(`WHERE person_id = ? AND changed_at <= ? ORDER BY version DESC LIMIT 1`, id, t.UnixNano())
+	if err != nil {
+		return Record{}, err
+	}
+	if len(changes) == 0 {
+		return Record{}, fmt.Errorf("%w: %d at %s", ErrNotFound, id, t.Format(time.RFC3339))
+	}
+	rec := changes[0].Record
+	if rec.Deleted {
+		return rec, fmt.Errorf("%w: %d at %s", ErrDeleted, id, t.Format(time.RFC3339))
+	}
+	return rec, nil
+}
+
+// History returns every change of the person id, oldest first.
+func (r *Repository) History(id int64) ([]Change, error) {
+	defer queryDuration.Since(time.Now(), "history")
+	return r.changes(`WHERE person_id = ? ORDER BY version`, id)
+}
+
+// ListChanges returns every change made at or after since, oldest first.
+func (r *Repository) ListChanges(since time.Time) ([]Change, error) {
+	defer queryDuration.Since(time.Now(), "list_changes")
+	return r.changes(`WHERE changed_at >= ? ORDER BY version`, since.UnixNano())
+}
+
+func (r *Repository) changes(where string, args ...interface{}) ([]Change, error) {
+	rows, err := r.db.Query(`SELECT version, person_id, op, name, age, deleted, changed_by, changed_at
+		FROM person_history `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Change
+	for rows.Next() {
+		var c Change
+		var at int64
+		if err := rows.Scan(&c.Version, &c.Record.ID, &c.Op, &c.Record.Name, &c.Record.Age, &c.Record.Deleted, &c.By, &at); err != nil {
+			return nil, err
+		}
+		c.At = time.Unix(0, at)
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,120 @@
+package persons
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/mylib"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func open(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestHistory(t *testing.T) {
+	repo, err := NewRepository(open(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { clock = clock.Add(time.Hour); return clock }
+
+	rec, _ := repo.Create(mylib.Person{Name: "Mike", Age: 20}, "admin")
+	repo.Update(rec.ID, mylib.Person{Name: "Mike", Age: 21}, "mike")
+	repo.Delete(rec.ID, "admin")
+
+	if _, err := repo.Get(rec.ID); !errors.Is(err, ErrDeleted) {
+		t.Error("Expected deleted, got", err)
+	}
+	if list, _ := repo.List(false); len(list) != 0 {
+		t.Error("Expected no live persons, got", list)
+	}
+	if got, err := repo.GetAsOf(rec.ID, time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC)); err != nil || got.Age != 20 {
+		t.Error("Expected age 20 at 01:30, got", got, err)
+	}
+	if _, err := repo.GetAsOf(rec.ID, time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)); !errors.Is(err, ErrNotFound) {
+		t.Error("Expected nobody before creation, got", err)
+	}
+	if _, err := repo.Restore(rec.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Get(rec.ID); got.Age != 21 {
+		t.Error("Expected restored with age 21, got", got)
+	}
+
+	changes, _ := repo.ListChanges(time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC))
+	var ops []string
+	for _, c := range changes {
+		ops = append(ops, string(c.Op)+":"+c.By)
+	}
+	if strings.Join(ops, " ") != "update:mike delete:admin restore:admin" {
+		t.Error("Unexpected changes", ops)
+	}
+
+	if _, err := repo.Create(mylib.Person{Name: "Nobody"}, ""); !errors.Is(err, ErrNoActor) {
+		t.Error("Expected a change without actor to fail, got", err)
+	}
+	if _, err := repo.Update(rec.ID, mylib.Person{Name: "Mike", Age: 99}, ""); !errors.Is(err, ErrNoActor) {
+		t.Error("Expected an update without actor to fail, got", err)
+	}
+	if got, _ := repo.Get(rec.ID); got.Age != 21 {
+		t.Error("Expected the update without actor to be rolled back, got", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := open(t)
+	db.Exec(`CREATE TABLE persons(name STRING, age INT); INSERT INTO persons VALUES('Nancy', 30), ('Mike', 20)`)
+	repo, err := NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := repo.List(false)
+	if len(list) != 2 || list[1].ID != 2 || list[1].Name != "Mike" {
+		t.Error("Expected migrated persons, got", list)
+	}
+	if got, err := repo.GetAsOf(1, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil || got.Name != "Nancy" {
+		t.Error("Expected Nancy to predate the history, got", got, err)
+	}
+	// a second open leaves the table alone
+	if _, err := NewRepository(db); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	repo, _ := NewRepository(open(t))
+	actor := func(r *http.Request) (mylib.Person, error) { return mylib.Person{Name: "admin"}, nil }
+	h := NewHandler(repo, actor)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	if w := do("POST", "/persons", `{"name": "Nancy", "age": 30}`); w.Code != http.StatusOK {
+		t.Fatal("Expected created, got", w.Code, w.Body)
+	}
+	do("DELETE", "/persons/1", "")
+	if w := do("GET", "/persons/1", ""); w.Code != http.StatusNotFound {
+		t.Error("Expected deleted person to be gone, got", w.Code)
+	}
+	if w := do("POST", "/persons/1/restore", ""); w.Code != http.StatusOK {
+		t.Error("Expected restore, got", w.Code)
+	}
+	w := do("GET", "/persons/1/history", "")
+	if !strings.Contains(w.Body.String(), `"op":"delete"`) || !strings.Contains(w.Body.String(), `"by":"admin"`) {
+		t.Error("Unexpected history", w.Body)
+	}
+}
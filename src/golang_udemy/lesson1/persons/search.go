@@ -0,0 +1,23 @@
+package persons
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Hit is a person matching a search.
+type Hit struct {
+	Record
+	// Rank orders hits within one search; higher is better.
+	Rank float64 `json:"rank"`
+	// Snippet is the name, escaped for HTML, with the matching parts
+	// in <b></b>.
+	Snippet string `json:"snippet"`
+}
+
+// terms splits a search query into lower-cased words.
+func terms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
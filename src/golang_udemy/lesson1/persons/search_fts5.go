@@ -0,0 +1,257 @@
+//go:build sqlite_fts5 || fts5
+
+package persons
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// createTerms creates the table of the words in persons_fts, case and
+// diacritics folded, for databases indexed before it was added too.
+const createTerms = `CREATE VIRTUAL TABLE IF NOT EXISTS persons_terms USING fts5vocab(persons_fts, row)`
+
+// setupSearch creates the FTS5 indexes over persons and the triggers
+// that keep them in sync. persons_fts matches whole words and prefixes,
+// folding case and diacritics, and persons_terms lists the words it
+// holds for fuzzy search; persons_trigram matches any three letters,
+// which makes substring search possible.
+//
+// The trigram tokenizer needs SQLite 3.34 and folds diacritics only from
+// 3.45. Against an older SQLite persons_trigram is built without folding,
+// or not at all before 3.34, in which case Search matches words and
+// prefixes only.
+func (r *Repository) setupSearch() error {
+	var n int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'persons_fts'`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		if _, err := r.db.Exec(createTerms); err != nil {
+			return err
+		}
+		err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'persons_trigram'`).Scan(&n)
+		r.trigram = n > 0
+		return err
+	}
+	var version string
+	if err := r.db.QueryRow(`SELECT sqlite_version()`).Scan(&version); err != nil {
+		return err
+	}
+	var major, minor int
+	fmt.Sscanf(version, "%d.%d", &major, &minor)
+	tokenize := ""
+	switch {
+	case major > 3 || minor >= 45:
+		tokenize = "trigram remove_diacritics 1"
+	case minor >= 34:
+		tokenize = "trigram"
+	}
+	r.trigram = tokenize != ""
+
+	tables := []string{"persons_fts"}
+	stmts := []string{`CREATE VIRTUAL TABLE persons_fts USING fts5(name, content='persons', content_rowid='id',
+			tokenize='unicode61 remove_diacritics 2', prefix='2 3')`, createTerms}
+	if r.trigram {
+		tables = append(tables, "persons_trigram")
+		stmts = append(stmts, `CREATE VIRTUAL TABLE persons_trigram USING fts5(name, content='persons', content_rowid='id',
+			tokenize='`+tokenize+`')`)
+	}
+	var insert, remove []string
+	for _, t := range tables {
+		insert = append(insert, fmt.Sprintf(`INSERT INTO %[1]s(rowid, name) VALUES(new.id, new.name);`, t))
+		remove = append(remove, fmt.Sprintf(`INSERT INTO %[1]s(%[1]s, rowid, name) VALUES('delete', old.id, old.name);`, t))
+	}
+	stmts = append(stmts,
+		`CREATE TRIGGER persons_ai AFTER INSERT ON persons BEGIN `+strings.Join(insert, " ")+` END`,
+		`CREATE TRIGGER persons_ad AFTER DELETE ON persons BEGIN `+strings.Join(remove, " ")+` END`,
+		`CREATE TRIGGER persons_au AFTER UPDATE OF name ON persons BEGIN `+
+			strings.Join(remove, " ")+" "+strings.Join(insert, " ")+` END`)
+	for _, t := range tables {
+		stmts = append(stmts, fmt.Sprintf(`INSERT INTO %[1]s(%[1]s) VALUES('rebuild')`, t))
+	}
+	_, err := r.db.Exec(strings.Join(stmts, ";\n"))
+	return err
+}
+
+// Search finds live persons by name, filling up to limit hits in three
+// rounds that each rank below the one before:
+//
+//   - names with every word of q as a word or word prefix, best ranked
+//     first;
+//   - names with, for every word of q, a word within a typo or two of it
+//     (see maxEdits), so "jse" finds José;
+//   - names containing every word of q of three letters or more, such
+//     as Nancy for "ancy", when the trigram index is there.
+func (r *Repository) Search(q string, limit int) ([]Hit, error) {
+	defer queryDuration.Since(time.Now(), "search")
+	words := terms(q)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	var prefix, fuzzy, substring []string
+	for _, w := range words {
+		prefix = append(prefix, quoteTerm(w)+"*")
+		runes := []rune(w)
+		for i := 0; i+3 <= len(runes); i++ {
+			substring = append(substring, quoteTerm(string(runes[i:i+3])))
+		}
+	}
+	hits, err := r.match("persons_fts", strings.Join(prefix, " AND "), limit, nil)
+	if err != nil || len(hits) >= limit {
+		return hits, err
+	}
+	seen := map[int64]bool{}
+	for _, h := range hits {
+		seen[h.ID] = true
+	}
+
+	for _, w := range words {
+		near, err := r.similar(w)
+		if err != nil {
+			return nil, err
+		}
+		if len(near) == 0 {
+			fuzzy = nil
+			break
+		}
+		for i := range near {
+			near[i] = quoteTerm(near[i])
+		}
+		fuzzy = append(fuzzy, "("+strings.Join(near, " OR ")+")")
+	}
+	if len(fuzzy) > 0 {
+		more, err := r.match("persons_fts", strings.Join(fuzzy, " AND "), limit-len(hits), seen)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range more {
+			seen[h.ID] = true
+		}
+		hits = append(hits, more...)
+	}
+
+	if len(hits) >= limit || len(substring) == 0 || !r.trigram {
+		return hits, nil
+	}
+	more, err := r.match("persons_trigram", strings.Join(substring, " AND "), limit-len(hits), seen)
+	return append(hits, more...), err
+}
+
+// maxTerms caps how many similar words a fuzzy search tries per word.
+const maxTerms = 20
+
+// similar returns the indexed words within maxEdits of w, closest first.
+// It reads every word of about the right length, which is cheap next to
+// the names themselves as names share most of their words.
+func (r *Repository) similar(w string) ([]string, error) {
+	n, max := len([]rune(w)), maxEdits(w)
+	if max == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.Query(`SELECT term FROM persons_terms WHERE length(term) BETWEEN ? AND ?`, n-max, n+max)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type near struct {
+		term string
+		d    int
+	}
+	var list []near
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		if d := distance(w, term); d <= max {
+			list = append(list, near{term, d})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].d < list[j].d })
+	var out []string
+	for _, t := range list[:min(len(list), maxTerms)] {
+		out = append(out, t.term)
+	}
+	return out, nil
+}
+
+// maxEdits is how many typos a word of w's length may have and still
+// match: none for one or two letters, one up to five and two beyond.
+func maxEdits(w string) int {
+	switch n := len([]rune(w)); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// distance is the number of letters inserted, deleted, replaced or
+// swapped with a neighbour that turns a into b.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func (r *Repository) match(table, query string, limit int, skip map[int64]bool) ([]Hit, error) {
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT p.id, p.name, p.age, -bm25(%[1]s), snippet(%[1]s, 0, char(2), char(3), '…', 16)
+		FROM %[1]s JOIN persons p ON p.id = %[1]s.rowid
+		WHERE %[1]s MATCH ? AND p.deleted = 0
+		ORDER BY bm25(%[1]s), p.id LIMIT ?`, table), query, limit+len(skip))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hits []Hit
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.ID, &h.Name, &h.Age, &h.Rank, &h.Snippet); err != nil {
+			return nil, err
+		}
+		h.Snippet = markup(h.Snippet)
+		if !skip[h.ID] && len(hits) < limit {
+			hits = append(hits, h)
+		}
+	}
+	return hits, rows.Err()
+}
+
+// markup escapes a snippet() result for HTML and turns its \x02 and
+// \x03 markers into <b></b>.
+func markup(s string) string {
+	return strings.NewReplacer("\x02", "<b>", "\x03", "</b>").Replace(html.EscapeString(s))
+}
+
+// quoteTerm makes s a literal FTS5 string.
+func quoteTerm(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,56 @@
+//go:build sqlite_fts5 || fts5
+
+package persons
+
+import (
+	"testing"
+
+	"golang_udemy/lesson1/mylib"
+)
+
+func TestFuzzySearch(t *testing.T) {
+	repo, _ := NewRepository(open(t))
+	for _, name := range []string{"Zoë Müller", "Jürgen Schmidt", "Nancy", "José Díaz", "Joan Baez"} {
+		repo.Create(mylib.Person{Name: name}, "test")
+	}
+	if hits, _ := repo.Search("zoe muller", 10); len(hits) != 1 || hits[0].Snippet != "<b>Zoë</b> <b>Müller</b>" {
+		t.Error("Expected diacritic-insensitive match, got", hits)
+	}
+	if hits, _ := repo.Search("schmitt", 10); len(hits) == 0 || hits[0].Name != "Jürgen Schmidt" {
+		t.Error("Expected fuzzy match on Schmidt, got", hits)
+	}
+	if hits, _ := repo.Search("ancy", 10); len(hits) != 1 || hits[0].Name != "Nancy" {
+		t.Error("Expected substring match on Nancy, got", hits)
+	}
+	if hits, _ := repo.Search("jse", 10); len(hits) != 1 || hits[0].Name != "José Díaz" {
+		t.Error("Expected a one-letter misspelling to find José, got", hits)
+	}
+	if hits, _ := repo.Search("jose diaz", 10); len(hits) != 1 || hits[0].Name != "José Díaz" {
+		t.Error("Expected José Díaz without diacritics, got", hits)
+	}
+	if hits, _ := repo.Search("schmidt zzz", 10); len(hits) != 0 {
+		t.Error("Expected every word to have to match, got", hits)
+	}
+	// a shared three-letter run is not enough
+	if hits, _ := repo.Search("nancymiller", 10); len(hits) != 0 {
+		t.Error("Expected no match on a single shared trigram, got", hits)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"jse", "jose", 1},
+		{"schmitt", "schmidt", 1},
+		{"joes", "jose", 1},
+		{"müller", "muller", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	} {
+		if got := distance(c.a, c.b); got != c.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
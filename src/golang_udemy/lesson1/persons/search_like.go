@@ -0,0 +1,106 @@
+//go:build !(sqlite_fts5 || fts5)
+
+package persons
+
+import (
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// setupSearch has nothing to set up without FTS5, but refuses a
+// database indexed by an FTS5 build: its triggers would make every
+// write to persons fail.
+func (r *Repository) setupSearch() error {
+	var n int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'persons_fts'`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return errors.New("database has an FTS5 index; build with -tags sqlite_fts5")
+	}
+	return nil
+}
+
+// Search finds live persons whose name contains every word of q,
+// ignoring ASCII case. Whole names rank above prefixes, which rank above
+// other matches. Build with the sqlite_fts5 tag for indexed, fuzzy and
+// diacritic-insensitive search.
+func (r *Repository) Search(q string, limit int) ([]Hit, error) {
+	defer queryDuration.Since(time.Now(), "search")
+	words := terms(q)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	var where []string
+	var args []interface{}
+	for _, w := range words {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(w)+"%")
+	}
+	list, err := r.list(`WHERE deleted = 0 AND `+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, len(list))
+	for i, rec := range list {
+		hits[i] = Hit{Record: rec, Rank: rank(rec.Name, words), Snippet: highlight(rec.Name, words)}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Rank > hits[j].Rank })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+func rank(name string, words []string) float64 {
+	name = asciiLower(name)
+	switch {
+	case name == strings.Join(words, " "):
+		return 3
+	case strings.HasPrefix(name, words[0]):
+		return 2
+	}
+	return 1
+}
+
+// highlight escapes name for HTML and wraps the first match of each
+// word in <b></b>.
+func highlight(name string, words []string) string {
+	lower := asciiLower(name)
+	marked := make([]bool, len(name))
+	for _, w := range words {
+		if i := strings.Index(lower, w); i >= 0 {
+			for k := i; k < i+len(w); k++ {
+				marked[k] = true
+			}
+		}
+	}
+	var b strings.Builder
+	for start := 0; start < len(name); {
+		end := start + 1
+		for end < len(name) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			b.WriteString("<b>" + html.EscapeString(name[start:end]) + "</b>")
+		} else {
+			b.WriteString(html.EscapeString(name[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// asciiLower folds case like LIKE does, keeping byte offsets intact.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
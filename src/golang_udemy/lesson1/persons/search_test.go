@@ -0,0 +1,49 @@
+package persons
+
+import (
+	"strings"
+	"testing"
+
+	"golang_udemy/lesson1/mylib"
+)
+
+func TestSearch(t *testing.T) {
+	repo, err := NewRepository(open(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Nancy Smith", "Nan", "Anna Nanami", "Bob", "<script>nan</script>"} {
+		repo.Create(mylib.Person{Name: name}, "test")
+	}
+	hits, err := repo.Search("nan", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) < 3 || hits[0].Name != "Nan" {
+		t.Fatal("Expected Nan first of three, got", hits)
+	}
+	if hits[0].Snippet != "<b>Nan</b>" {
+		t.Error("Unexpected snippet", hits[0].Snippet)
+	}
+	for _, h := range hits {
+		if strings.Contains(h.Snippet, "<script>") {
+			t.Error("Expected the snippet to be escaped, got", h.Snippet)
+		}
+	}
+
+	bob, _ := repo.ByName("Bob")
+	repo.Update(bob.ID, mylib.Person{Name: "Robert"}, "test")
+	if hits, _ := repo.Search("bob", 10); len(hits) != 0 {
+		t.Error("Expected renamed Bob to be gone, got", hits)
+	}
+	if hits, _ := repo.Search("rob", 10); len(hits) != 1 {
+		t.Error("Expected Robert by prefix, got", hits)
+	}
+	repo.Delete(bob.ID, "test")
+	if hits, _ := repo.Search("robert", 10); len(hits) != 0 {
+		t.Error("Expected deleted person to be hidden, got", hits)
+	}
+	if hits, _ := repo.Search("nancy smith", 1); len(hits) != 1 || hits[0].Name != "Nancy Smith" {
+		t.Error("Expected Nancy Smith, got", hits)
+	}
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"sync"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Breaker stops calling a provider for Cooldown once Threshold fetches
+// in a row have failed. After the cooldown it lets a single trial fetch
+// through: success closes the circuit again, failure reopens it for
+// another Cooldown. Unknown symbols do not count as failures.
+type Breaker struct {
+	Provider
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+	// trial is set while the half-open circuit waits on its trial fetch
+	trial bool
+	now   func() time.Time
+}
+
+// WithBreaker wraps p in a circuit breaker.
+func WithBreaker(p Provider, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Provider: p, Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+func (b *Breaker) Fetch(r Request) (quote.Quote, error) {
+	b.mu.Lock()
+	trial := false
+	if b.open {
+		if b.trial || b.now().Before(b.openUntil) {
+			b.mu.Unlock()
+			return quote.NewQuote("", 0), &FetchError{b.Name(), r.Symbol, ErrCircuitOpen}
+		}
+		b.trial, trial = true, true
+	}
+	b.mu.Unlock()
+
+	q, err := b.Provider.Fetch(r)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+	if err == nil || IsNotFound(err) {
+		b.failures = 0
+		if trial {
+			b.open = false
+		}
+		return q, err
+	}
+	b.failures++
+	if trial || b.failures >= b.Threshold {
+		b.failures = 0
+		b.open = true
+		b.openUntil = b.now().Add(b.Cooldown)
+	}
+	return q, err
+}
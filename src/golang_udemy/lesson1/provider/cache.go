@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+type cached struct {
+	Provider
+	dir string
+	now func() time.Time
+}
+
+// Cached keeps successful responses of p as JSON files under dir,
+// keyed by provider, symbol, period and time range. Ranges reaching
+// today or later are always fetched, since their last bars can still
+// change.
+func Cached(p Provider, dir string) Provider {
+	return cached{p, dir, time.Now}
+}
+
+func (c cached) path(r Request) string {
+	name := fmt.Sprintf("%s_%s_%s_%s.json",
+		strings.NewReplacer("/", "-", "\\", "-").Replace(r.Symbol), r.Period,
+		r.From.Format("20060102T1504"), r.To.Format("20060102T1504"))
+	return filepath.Join(c.dir, c.Name(), name)
+}
+
+func (c cached) Fetch(r Request) (quote.Quote, error) {
+	if r.To.Format("20060102") >= c.now().In(r.To.Location()).Format("20060102") {
+		return c.Provider.Fetch(r)
+	}
+	path := c.path(r)
+	if q, err := quote.NewQuoteFromJSONFile(path); err == nil {
+		return q, nil
+	}
+	q, err := c.Provider.Fetch(r)
+	if err != nil {
+		return q, err
+	}
+	// the quote is good even if it cannot be cached
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("provider: cache %s: %v", path, err)
+	} else if err := q.WriteJSON(path, false); err != nil {
+		log.Printf("provider: cache %s: %v", path, err)
+	}
+	return q, nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Bucket is a token bucket refilled at Rate tokens per second up to
+// Burst. A Rate of zero or less does not limit at all.
+type Bucket struct {
+	Rate  float64
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// ErrBadBucket is returned by NewBucket for a limiting bucket that could
+// never hold a whole token, on which Wait would block forever.
+var ErrBadBucket = errors.New("provider: bucket with a rate needs a burst of at least 1")
+
+// NewBucket returns a full bucket.
+func NewBucket(rate float64, burst int) (*Bucket, error) {
+	if rate > 0 && burst < 1 {
+		return nil, ErrBadBucket
+	}
+	return &Bucket{Rate: rate, Burst: burst, tokens: float64(burst), now: time.Now, sleep: time.Sleep}, nil
+}
+
+// Wait blocks until a token is available and takes it.
+func (b *Bucket) Wait() {
+	for {
+		d := b.reserve()
+		if d == 0 {
+			return
+		}
+		b.sleep(d)
+	}
+}
+
+// reserve takes a token, or returns how long to wait for one.
+func (b *Bucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Rate <= 0 {
+		return 0
+	}
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.Rate
+		if b.tokens > float64(b.Burst) {
+			b.tokens = float64(b.Burst)
+		}
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.Rate * float64(time.Second))
+}
+
+type limited struct {
+	Provider
+	bucket *Bucket
+}
+
+// RateLimited makes p take a token from b before every fetch.
+// Share b between providers that hit the same API.
+func RateLimited(p Provider, b *Bucket) Provider {
+	return limited{p, b}
+}
+
+func (l limited) Fetch(r Request) (quote.Quote, error) {
+	l.bucket.Wait()
+	return l.Provider.Fetch(r)
+}
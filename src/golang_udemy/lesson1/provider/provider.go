@@ -0,0 +1,99 @@
+/*
+provider puts go-quote's data sources behind one interface and adds
+rate limiting, an on-disk cache and circuit breaking around them.
+*/
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Request asks for the bars of Symbol at Period between From and To.
+type Request struct {
+	Symbol string
+	Period quote.Period
+	From   time.Time
+	To     time.Time
+}
+
+// Provider fetches candles from a data source.
+type Provider interface {
+	Name() string
+	Fetch(r Request) (quote.Quote, error)
+}
+
+// ErrCircuitOpen is returned while a provider is being given a rest
+// after repeated failures.
+var ErrCircuitOpen = errors.New("provider: circuit open")
+
+// FetchError wraps a failure of a provider for one symbol.
+// A symbol the source does not know unwraps to *quote.SymbolNotFoundError.
+type FetchError struct {
+	Provider string
+	Symbol   string
+	Err      error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("%s: fetch %s: %v", e.Provider, e.Symbol, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether err means the symbol does not exist.
+func IsNotFound(err error) bool {
+	var nf *quote.SymbolNotFoundError
+	return errors.As(err, &nf)
+}
+
+// dateTime is the layout quote.ParseDateString reads.
+const dateTime = "2006-01-02 15:04"
+
+// Func adapts a go-quote fetch function to a Provider.
+type Func struct {
+	ProviderName string
+	Get          func(symbol, start, end string, period quote.Period) (quote.Quote, error)
+}
+
+func (f Func) Name() string {
+	return f.ProviderName
+}
+
+// Fetch calls Get and turns go-quote's silent empty result into an error.
+// From and To keep their time of day, so intraday requests reach the
+// source as asked.
+func (f Func) Fetch(r Request) (quote.Quote, error) {
+	q, err := f.Get(r.Symbol, r.From.Format(dateTime), r.To.Format(dateTime), r.Period)
+	if err == nil && len(q.Close) == 0 {
+		err = &quote.SymbolNotFoundError{Symbol: r.Symbol}
+	}
+	if err != nil {
+		return q, &FetchError{f.ProviderName, r.Symbol, err}
+	}
+	return q, nil
+}
+
+// Tiingo returns the Tiingo daily equity provider.
+func Tiingo(token string) Provider {
+	return Func{"tiingo", func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		return quote.NewQuoteFromTiingo(symbol, start, end, period, token)
+	}}
+}
+
+// TiingoCrypto returns the Tiingo crypto provider.
+func TiingoCrypto(token string) Provider {
+	return Func{"tiingo-crypto", func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		return quote.NewQuoteFromTiingoCrypto(symbol, start, end, period, token)
+	}}
+}
+
+// Coinbase returns the Coinbase exchange provider.
+func Coinbase() Provider {
+	return Func{"coinbase", quote.NewQuoteFromCoinbase}
+}
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// fake counts calls and answers from a table of symbols.
+type fake struct {
+	calls int
+	err   error
+}
+
+func (f *fake) get(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+	f.calls++
+	if f.err != nil || symbol == "NOPE" {
+		return quote.NewQuote("", 0), f.err
+	}
+	q := quote.NewQuote(symbol, 1)
+	q.Date[0] = quote.ParseDateString(start)
+	q.Close[0] = 42
+	return q, nil
+}
+
+var req = Request{"AAPL", quote.Daily, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}
+
+func TestNotFound(t *testing.T) {
+	f := &fake{}
+	_, err := Func{"fake", f.get}.Fetch(Request{Symbol: "NOPE"})
+	if !IsNotFound(err) {
+		t.Error("Expected not found, got", err)
+	}
+}
+
+func TestCached(t *testing.T) {
+	f := &fake{}
+	p := Cached(Func{"fake", f.get}, t.TempDir())
+	for i := 0; i < 2; i++ {
+		q, err := p.Fetch(req)
+		if err != nil || q.Close[0] != 42 {
+			t.Fatal("Expected 42, got", q.Close, err)
+		}
+	}
+	if f.calls != 1 {
+		t.Error("Expected 1 call, got", f.calls)
+	}
+
+	c := p.(cached)
+	c.now = func() time.Time { return req.To }
+	for i := 0; i < 2; i++ {
+		c.Fetch(req)
+	}
+	if f.calls != 3 {
+		t.Error("Expected a range ending today not to be cached, got", f.calls, "calls")
+	}
+}
+
+func TestCacheWriteFails(t *testing.T) {
+	f := &fake{}
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the cache directory is a file, so nothing can be written under it
+	q, err := Cached(Func{"fake", f.get}, file).Fetch(req)
+	if err != nil || q.Close[0] != 42 {
+		t.Error("Expected the fetched quote despite the cache, got", q.Close, err)
+	}
+}
+
+func TestIntraday(t *testing.T) {
+	var start, end string
+	p := Func{"fake", func(symbol, s, e string, period quote.Period) (quote.Quote, error) {
+		start, end = s, e
+		return quote.NewQuote(symbol, 1), nil
+	}}
+	r := Request{"BTC-USD", quote.Min5, time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC), time.Date(2024, 1, 2, 15, 45, 0, 0, time.UTC)}
+	if _, err := p.Fetch(r); err != nil {
+		t.Fatal(err)
+	}
+	if start != "2024-01-02 09:30" || end != "2024-01-02 15:45" {
+		t.Error("Expected the time of day to reach the source, got", start, end)
+	}
+	if got := quote.ParseDateString(end); !got.Equal(r.To) {
+		t.Error("Expected go-quote to read back", r.To, "got", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	f := &fake{err: errors.New("boom")}
+	b := WithBreaker(Func{"fake", f.get}, 2, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	b.Fetch(req)
+	b.Fetch(req)
+	if _, err := b.Fetch(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Error("Expected open circuit, got", err)
+	}
+	if f.calls != 2 {
+		t.Error("Expected 2 calls, got", f.calls)
+	}
+
+	// half open: the trial fails and the circuit opens again at once
+	now = now.Add(time.Minute)
+	b.Fetch(req)
+	if _, err := b.Fetch(req); !errors.Is(err, ErrCircuitOpen) || f.calls != 3 {
+		t.Error("Expected a failed trial to reopen the circuit, got", err, f.calls)
+	}
+
+	now = now.Add(time.Minute)
+	f.err = nil
+	if _, err := b.Fetch(Request{Symbol: "NOPE"}); !IsNotFound(err) {
+		t.Error("Expected not found after cooldown, got", err)
+	}
+	if _, err := b.Fetch(req); err != nil {
+		t.Error("Expected the circuit to close after a good trial, got", err)
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	calls := 0
+	fail := true
+	p := Func{"slow", func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		calls++
+		if fail {
+			return quote.NewQuote("", 0), errors.New("boom")
+		}
+		started <- struct{}{}
+		<-release
+		return quote.NewQuote(symbol, 1), nil
+	}}
+	b := WithBreaker(p, 1, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	b.Fetch(req)
+
+	now = now.Add(time.Minute)
+	fail = false
+	done := make(chan error)
+	go func() {
+		_, err := b.Fetch(req)
+		done <- err
+	}()
+	<-started
+	if _, err := b.Fetch(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Error("Expected only one trial while half open, got", err)
+	}
+	close(release)
+	if err := <-done; err != nil || calls != 2 {
+		t.Error("Expected the trial to succeed, got", err, calls)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	b, err := NewBucket(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.now = func() time.Time { return now }
+	b.sleep = func(d time.Duration) { slept += d; now = now.Add(d) }
+
+	for i := 0; i < 4; i++ {
+		b.Wait()
+	}
+	if slept != time.Second {
+		t.Error("Expected to sleep 1s, got", slept)
+	}
+
+	if _, err := NewBucket(2, 0); !errors.Is(err, ErrBadBucket) {
+		t.Error("Expected a bucket without burst to be rejected, got", err)
+	}
+	unlimited, _ := NewBucket(0, 1)
+	unlimited.sleep = func(time.Duration) { t.Fatal("Expected a zero rate not to wait") }
+	for i := 0; i < 3; i++ {
+		unlimited.Wait()
+	}
+}
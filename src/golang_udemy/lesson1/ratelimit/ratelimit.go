@@ -0,0 +1,235 @@
+/*
+ratelimit throttles API clients with a token bucket per client and route
+group, and caps how many requests each client makes per day.
+
+Buckets live in memory and refill continuously. Daily quotas are counted
+in SQLite so they survive restarts and are shared by every process using
+the same database; rows of earlier days are deleted once a day.
+
+Clients are told apart by the person account.Store.Require verified, so
+Wrap belongs inside it; unauthenticated requests count against their IP.
+*/
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang_udemy/lesson1/account"
+)
+
+// Limit is how much a single client may use a route group.
+type Limit struct {
+	// Rate is the number of requests per second a client sustains.
+	Rate float64
+	// Burst is how many requests a client may make at once.
+	Burst int
+	// Daily caps the requests per UTC day; 0 means no cap.
+	Daily int
+}
+
+// ErrBadLimit is returned by Wrap for a Limit that would never refill or
+// never let a request through.
+var ErrBadLimit = errors.New("ratelimit: limit needs a positive rate and a burst of at least 1")
+
+// KeyFunc names the client behind a request.
+type KeyFunc func(r *http.Request) string
+
+// ClientKey identifies a client by the authenticated person behind r, or
+// else its IP address. Credentials that were not verified are ignored,
+// so made-up ones cannot buy a fresh bucket.
+func ClientKey(r *http.Request) string {
+	if c, ok := account.CallerFrom(r.Context()); ok {
+		return "person:" + strconv.FormatInt(c.Person.ID, 10)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+type bucket struct {
+	lim    Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps the buckets and quotas of every client.
+type Limiter struct {
+	db  *sql.DB
+	key KeyFunc
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	// purged is the last UTC day quotas of earlier days were deleted on
+	purged string
+}
+
+// New creates the quotas table in db if needed. key may be nil, which
+// means ClientKey.
+func New(db *sql.DB, key KeyFunc) (*Limiter, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS quotas(
+			client TEXT,
+			route_group TEXT,
+			day TEXT,
+			count INT,
+			PRIMARY KEY(client, route_group, day))`)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		key = ClientKey
+	}
+	return &Limiter{db: db, key: key, now: time.Now, buckets: map[string]*bucket{}}, nil
+}
+
+// status is what a client has left after a request.
+type status struct {
+	ok         bool
+	limit      int
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// take spends a token of client's bucket in group.
+func (l *Limiter) take(client, group string, lim Limit) status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	k := group + " " + client
+	b, ok := l.buckets[k]
+	if !ok {
+		l.prune(now)
+		b = &bucket{lim: lim, tokens: float64(lim.Burst), last: now}
+		l.buckets[k] = b
+	}
+	b.tokens = math.Min(float64(lim.Burst), b.tokens+now.Sub(b.last).Seconds()*lim.Rate)
+	b.last = now
+	s := status{limit: lim.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		s.ok = true
+	} else {
+		s.retryAfter = seconds((1 - b.tokens) / lim.Rate)
+	}
+	s.remaining = int(b.tokens)
+	s.reset = seconds((float64(lim.Burst) - b.tokens) / lim.Rate)
+	return s
+}
+
+// prune drops buckets that have refilled, so one-off clients do not
+// pile up. It runs only once there are many buckets.
+func (l *Limiter) prune(now time.Time) {
+	if len(l.buckets) < 10000 {
+		return
+	}
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.lim.Rate >= float64(b.lim.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// count adds a request to client's quota in group for today.
+func (l *Limiter) count(client, group string, lim Limit) (status, error) {
+	now := l.now().UTC()
+	day := now.Format("2006-01-02")
+	if err := l.purge(day); err != nil {
+		return status{}, err
+	}
+	var n int
+	err := l.db.QueryRow(`INSERT INTO quotas(client, route_group, day, count) VALUES(?, ?, ?, 1)
+		ON CONFLICT(client, route_group, day) DO UPDATE SET count = count + 1
+		RETURNING count`, client, group, day).Scan(&n)
+	if err != nil {
+		return status{}, err
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	s := status{ok: n <= lim.Daily, limit: lim.Daily, remaining: max(lim.Daily-n, 0), reset: midnight.Sub(now)}
+	if !s.ok {
+		s.retryAfter = s.reset
+	}
+	return s, nil
+}
+
+// purge deletes the quotas of days before day, at most once a day.
+func (l *Limiter) purge(day string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.purged == day {
+		return nil
+	}
+	if _, err := l.db.Exec(`DELETE FROM quotas WHERE day < ?`, day); err != nil {
+		return err
+	}
+	l.purged = day
+	return nil
+}
+
+// Used returns how many requests client made to group on the UTC day of t.
+func (l *Limiter) Used(client, group string, t time.Time) (int, error) {
+	var n int
+	err := l.db.QueryRow(`SELECT count FROM quotas WHERE client = ? AND route_group = ? AND day = ?`,
+		client, group, t.UTC().Format("2006-01-02")).Scan(&n)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return n, err
+}
+
+// Wrap limits next as the route group named group. Responses carry
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers; requests over a limit get 429 Too Many
+// Requests with Retry-After. A Limit that cannot refill is rejected with
+// ErrBadLimit.
+func (l *Limiter) Wrap(group string, lim Limit, next http.Handler) (http.Handler, error) {
+	if !(lim.Rate > 0) || lim.Burst < 1 || lim.Daily < 0 {
+		return nil, fmt.Errorf("%w: %s %+v", ErrBadLimit, group, lim)
+	}
+	policy := fmt.Sprintf("%d;w=%d", lim.Burst, int(math.Ceil(float64(lim.Burst)/lim.Rate)))
+	if lim.Daily > 0 {
+		policy += fmt.Sprintf(", %d;w=86400", lim.Daily)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := l.key(r)
+		s := l.take(client, group, lim)
+		// a throttled request does not count against the daily quota
+		if s.ok && lim.Daily > 0 {
+			q, err := l.count(client, group, lim)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !q.ok || q.remaining < s.remaining {
+				s = q
+			}
+		}
+		h := w.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(s.limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(s.remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(int(s.reset/time.Second)))
+		if !s.ok {
+			h.Set("Retry-After", strconv.Itoa(int(s.retryAfter/time.Second)))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}), nil
+}
+
+// seconds rounds s up to whole seconds.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/mylib"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newLimiter(t *testing.T) (*Limiter, *time.Time) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	l, err := New(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func get(h http.Handler, addr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/indicators", nil)
+	r.RemoteAddr = addr
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBucket(t *testing.T) {
+	l, now := newLimiter(t)
+	h, err := l.Wrap("indicators", Limit{Rate: 1, Burst: 2}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get(h, "10.0.0.1:1000")
+	w := get(h, "10.0.0.1:1001")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Error("Expected last token, got", w.Code, w.Header())
+	}
+	w = get(h, "10.0.0.1:1002")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Error("Expected 429 retry in 1s, got", w.Code, w.Header())
+	}
+	// another client has its own bucket
+	if w = get(h, "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Error("Expected other client to pass, got", w.Code)
+	}
+	*now = now.Add(time.Second)
+	if w = get(h, "10.0.0.1:1003"); w.Code != http.StatusOK {
+		t.Error("Expected refilled token, got", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=2" {
+		t.Error("Unexpected policy", got)
+	}
+}
+
+func TestBadLimit(t *testing.T) {
+	l, _ := newLimiter(t)
+	for _, lim := range []Limit{{Rate: 0, Burst: 5}, {Rate: -1, Burst: 5}, {Rate: 1, Burst: 0}} {
+		if _, err := l.Wrap("indicators", lim, http.NotFoundHandler()); !errors.Is(err, ErrBadLimit) {
+			t.Errorf("Expected %+v to be rejected, got %v", lim, err)
+		}
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	l, now := newLimiter(t)
+	h, err := l.Wrap("indicators", Limit{Rate: 100, Burst: 100, Daily: 3}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		get(h, "10.0.0.1:1000")
+	}
+	w := get(h, "10.0.0.1:1000")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Error("Expected quota exhausted until midnight, got", w.Code, w.Header())
+	}
+	if n, _ := l.Used("ip:10.0.0.1", "indicators", *now); n != 4 {
+		t.Error("Expected 4 requests counted, got", n)
+	}
+	*now = now.Add(time.Hour)
+	if w = get(h, "10.0.0.1:1000"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "2" {
+		t.Error("Expected a fresh quota the next day, got", w.Code, w.Header())
+	}
+	var rows int
+	l.db.QueryRow(`SELECT COUNT(*) FROM quotas`).Scan(&rows)
+	if rows != 1 {
+		t.Error("Expected yesterday's quota to be purged, got rows", rows)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+	accounts, _ := account.NewStore(db)
+	accounts.Register(mylib.Person{Name: "Alice"}, "alice", "pw")
+
+	var got string
+	h := accounts.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = ClientKey(r) }),
+		account.ScopeRead, account.ScopeRead)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("alice", "pw")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "person:1" {
+		t.Error("Expected person:1, got", got)
+	}
+
+	// unverified credentials are not trusted
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.9:1234"
+	r.Header.Set("Authorization", "Bearer made.up")
+	if k := ClientKey(r); k != "ip:10.0.0.9" {
+		t.Error("Expected ip:10.0.0.9, got", k)
+	}
+}
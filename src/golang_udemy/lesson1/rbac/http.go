@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/reply"
+)
+
+// Require checks that the caller authenticated by account.Store.Require
+// holds a permission before passing the request on to next. GET and HEAD
+// requests need read, anything else write.
+func (e *Enforcer) Require(next http.Handler, read, write Permission) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perm := write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			perm = read
+		}
+		if !e.allow(w, r, perm) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MayManage lets a person manage their own account, and holders of
+// ManageAccounts anyone's. It is an account.MayManage.
+func (e *Enforcer) MayManage(c account.Caller, personID int64) (bool, error) {
+	if c.Person.ID == personID {
+		return true, nil
+	}
+	err := e.Check(c.Person.ID, ManageAccounts, fmt.Sprintf("account of person %d", personID))
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *Enforcer) allow(w http.ResponseWriter, r *http.Request, perm Permission) bool {
+	c, ok := account.CallerFrom(r.Context())
+	if !ok {
+		http.Error(w, "not authenticated", http.StatusUnauthorized)
+		return false
+	}
+	err := e.Check(c.Person.ID, perm, r.Method+" "+r.URL.Path)
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// NewHandler serves role administration to admins:
+//
+//	GET    /roles
+//	PUT    /roles/{person id}/{role}
+//	DELETE /roles/{person id}/{role}
+//	GET    /denials?since=2006-01-02T15:04:05Z
+//
+// It must be wrapped by account.Store.Require.
+func NewHandler(e *Enforcer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /roles", func(w http.ResponseWriter, r *http.Request) {
+		if e.allow(w, r, ManageRoles) {
+			m, err := e.Assignments()
+			reply.JSON(w, m, err, codes...)
+		}
+	})
+	mux.HandleFunc("PUT /roles/{person}/{role}", func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := personID(w, r); ok && e.allow(w, r, ManageRoles) {
+			reply.JSON(w, nil, e.Assign(id, Role(r.PathValue("role"))), codes...)
+		}
+	})
+	mux.HandleFunc("DELETE /roles/{person}/{role}", func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := personID(w, r); ok && e.allow(w, r, ManageRoles) {
+			reply.JSON(w, nil, e.Unassign(id, Role(r.PathValue("role"))), codes...)
+		}
+	})
+	mux.HandleFunc("GET /denials", func(w http.ResponseWriter, r *http.Request) {
+		if !e.allow(w, r, ReadAudit) {
+			return
+		}
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		list, err := e.Denials(since)
+		reply.JSON(w, list, err, codes...)
+	})
+	return mux
+}
+
+func personID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("person"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad person id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// codes are the statuses of the errors the handlers return.
+var codes = []reply.Code{
+	{Err: ErrUnknownRole, Status: http.StatusBadRequest},
+	{Err: ErrLastAdmin, Status: http.StatusConflict},
+}
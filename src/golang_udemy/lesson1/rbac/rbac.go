@@ -0,0 +1,272 @@
+/*
+rbac decides what a person may do from the roles assigned to them, and
+records every denial for later audit. Persons are identified by id, so a
+new person reusing a deleted person's name starts without roles.
+
+A person without roles may do nothing, so a new database needs its first
+admin made with Bootstrap, and Unassign will not take away the last
+admin.
+*/
+package rbac
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrUnknownRole  = errors.New("rbac: unknown role")
+	ErrBootstrapped = errors.New("rbac: roles have already been assigned")
+	ErrLastAdmin    = errors.New("rbac: cannot remove the last admin")
+)
+
+// Role is a named set of permissions.
+type Role string
+
+const (
+	Admin   Role = "admin"
+	Trader  Role = "trader"
+	Analyst Role = "analyst"
+	Viewer  Role = "viewer"
+)
+
+// Permission is a single thing that can be allowed.
+type Permission string
+
+const (
+	ReadMarket      Permission = "market:read"
+	ReadOwn         Permission = "own:read"
+	EditWatchlists  Permission = "watchlists:write"
+	EditAlerts      Permission = "alerts:write"
+	EditStrategies  Permission = "strategies:write"
+	PlaceLiveOrders Permission = "orders:live"
+	ManageUniverse  Permission = "universe:write"
+	ReadPersons     Permission = "persons:read"
+	EditPersons     Permission = "persons:write"
+	ManageAccounts  Permission = "accounts:write"
+	ManageRoles     Permission = "roles:write"
+	ReadAudit       Permission = "audit:read"
+	ManageDatabase  Permission = "database:admin"
+)
+
+// Policy maps each role to its permissions.
+type Policy map[Role][]Permission
+
+// DefaultPolicy gives each role everything the role below it has.
+var DefaultPolicy = Policy{
+	Viewer:  {ReadMarket, ReadOwn},
+	Analyst: {ReadMarket, ReadOwn, ReadPersons, EditWatchlists, EditAlerts, EditStrategies},
+	Trader:  {ReadMarket, ReadOwn, ReadPersons, EditWatchlists, EditAlerts, EditStrategies, PlaceLiveOrders},
+	Admin: {ReadMarket, ReadOwn, ReadPersons, EditWatchlists, EditAlerts, EditStrategies, PlaceLiveOrders,
+		ManageUniverse, EditPersons, ManageAccounts, ManageRoles, ReadAudit, ManageDatabase},
+}
+
+// Allows reports whether role has perm under p.
+func (p Policy) Allows(role Role, perm Permission) bool {
+	for _, q := range p[role] {
+		if q == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedError is returned when a person lacks a permission.
+type DeniedError struct {
+	Person     int64
+	Permission Permission
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("rbac: person %d may not %s", e.Person, e.Permission)
+}
+
+// Denial is an audit record of a refused action.
+type Denial struct {
+	Time       time.Time  `json:"time"`
+	Person     int64      `json:"person_id"`
+	Permission Permission `json:"permission"`
+	Resource   string     `json:"resource"`
+}
+
+// Enforcer checks permissions against role assignments kept in SQLite.
+type Enforcer struct {
+	db     *sql.DB
+	policy Policy
+	now    func() time.Time
+}
+
+// NewEnforcer creates the role_assignments and access_denials tables in
+// db if needed.
+func NewEnforcer(db *sql.DB, policy Policy) (*Enforcer, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS role_assignments(
+			person_id INTEGER,
+			role TEXT,
+			PRIMARY KEY(person_id, role));
+		CREATE TABLE IF NOT EXISTS access_denials(
+			time INT,
+			person_id INTEGER,
+			permission TEXT,
+			resource TEXT)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Enforcer{db, policy, time.Now}, nil
+}
+
+// Assign gives the person id role.
+func (e *Enforcer) Assign(person int64, role Role) error {
+	if _, ok := e.policy[role]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	_, err := e.db.Exec(`INSERT OR IGNORE INTO role_assignments(person_id, role) VALUES(?, ?)`, person, role)
+	return err
+}
+
+// Unassign takes role away from the person id. It returns ErrLastAdmin
+// rather than leave no one able to assign roles.
+func (e *Enforcer) Unassign(person int64, role Role) error {
+	res, err := e.db.Exec(`DELETE FROM role_assignments WHERE person_id = ? AND role = ?
+		AND (role != ? OR (SELECT COUNT(*) FROM role_assignments WHERE role = ?) > 1)`, person, role, Admin, Admin)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 || role != Admin {
+		return err
+	}
+	has, err := e.has(person, Admin)
+	if has {
+		return ErrLastAdmin
+	}
+	return err
+}
+
+// Bootstrap makes the person id the first admin. It returns
+// ErrBootstrapped once any role has been assigned.
+func (e *Enforcer) Bootstrap(person int64) error {
+	res, err := e.db.Exec(`INSERT INTO role_assignments(person_id, role)
+		SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM role_assignments)`, person, Admin)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrBootstrapped
+	}
+	return err
+}
+
+func (e *Enforcer) has(person int64, role Role) (bool, error) {
+	var n int
+	err := e.db.QueryRow(`SELECT COUNT(*) FROM role_assignments WHERE person_id = ? AND role = ?`, person, role).Scan(&n)
+	return n > 0, err
+}
+
+// Roles returns the roles of the person id.
+func (e *Enforcer) Roles(person int64) ([]Role, error) {
+	rows, err := e.db.Query(`SELECT role FROM role_assignments WHERE person_id = ? ORDER BY role`, person)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roles []Role
+	for rows.Next() {
+		var r Role
+		if err := rows.Scan(&r); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// Assignments returns the roles of every person id with a role.
+func (e *Enforcer) Assignments() (map[int64][]Role, error) {
+	rows, err := e.db.Query(`SELECT person_id, role FROM role_assignments ORDER BY person_id, role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := map[int64][]Role{}
+	for rows.Next() {
+		var p int64
+		var r Role
+		if err := rows.Scan(&p, &r); err != nil {
+			return nil, err
+		}
+		m[p] = append(m[p], r)
+	}
+	return m, rows.Err()
+}
+
+// Permissions returns everything the person id may do, sorted.
+func (e *Enforcer) Permissions(person int64) ([]Permission, error) {
+	roles, err := e.Roles(person)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[Permission]bool{}
+	var perms []Permission
+	for _, r := range roles {
+		for _, p := range e.policy[r] {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms, nil
+}
+
+// Check returns nil if the person id may perm, and otherwise records
+// the denial against resource and returns a DeniedError.
+func (e *Enforcer) Check(person int64, perm Permission, resource string) error {
+	roles, err := e.Roles(person)
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if e.policy.Allows(r, perm) {
+			return nil
+		}
+	}
+	_, err = e.db.Exec(`INSERT INTO access_denials(time, person_id, permission, resource) VALUES(?, ?, ?, ?)`,
+		e.now().UnixNano(), person, perm, resource)
+	if err != nil {
+		return err
+	}
+	return &DeniedError{person, perm}
+}
+
+// Denials returns the denials recorded since, oldest first.
+func (e *Enforcer) Denials(since time.Time) ([]Denial, error) {
+	rows, err := e.db.Query(`SELECT time, person_id, permission, resource FROM access_denials
+		WHERE time >= ? ORDER BY time`, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Denial
+	for rows.Next() {
+		var d Denial
+		var t int64
+		if err := rows.Scan(&t, &d.Person, &d.Permission, &d.Resource); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(0, t)
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// Bootstrapped reports whether any role has been assigned yet.
+func (e *Enforcer) Bootstrapped() (bool, error) {
+	var n int
+	err := e.db.QueryRow(`SELECT COUNT(*) FROM role_assignments`).Scan(&n)
+	return n > 0, err
+}
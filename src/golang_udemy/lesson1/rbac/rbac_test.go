@@ -0,0 +1,146 @@
+package rbac
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/mylib"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func open(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestCheck(t *testing.T) {
+	e, err := NewEnforcer(open(t), DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const alice, nobody = 1, 2
+	if err := e.Assign(alice, "wizard"); !errors.Is(err, ErrUnknownRole) {
+		t.Error("Expected unknown role, got", err)
+	}
+	e.Assign(alice, Analyst)
+	if err := e.Check(alice, EditStrategies, "strategy 1"); err != nil {
+		t.Error("Expected analyst to edit strategies, got", err)
+	}
+	var denied *DeniedError
+	if err := e.Check(alice, PlaceLiveOrders, "order 1"); !errors.As(err, &denied) {
+		t.Error("Expected analyst to be denied live orders, got", err)
+	}
+	e.Assign(alice, Trader)
+	if err := e.Check(alice, PlaceLiveOrders, "order 2"); err != nil {
+		t.Error("Expected trader to place live orders, got", err)
+	}
+	if err := e.Check(nobody, ReadMarket, "candles"); err == nil {
+		t.Error("Expected a person without roles to be denied")
+	}
+
+	list, _ := e.Denials(time.Time{})
+	if len(list) != 2 || list[0].Resource != "order 1" || list[1].Person != nobody {
+		t.Error("Unexpected denials", list)
+	}
+	perms, _ := e.Permissions(alice)
+	if len(perms) != len(DefaultPolicy[Trader]) {
+		t.Error("Expected trader permissions, got", perms)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	db := open(t)
+	accounts, _ := account.NewStore(db)
+	vera, _ := accounts.Register(mylib.Person{Name: "Vera"}, "vera", "pw")
+	root, _ := accounts.Register(mylib.Person{Name: "Root"}, "root", "pw")
+	e, _ := NewEnforcer(db, DefaultPolicy)
+	e.Assign(vera.ID, Viewer)
+	e.Assign(root.ID, Admin)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("/candles", e.Require(ok, ReadMarket, ManageUniverse))
+	mux.Handle("/", NewHandler(e))
+	h := accounts.Require(mux, account.ScopeRead, account.ScopeRead)
+
+	do := func(method, path, who string) int {
+		r := httptest.NewRequest(method, path, nil)
+		r.SetBasicAuth(who, "pw")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := do("GET", "/candles", "vera"); code != http.StatusOK {
+		t.Error("Expected viewer to read candles, got", code)
+	}
+	if code := do("POST", "/candles", "vera"); code != http.StatusForbidden {
+		t.Error("Expected viewer to be forbidden, got", code)
+	}
+	grant := fmt.Sprintf("/roles/%d/trader", vera.ID)
+	if code := do("PUT", grant, "vera"); code != http.StatusForbidden {
+		t.Error("Expected viewer not to grant roles, got", code)
+	}
+	if code := do("PUT", grant, "root"); code != http.StatusNoContent {
+		t.Error("Expected admin to grant roles, got", code)
+	}
+	if roles, _ := e.Roles(vera.ID); len(roles) != 2 {
+		t.Error("Expected vera to be viewer and trader, got", roles)
+	}
+	if code := do("GET", "/denials", "root"); code != http.StatusOK {
+		t.Error("Expected admin to read denials, got", code)
+	}
+	if ok, _ := e.MayManage(account.Caller{Person: vera}, root.ID); ok {
+		t.Error("Expected viewer not to manage root's account")
+	}
+	if ok, _ := e.MayManage(account.Caller{Person: root}, vera.ID); !ok {
+		t.Error("Expected admin to manage vera's account")
+	}
+	if list, _ := e.Denials(time.Time{}); len(list) != 3 || list[0].Resource != "POST /candles" {
+		t.Error("Unexpected denials", list)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	e, err := NewEnforcer(open(t), DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const root, vera = 1, 2
+	if err := e.Check(root, ManageRoles, "roles"); err == nil {
+		t.Error("Expected everything to be denied before an admin is bootstrapped")
+	}
+	if err := e.Bootstrap(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Check(root, ManageRoles, "roles"); err != nil {
+		t.Error("Expected the bootstrapped admin to manage roles, got", err)
+	}
+	if err := e.Bootstrap(vera); !errors.Is(err, ErrBootstrapped) {
+		t.Error("Expected a second bootstrap to be refused, got", err)
+	}
+
+	if err := e.Unassign(root, Admin); !errors.Is(err, ErrLastAdmin) {
+		t.Error("Expected the last admin to stay, got", err)
+	}
+	e.Assign(vera, Admin)
+	if err := e.Unassign(root, Admin); err != nil {
+		t.Error("Expected an admin to go while another remains, got", err)
+	}
+	if err := e.Unassign(root, Admin); err != nil {
+		t.Error("Expected unassigning a role no longer held to do nothing, got", err)
+	}
+	if err := e.Unassign(vera, Admin); !errors.Is(err, ErrLastAdmin) {
+		t.Error("Expected the last admin to stay, got", err)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+refresh keeps a store of candles up to date from providers on a
+schedule. Each job refreshes one symbol at one period from a named
+source: the first run backfills a window of history, later runs fetch
+from the last stored bar on. Every source has its own limit on fetches
+in flight, and failed fetches are retried with exponential backoff,
+except for symbols the source does not know.
+*/
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// ErrUnknownSource is returned for a job naming a source the scheduler
+// was not given.
+var ErrUnknownSource = errors.New("refresh: unknown source")
+
+// Job keeps the candles of Symbol at Period from Source up to date,
+// refreshing them every Every. With nothing stored yet it backfills from
+// Backfill ago.
+type Job struct {
+	Source   string
+	Symbol   string
+	Period   quote.Period
+	Every    time.Duration
+	Backfill time.Duration
+}
+
+func (j Job) String() string {
+	return fmt.Sprintf("%s %s %s", j.Source, j.Symbol, j.Period)
+}
+
+// Source is a provider jobs name by its Name, fetched from at most
+// Concurrency at a time (one if less).
+type Source struct {
+	Provider    provider.Provider
+	Concurrency int
+}
+
+// Retry is how a failed fetch is retried: up to Attempts tries in all,
+// waiting Backoff before the second and twice as long before each one
+// after, but never more than Max.
+type Retry struct {
+	Attempts int
+	Backoff  time.Duration
+	Max      time.Duration
+}
+
+// DefaultRetry tries a fetch four times over about a quarter minute.
+var DefaultRetry = Retry{Attempts: 4, Backoff: 2 * time.Second, Max: time.Minute}
+
+type source struct {
+	provider.Provider
+	slots chan struct{}
+}
+
+// Scheduler runs jobs against a store.
+type Scheduler struct {
+	store   *Store
+	retry   Retry
+	sources map[string]source
+	now     func() time.Time
+}
+
+// New returns a scheduler saving to store what it fetches from sources.
+func New(store *Store, retry Retry, sources ...Source) *Scheduler {
+	s := &Scheduler{store: store, retry: retry, sources: map[string]source{}, now: time.Now}
+	for _, src := range sources {
+		n := src.Concurrency
+		if n < 1 {
+			n = 1
+		}
+		s.sources[src.Provider.Name()] = source{src.Provider, make(chan struct{}, n)}
+	}
+	return s
+}
+
+// Refresh runs j once and returns how many bars it saved.
+func (s *Scheduler) Refresh(ctx context.Context, j Job) (int, error) {
+	src, ok := s.sources[j.Source]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownSource, j.Source)
+	}
+	r := provider.Request{Symbol: j.Symbol, Period: j.Period, To: s.now()}
+	last, ok, err := s.store.Last(j.Symbol, j.Period)
+	if err != nil {
+		return 0, err
+	}
+	r.From = r.To.Add(-j.Backfill)
+	if ok {
+		// the last bar may have been fetched before it closed
+		r.From = last
+	}
+	q, err := s.fetch(ctx, src, r)
+	if err != nil {
+		return 0, err
+	}
+	q.Symbol = j.Symbol
+	return len(q.Date), s.store.Save(q, j.Period)
+}
+
+// fetch fetches r from src, retrying failures as s.retry allows. It holds
+// one of src's slots only while a fetch is in flight.
+func (s *Scheduler) fetch(ctx context.Context, src source, r provider.Request) (quote.Quote, error) {
+	wait := s.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case src.slots <- struct{}{}:
+		case <-ctx.Done():
+			return quote.Quote{}, ctx.Err()
+		}
+		q, err := src.Fetch(r)
+		<-src.slots
+		if err == nil || provider.IsNotFound(err) || attempt >= s.retry.Attempts {
+			return q, err
+		}
+		log.Printf("refresh: %v; retrying in %s", err, wait)
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return q, ctx.Err()
+		}
+		if wait *= 2; s.retry.Max > 0 && wait > s.retry.Max {
+			wait = s.retry.Max
+		}
+	}
+}
+
+// RefreshAll runs each of jobs once, all at the same time as far as the
+// sources allow, and returns the errors of those that failed.
+func (s *Scheduler) RefreshAll(ctx context.Context, jobs ...Job) error {
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Refresh(ctx, j); err != nil {
+				errs[i] = fmt.Errorf("refresh %s: %w", j, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Run refreshes each of jobs now and then every j.Every until ctx is
+// done, logging failures, and returns ctx.Err().
+func (s *Scheduler) Run(ctx context.Context, jobs ...Job) error {
+	for _, j := range jobs {
+		if _, ok := s.sources[j.Source]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownSource, j.Source)
+		}
+		if j.Every <= 0 {
+			return fmt.Errorf("refresh: %s: every must be positive", j)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := time.NewTicker(j.Every)
+			defer t.Stop()
+			for {
+				if n, err := s.Refresh(ctx, j); err != nil {
+					log.Printf("refresh %s: %v", j, err)
+				} else {
+					log.Printf("refresh %s: saved %d bars", j, n)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,216 @@
+package refresh
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/provider"
+
+	quote "github.com/markcheno/go-quote"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fakeServer answers as Tiingo's daily prices and Coinbase's candles do,
+// with a bar every day or granularity from 2024 on, and keeps count.
+type fakeServer struct {
+	mu       sync.Mutex
+	requests map[string][]url.Values // by symbol
+	inFlight map[string]int          // by source
+	maxIn    map[string]int
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{requests: map[string][]url.Values{}, inFlight: map[string]int{}, maxIn: map[string]int{}}
+}
+
+func (f *fakeServer) enter(source, symbol string, q url.Values) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[symbol] = append(f.requests[symbol], q)
+	f.inFlight[source]++
+	if f.inFlight[source] > f.maxIn[source] {
+		f.maxIn[source] = f.inFlight[source]
+	}
+	return func() {
+		f.mu.Lock()
+		f.inFlight[source]--
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tiingo/daily/{symbol}/prices", func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.PathValue("symbol")
+		defer f.enter("tiingo", symbol, r.URL.Query())()
+		time.Sleep(20 * time.Millisecond)
+		if r.Header.Get("Authorization") != "Token test" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		if symbol == "NOPE" {
+			http.NotFound(w, r)
+			return
+		}
+		from, _ := time.Parse("2006-1-2", r.URL.Query().Get("startDate"))
+		to, _ := time.Parse("2006-1-2", r.URL.Query().Get("endDate"))
+		var rows []map[string]interface{}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			c := float64(d.YearDay())
+			rows = append(rows, map[string]interface{}{
+				"date": d.Format("2006-01-02") + "T00:00:00.000Z", "adjOpen": c, "adjHigh": c + 1, "adjLow": c - 1, "adjClose": c, "volume": 100,
+			})
+		}
+		json.NewEncoder(w).Encode(rows)
+	})
+	mux.HandleFunc("GET /products/{symbol}/candles", func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.PathValue("symbol")
+		defer f.enter("coinbase", symbol, r.URL.Query())()
+		from, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+		to, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+		var step int64
+		fmt.Sscan(r.URL.Query().Get("granularity"), &step)
+		var rows [][6]float64
+		// newest first, as Coinbase sends them
+		for t := to.Unix() / step * step; t >= from.Unix(); t -= step {
+			rows = append(rows, [6]float64{float64(t), 9, 11, 10, 10.5, 3})
+		}
+		json.NewEncoder(w).Encode(rows)
+	})
+	return mux
+}
+
+// redirect sends every request to the fake server instead of the real
+// hosts go-quote has built in, failing the first fail[symbol] requests
+// for a symbol as a dropped connection would.
+type redirect struct {
+	to   *url.URL
+	next http.RoundTripper
+	mu   sync.Mutex
+	fail map[string]int
+}
+
+func (rt *redirect) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	for symbol, n := range rt.fail {
+		if n > 0 && strings.Contains(r.URL.Path, "/"+symbol+"/") {
+			rt.fail[symbol]--
+			rt.mu.Unlock()
+			return nil, errors.New("connection reset")
+		}
+	}
+	rt.mu.Unlock()
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.to.Scheme, rt.to.Host
+	return rt.next.RoundTrip(r)
+}
+
+// fake starts a fake server and points http.DefaultTransport, which
+// go-quote's clients use, at it for the rest of the test.
+func fake(t *testing.T) (*fakeServer, *redirect) {
+	f := newFakeServer()
+	srv := httptest.NewServer(f.handler())
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	rt := &redirect{to: u, next: srv.Client().Transport, fail: map[string]int{}}
+	saved := http.DefaultTransport
+	http.DefaultTransport = rt
+	t.Cleanup(func() { http.DefaultTransport = saved })
+	return f, rt
+}
+
+func newScheduler(t *testing.T, now time.Time) (*Scheduler, *Store) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(store, Retry{Attempts: 3, Backoff: time.Millisecond, Max: 2 * time.Millisecond},
+		Source{Provider: provider.Tiingo("test"), Concurrency: 2},
+		Source{Provider: provider.Coinbase()})
+	s.now = func() time.Time { return now }
+	return s, store
+}
+
+func TestRefresh(t *testing.T) {
+	f, rt := fake(t)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s, store := newScheduler(t, now)
+
+	var jobs []Job
+	for _, symbol := range []string{"AAPL", "MSFT", "IBM", "KO", "PEP"} {
+		jobs = append(jobs, Job{Source: "tiingo", Symbol: symbol, Period: quote.Daily, Backfill: 10 * 24 * time.Hour})
+	}
+	jobs = append(jobs, Job{Source: "coinbase", Symbol: "BTC-USD", Period: quote.Min60, Backfill: 6 * time.Hour})
+	rt.fail["AAPL"] = 2
+	if err := s.RefreshAll(context.Background(), jobs...); err != nil {
+		t.Fatal(err)
+	}
+	if f.maxIn["tiingo"] != 2 {
+		t.Error("Expected at most and up to 2 Tiingo fetches at a time, got", f.maxIn["tiingo"])
+	}
+	q, _ := store.Candles("AAPL", quote.Daily, time.Time{}, now.AddDate(1, 0, 0))
+	if len(q.Date) != 11 || !q.Date[0].Equal(now.AddDate(0, 0, -10)) || q.Close[10] != 61 {
+		t.Error("Expected AAPL backfilled from 2024-02-20 after two failures, got", q.Date)
+	}
+	q, _ = store.Candles("BTC-USD", quote.Min60, time.Time{}, now.AddDate(1, 0, 0))
+	if len(q.Date) != 7 || !q.Date[0].Equal(now.Add(-6*time.Hour)) {
+		t.Error("Expected 7 hourly BTC-USD bars, got", q.Date)
+	}
+
+	// the next run starts from the last bar stored
+	s.now = func() time.Time { return now.AddDate(0, 0, 2) }
+	if n, err := s.Refresh(context.Background(), jobs[0]); err != nil || n != 3 {
+		t.Error("Expected 3 bars refreshed, got", n, err)
+	}
+	aapl := f.requests["AAPL"]
+	if from := aapl[len(aapl)-1].Get("startDate"); from != "2024-3-1" {
+		t.Error("Expected the refresh to start at the last bar, got", from)
+	}
+	if q, _ := store.Candles("AAPL", quote.Daily, time.Time{}, now.AddDate(1, 0, 0)); len(q.Date) != 13 {
+		t.Error("Expected 13 AAPL bars, got", len(q.Date))
+	}
+}
+
+func TestRefreshFailures(t *testing.T) {
+	f, rt := fake(t)
+	s, _ := newScheduler(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	_, err := s.Refresh(ctx, Job{Source: "tiingo", Symbol: "NOPE", Period: quote.Daily, Backfill: time.Hour})
+	if !provider.IsNotFound(err) || len(f.requests["NOPE"]) != 1 {
+		t.Error("Expected an unknown symbol not to be retried, got", err, len(f.requests["NOPE"]))
+	}
+	rt.fail["KO"] = 3
+	if _, err := s.Refresh(ctx, Job{Source: "tiingo", Symbol: "KO", Period: quote.Daily, Backfill: time.Hour}); err == nil {
+		t.Error("Expected failure after 3 attempts")
+	}
+	if rt.fail["KO"] != 0 {
+		t.Error("Expected 3 attempts, got", 3-rt.fail["KO"])
+	}
+	if _, err := s.Refresh(ctx, Job{Source: "yahoo", Symbol: "KO"}); !errors.Is(err, ErrUnknownSource) {
+		t.Error("Expected unknown source, got", err)
+	}
+	if err := s.Run(ctx, Job{Source: "tiingo", Symbol: "KO"}); err == nil {
+		t.Error("Expected a job without an interval to be refused")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.Run(cancelled, Job{Source: "tiingo", Symbol: "KO", Period: quote.Daily, Every: time.Hour}); !errors.Is(err, context.Canceled) {
+		t.Error("Expected Run to stop with its context, got", err)
+	}
+}
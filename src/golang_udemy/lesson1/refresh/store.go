@@ -0,0 +1,87 @@
+package refresh
+
+import (
+	"database/sql"
+	"time"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+// Store keeps candles in the candles table, one row per symbol, period
+// and bar.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates the candles table in db if needed.
+func NewStore(db *sql.DB) (*Store, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS candles(
+		symbol TEXT,
+		period TEXT,
+		time INT,
+		open REAL,
+		high REAL,
+		low REAL,
+		close REAL,
+		volume REAL,
+		PRIMARY KEY(symbol, period, time))`)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// Save records the bars of q, replacing those already stored at the same
+// times, which may have been incomplete.
+func (s *Store) Save(q quote.Quote, period quote.Period) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, t := range q.Date {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO candles(symbol, period, time, open, high, low, close, volume)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+			q.Symbol, period, t.Unix(), q.Open[i], q.High[i], q.Low[i], q.Close[i], q.Volume[i])
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Last returns the time of the latest bar of symbol at period.
+func (s *Store) Last(symbol string, period quote.Period) (time.Time, bool, error) {
+	var t sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(time) FROM candles WHERE symbol = ? AND period = ?`, symbol, period).Scan(&t)
+	if err != nil || !t.Valid {
+		return time.Time{}, false, err
+	}
+	return time.Unix(t.Int64, 0).UTC(), true, nil
+}
+
+// Candles returns the bars of symbol at period in [from, to), oldest first.
+func (s *Store) Candles(symbol string, period quote.Period, from, to time.Time) (quote.Quote, error) {
+	rows, err := s.db.Query(`SELECT time, open, high, low, close, volume FROM candles
+		WHERE symbol = ? AND period = ? AND time >= ? AND time < ? ORDER BY time`,
+		symbol, period, from.Unix(), to.Unix())
+	if err != nil {
+		return quote.Quote{}, err
+	}
+	defer rows.Close()
+	q := quote.NewQuote(symbol, 0)
+	for rows.Next() {
+		var t int64
+		var o, h, l, c, v float64
+		if err := rows.Scan(&t, &o, &h, &l, &c, &v); err != nil {
+			return q, err
+		}
+		q.Date = append(q.Date, time.Unix(t, 0).UTC())
+		q.Open = append(q.Open, o)
+		q.High = append(q.High, h)
+		q.Low = append(q.Low, l)
+		q.Close = append(q.Close, c)
+		q.Volume = append(q.Volume, v)
+	}
+	return q, rows.Err()
+}
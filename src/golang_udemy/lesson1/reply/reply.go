@@ -0,0 +1,54 @@
+/*
+reply writes the responses of the JSON HTTP handlers, so every package
+answers errors, empty results and lists the same way.
+*/
+package reply
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// Code is the HTTP status an error matching Err is answered with.
+type Code struct {
+	Err    error
+	Status int
+}
+
+// JSON answers a request. An error gets the status of the first of codes
+// it matches with errors.Is, or 500 Internal Server Error. Otherwise a
+// nil v is 204 No Content and anything else is v as JSON, where nil
+// slices and maps are written as [] and {} rather than null.
+func JSON(w http.ResponseWriter, v interface{}, err error, codes ...Code) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		for _, c := range codes {
+			if errors.Is(err, c.Err) {
+				status = c.Status
+				break
+			}
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(empty(v))
+}
+
+// empty replaces a nil slice or map with an empty one.
+func empty(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Slice && rv.IsNil():
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	case rv.Kind() == reflect.Map && rv.IsNil():
+		return reflect.MakeMap(rv.Type()).Interface()
+	}
+	return v
+}
@@ -0,0 +1,35 @@
+package reply
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errGone = errors.New("gone")
+
+func TestJSON(t *testing.T) {
+	codes := []Code{{errGone, http.StatusNotFound}}
+	for _, c := range []struct {
+		v      interface{}
+		err    error
+		status int
+		body   string
+	}{
+		{[]string(nil), nil, http.StatusOK, "[]"},
+		{map[string]int(nil), nil, http.StatusOK, "{}"},
+		{[]int{1}, nil, http.StatusOK, "[1]"},
+		{nil, nil, http.StatusNoContent, ""},
+		{nil, fmt.Errorf("wrapped: %w", errGone), http.StatusNotFound, "wrapped: gone"},
+		{nil, errors.New("boom"), http.StatusInternalServerError, "boom"},
+	} {
+		w := httptest.NewRecorder()
+		JSON(w, c.v, c.err, codes...)
+		if w.Code != c.status || strings.TrimSpace(w.Body.String()) != c.body {
+			t.Errorf("%#v, %v: Expected %d %q, got %d %q", c.v, c.err, c.status, c.body, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+/*
+report writes a backtest report: its parameters, summary statistics, a
+grid of monthly returns, equity and drawdown curves, Monte Carlo
+intervals and the list of trades.
+
+The report is Markdown, so it diffs well in git, with the curves as
+inline SVG. HTML renders the same Markdown through goldmark into a
+standalone page. Every text taken from the report is escaped, so a
+parameter or symbol cannot inject markup into either.
+*/
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang_udemy/lesson1/backtest"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Report is what to report on.
+type Report struct {
+	Title  string
+	Params map[string]string
+	Ledger backtest.Ledger
+	// MonteCarlo adds the outcome of Ledger.Simulate when set.
+	MonteCarlo *backtest.Simulation
+}
+
+// Markdown writes r as Markdown.
+func Markdown(w io.Writer, r Report) error {
+	var b bytes.Buffer
+	title := r.Title
+	if title == "" {
+		title = "Backtest"
+	}
+	fmt.Fprintf(&b, "# %s\n", text(title))
+
+	b.WriteString("\n## Parameters\n\n| Parameter | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| capital | %.2f |\n", r.Ledger.Capital)
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "| %s | %s |\n", text(k), text(r.Params[k]))
+	}
+
+	s := r.Ledger.Stats()
+	b.WriteString("\n## Summary\n\n| Metric | Value |\n|---|--:|\n")
+	fmt.Fprintf(&b, "| Trades | %d |\n", s.Trades)
+	if s.Trades > 0 {
+		fmt.Fprintf(&b, "| Win rate | %s |\n", percent(float64(s.Wins)/float64(s.Trades)))
+	}
+	fmt.Fprintf(&b, "| Net profit | %.2f |\n", s.Net)
+	fmt.Fprintf(&b, "| Return | %s |\n", percent(s.Return))
+	fmt.Fprintf(&b, "| Max drawdown | %s |\n", percent(s.MaxDrawdown))
+	if math.IsInf(s.ProfitFactor, 1) {
+		b.WriteString("| Profit factor | ∞ |\n")
+	} else {
+		fmt.Fprintf(&b, "| Profit factor | %.2f |\n", s.ProfitFactor)
+	}
+	fmt.Fprintf(&b, "| Average win | %.2f |\n", s.AvgWin)
+	fmt.Fprintf(&b, "| Average loss | %.2f |\n", s.AvgLoss)
+
+	if months := r.Ledger.Monthly(); len(months) > 0 {
+		b.WriteString("\n## Monthly returns\n\n| Year |")
+		for m := time.January; m <= time.December; m++ {
+			b.WriteString(" " + m.String()[:3] + " |")
+		}
+		b.WriteString(" Year |\n|---|" + strings.Repeat("--:|", 13) + "\n")
+		for i := 0; i < len(months); {
+			year := months[i].Year
+			cells := make([]string, 12)
+			total := 1.0
+			for ; i < len(months) && months[i].Year == year; i++ {
+				cells[months[i].Month-1] = percent(months[i].Return)
+				total *= 1 + months[i].Return
+			}
+			fmt.Fprintf(&b, "| %d | %s | %s |\n", year, strings.Join(cells, " | "), percent(total-1))
+		}
+	}
+
+	if points := r.Ledger.Equity(); len(points) > 1 {
+		equity := make([]float64, len(points))
+		drawdown := make([]float64, len(points))
+		for i, p := range points {
+			equity[i], drawdown[i] = p.Equity, p.Drawdown*100
+		}
+		b.WriteString("\n## Equity\n\n")
+		curve(&b, points, equity, "#1e88e5", "%.0f")
+		b.WriteString("\n## Drawdown\n\n")
+		curve(&b, points, drawdown, "#e53935", "%.1f%%")
+	}
+
+	if mc := r.MonteCarlo; mc != nil && mc.Runs > 0 {
+		fmt.Fprintf(&b, "\n## Monte Carlo\n\n%d %s runs, %.0f%% intervals.\n\n", mc.Runs, text(string(mc.Method)), mc.Confidence*100)
+		b.WriteString("| Statistic | Low | Median | High |\n|---|--:|--:|--:|\n")
+		fmt.Fprintf(&b, "| Final equity | %.2f | %.2f | %.2f |\n", mc.FinalEquity.Lo, mc.FinalEquity.Median, mc.FinalEquity.Hi)
+		fmt.Fprintf(&b, "| Max drawdown | %s | %s | %s |\n", percent(mc.MaxDrawdown.Lo), percent(mc.MaxDrawdown.Median), percent(mc.MaxDrawdown.Hi))
+		fmt.Fprintf(&b, "| Trades to recover | %.0f | %.0f | %.0f |\n", mc.Recovery.Lo, mc.Recovery.Median, mc.Recovery.Hi)
+	}
+
+	if len(r.Ledger.Trades) > 0 {
+		b.WriteString("\n## Trades\n\n| # | Symbol | Entry | Exit | Quantity | Entry price | Exit price | Fees | PnL |\n|--:|---|---|---|--:|--:|--:|--:|--:|\n")
+		for i, t := range r.Ledger.Trades {
+			fmt.Fprintf(&b, "| %d | %s | %s | %s | %g | %.2f | %.2f | %.2f | %.2f |\n", i+1, text(t.Symbol),
+				t.Entry.Format("2006-01-02"), t.Exit.Format("2006-01-02"), t.Quantity, t.EntryPrice, t.ExitPrice, t.Fees, t.PnL())
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// HTML writes r as a standalone HTML page.
+func HTML(w io.Writer, r Report) error {
+	var md, body bytes.Buffer
+	if err := Markdown(&md, r); err != nil {
+		return err
+	}
+	// raw HTML is let through for the SVG curves; text() has escaped
+	// everything else
+	gm := goldmark.New(goldmark.WithExtensions(extension.Table), goldmark.WithRendererOptions(gmhtml.WithUnsafe()))
+	if err := gm.Convert(md.Bytes(), &body); err != nil {
+		return err
+	}
+	title := r.Title
+	if title == "" {
+		title = "Backtest"
+	}
+	_, err := fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; }
+</style>
+</head>
+<body>
+%s</body>
+</html>
+`, html.EscapeString(title), body.Bytes())
+	return err
+}
+
+// text escapes s for a Markdown paragraph or table cell: HTML specials
+// become entities, Markdown punctuation is backslashed and line breaks
+// become spaces.
+func text(s string) string {
+	s = html.EscapeString(s)
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		case strings.ContainsRune("\\`*_[]|#!~", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func percent(f float64) string {
+	return fmt.Sprintf("%.2f%%", f*100)
+}
+
+const (
+	svgWidth  = 720.0
+	svgHeight = 200.0
+	svgMargin = 50.0
+)
+
+// curve writes values as an SVG line over the times of points, laid out
+// by index. It writes no blank lines, which would end the Markdown HTML
+// block.
+func curve(b *bytes.Buffer, points []backtest.Point, values []float64, color, format string) {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	x := func(i int) float64 {
+		return svgMargin + float64(i)/float64(len(values)-1)*(svgWidth-2*svgMargin)
+	}
+	y := func(v float64) float64 {
+		return svgHeight - svgMargin/2 - (v-lo)/(hi-lo)*(svgHeight-svgMargin)
+	}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="10">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	for _, v := range []float64{lo, hi} {
+		fmt.Fprintf(b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#ddd"/><text x="%.0f" y="%.1f" text-anchor="end">`+format+"</text>\n",
+			svgMargin, svgWidth-svgMargin, y(v), y(v), svgMargin-4, y(v)+3, v)
+	}
+	var pts []string
+	for i, v := range values {
+		pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+	}
+	fmt.Fprintf(b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n", color, strings.Join(pts, " "))
+	last := len(points) - 1
+	fmt.Fprintf(b, `<text x="%.0f" y="%.0f">%s</text><text x="%.0f" y="%.0f" text-anchor="end">%s</text>`+"\n",
+		x(0), svgHeight-4, points[0].Time.Format("2006-01-02"), x(last), svgHeight-4, points[last].Time.Format("2006-01-02"))
+	b.WriteString("</svg>\n")
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/backtest"
+)
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var report = Report{
+	Title:  "SMA cross",
+	Params: map[string]string{"symbol": "BTC-USD", "strategy": "buy when a | b\n<script>alert(1)</script>"},
+	Ledger: backtest.Ledger{Capital: 1000, Trades: []backtest.Trade{
+		{Symbol: "BTC-USD", Entry: day(1, 2), Exit: day(1, 10), EntryPrice: 10, ExitPrice: 12, Quantity: 50},
+		{Symbol: "BTC-USD", Entry: day(1, 15), Exit: day(3, 5), EntryPrice: 12, ExitPrice: 9, Quantity: 100},
+	}},
+}
+
+func TestMarkdown(t *testing.T) {
+	r := report
+	sim := r.Ledger.Simulate(backtest.MonteCarlo{Method: backtest.Shuffle, Runs: 10})
+	r.MonteCarlo = &sim
+	var b strings.Builder
+	if err := Markdown(&b, r); err != nil {
+		t.Fatal(err)
+	}
+	md := b.String()
+	for _, want := range []string{
+		"# SMA cross\n",
+		"| strategy | buy when a \\| b &lt;script&gt;alert(1)&lt;/script&gt; |\n",
+		"| Win rate | 50.00% |\n",
+		"| 2024 | 10.00% | 0.00% | -27.27% |" + strings.Repeat("  |", 9) + " -20.00% |\n",
+		"## Equity\n\n<svg ",
+		"10 shuffle runs, 90% intervals.",
+		"| Final equity | 800.00 | 800.00 | 800.00 |\n",
+		"| 2 | BTC-USD | 2024-01-15 | 2024-03-05 | 100 | 12.00 | 9.00 | 0.00 | -300.00 |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected %q in\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "\n\n</svg>") {
+		t.Error("Expected no blank line inside the SVG")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var b strings.Builder
+	if err := HTML(&b, report); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	if strings.Contains(page, "<script>") {
+		t.Error("Expected parameters to be escaped\n", page)
+	}
+	for _, want := range []string{"<title>SMA cross</title>", "<table>", "<svg ", "<polyline ", `<td style="text-align:right">-20.00%</td>`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %q in\n%s", want, page)
+		}
+	}
+}
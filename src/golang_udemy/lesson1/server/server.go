@@ -0,0 +1,181 @@
+/*
+server mounts the HTTP handlers of the other packages on one mux.
+
+Every route but sign-up runs through the same chain: account.Store.Require
+authenticates the caller and checks the API key's scope, rbac.Enforcer.Require
+checks the caller's roles, and ratelimit.Limiter.Wrap throttles the caller.
+A per-IP limit in front of everything bounds password guessing.
+
+Each route's latency is recorded by path, method and status code, and
+admins can scrape it with the other metrics from /metrics.
+*/
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"golang_udemy/lesson1/account"
+	"golang_udemy/lesson1/alert"
+	"golang_udemy/lesson1/chart"
+	"golang_udemy/lesson1/correlation"
+	"golang_udemy/lesson1/demographics"
+	"golang_udemy/lesson1/marketpb"
+	"golang_udemy/lesson1/metrics"
+	_ "golang_udemy/lesson1/oms" // registers the order counters served at /metrics
+	"golang_udemy/lesson1/persons"
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/ratelimit"
+	"golang_udemy/lesson1/rbac"
+	"golang_udemy/lesson1/universe"
+)
+
+// Limits are the rate limits of each route group. The ip group applies
+// to every request, before authentication.
+var Limits = map[string]ratelimit.Limit{
+	"ip":         {Rate: 20, Burst: 40},
+	"accounts":   {Rate: 0.2, Burst: 5, Daily: 200},
+	"market":     {Rate: 5, Burst: 20, Daily: 20000},
+	"watchlists": {Rate: 5, Burst: 20},
+	"alerts":     {Rate: 5, Burst: 20},
+	"persons":    {Rate: 5, Burst: 20},
+	"analytics":  {Rate: 1, Burst: 5, Daily: 2000},
+	"admin":      {Rate: 2, Burst: 10},
+}
+
+var requestDuration = metrics.Default.NewHistogram("http_request_duration_seconds",
+	"Time taken to serve HTTP requests, by route, method and status code.", metrics.DefBuckets, "route", "method", "code")
+
+func init() {
+	metrics.Default.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+}
+
+// status records the code a handler replied with.
+type status struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *status) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *status) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// instrument records how long h takes to serve each request under route.
+func instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &status{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if sw.code == 0 {
+			sw.code = http.StatusOK
+		}
+		requestDuration.Since(start, route, r.Method, strconv.Itoa(sw.code))
+	})
+}
+
+// route is a group of paths served by one handler. GET and HEAD requests
+// need the read scope and permission, anything else the write ones.
+type route struct {
+	paths       []string
+	group       string
+	h           http.Handler
+	read, write account.Scope
+	may, change rbac.Permission
+}
+
+// New creates every table in db and returns the API, fetching candles
+// through p.
+func New(db *sql.DB, p provider.Provider) (http.Handler, error) {
+	accounts, err := account.NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	enforcer, err := rbac.NewEnforcer(db, rbac.DefaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := ratelimit.New(db, nil)
+	if err != nil {
+		return nil, err
+	}
+	uni, err := universe.NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := persons.NewRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	demo, err := demographics.New(db)
+	if err != nil {
+		return nil, err
+	}
+	history, err := alert.NewHistory(db)
+	if err != nil {
+		return nil, err
+	}
+
+	uh := universe.NewHandler(uni, account.Owner)
+	rh := rbac.NewHandler(enforcer)
+	routes := []route{
+		{[]string{"/markets", "/symbols", "/symbols/"}, "market", uh,
+			account.ScopeRead, account.ScopeRead, rbac.ReadMarket, rbac.ManageUniverse},
+		{[]string{"/watchlists", "/watchlists/"}, "watchlists", uh,
+			account.ScopeRead, account.ScopeWatchlists, rbac.ReadOwn, rbac.EditWatchlists},
+		{[]string{"/alerts"}, "alerts", alert.NewHandler(history, account.Owner),
+			account.ScopeRead, account.ScopeAlerts, rbac.ReadOwn, rbac.EditAlerts},
+		{[]string{"/persons", "/persons/"}, "persons", persons.NewHandler(repo, account.Actor),
+			account.ScopeRead, account.ScopeAdmin, rbac.ReadPersons, rbac.EditPersons},
+		{[]string{"/demographics/"}, "analytics", demographics.NewHandler(demo),
+			account.ScopeRead, account.ScopeRead, rbac.ReadPersons, rbac.ReadPersons},
+		{[]string{"/correlation"}, "analytics", correlation.NewHandler(uni, p, account.Owner),
+			account.ScopeRead, account.ScopeRead, rbac.ReadOwn, rbac.ReadOwn},
+		{[]string{"/chart.svg"}, "analytics", chart.NewHandler(p),
+			account.ScopeRead, account.ScopeRead, rbac.ReadMarket, rbac.ReadMarket},
+		{[]string{"/candles"}, "market", marketpb.NewHandler(p),
+			account.ScopeRead, account.ScopeRead, rbac.ReadMarket, rbac.ReadMarket},
+		{[]string{"/roles", "/roles/"}, "admin", rh,
+			account.ScopeAdmin, account.ScopeAdmin, rbac.ManageRoles, rbac.ManageRoles},
+		{[]string{"/denials"}, "admin", rh,
+			account.ScopeAdmin, account.ScopeAdmin, rbac.ReadAudit, rbac.ReadAudit},
+		{[]string{"/metrics"}, "admin", metrics.Default,
+			account.ScopeAdmin, account.ScopeAdmin, rbac.ReadAudit, rbac.ReadAudit},
+	}
+
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		h, err := limiter.Wrap(rt.group, Limits[rt.group], rt.h)
+		if err != nil {
+			return nil, err
+		}
+		h = enforcer.Require(h, rt.may, rt.change)
+		h = accounts.Require(h, rt.read, rt.write)
+		for _, path := range rt.paths {
+			mux.Handle(path, instrument(path, h))
+		}
+	}
+	// sign-up is public and key management authenticates by itself, so
+	// both are only limited per IP
+	ah, err := limiter.Wrap("accounts", Limits["accounts"], account.NewHandler(accounts, enforcer.MayManage))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []string{"/accounts", "/accounts/", "/keys", "/keys/"} {
+		mux.Handle(path, instrument(path, ah))
+	}
+	return limiter.Wrap("ip", Limits["ip"], mux)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang_udemy/lesson1/alert"
+	"golang_udemy/lesson1/provider"
+	"golang_udemy/lesson1/ratelimit"
+	"golang_udemy/lesson1/rbac"
+
+	quote "github.com/markcheno/go-quote"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestChain(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer func(lim ratelimit.Limit) { Limits["market"] = lim }(Limits["market"])
+	Limits["market"] = ratelimit.Limit{Rate: 0.001, Burst: 3}
+	p := provider.Func{ProviderName: "fake", Get: func(symbol, start, end string, period quote.Period) (quote.Quote, error) {
+		return quote.NewQuote(symbol, 0), nil
+	}}
+	h, err := New(db, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token, ok := strings.CutPrefix(user, "key "); ok {
+			r.Header.Set("Authorization", "Bearer "+token)
+		} else if user != "" {
+			r.SetBasicAuth(user, "pw")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	signup := func(username string) int64 {
+		w := do("POST", "/accounts", "", fmt.Sprintf(`{"username": %q, "password": "pw", "name": %q}`, username, username))
+		var rec struct{ ID int64 }
+		if err := json.NewDecoder(w.Body).Decode(&rec); err != nil || w.Code != http.StatusOK {
+			t.Fatal("Expected sign-up to succeed, got", w.Code, err)
+		}
+		return rec.ID
+	}
+	alice, bob := signup("alice"), signup("bob")
+	e, _ := rbac.NewEnforcer(db, rbac.DefaultPolicy)
+	e.Assign(alice, rbac.Admin)
+
+	if w := do("GET", "/markets", "", ""); w.Code != http.StatusUnauthorized {
+		t.Error("Expected anonymous request to be unauthorized, got", w.Code)
+	}
+	if w := do("GET", "/markets", "bob", ""); w.Code != http.StatusForbidden {
+		t.Error("Expected bob without roles to be forbidden, got", w.Code)
+	}
+	if w := do("PUT", fmt.Sprintf("/roles/%d/viewer", bob), "bob", ""); w.Code != http.StatusForbidden {
+		t.Error("Expected bob not to grant himself roles, got", w.Code)
+	}
+	if w := do("PUT", fmt.Sprintf("/roles/%d/viewer", bob), "alice", ""); w.Code != http.StatusNoContent {
+		t.Error("Expected alice to grant roles, got", w.Code)
+	}
+	if w := do("GET", "/markets", "bob", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "3" {
+		t.Error("Expected viewer to read markets with rate limit headers, got", w.Code, w.Header())
+	}
+	if w := do("PUT", "/watchlists/main", "bob", ""); w.Code != http.StatusForbidden {
+		t.Error("Expected viewer not to edit watchlists, got", w.Code)
+	}
+
+	w := do("POST", "/keys", "alice", `{"scopes": ["read"]}`)
+	var issued struct{ Token string }
+	json.NewDecoder(w.Body).Decode(&issued)
+	if w.Code != http.StatusOK || issued.Token == "" {
+		t.Fatal("Expected a key, got", w.Code, w.Body)
+	}
+	if w := do("PUT", "/watchlists/main", "key "+issued.Token, ""); w.Code != http.StatusForbidden {
+		t.Error("Expected read key not to edit watchlists, got", w.Code)
+	}
+	if w := do("PUT", "/watchlists/main", "alice", ""); w.Code != http.StatusNoContent {
+		t.Error("Expected alice to edit her watchlists, got", w.Code)
+	}
+	if w := do("GET", "/watchlists", "key "+issued.Token, ""); strings.TrimSpace(w.Body.String()) != `["main"]` {
+		t.Error("Expected alice's key to see her watchlist, got", w.Code, w.Body)
+	}
+	if w := do("GET", "/watchlists", "bob", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Error("Expected bob not to see alice's watchlists, got", w.Body)
+	}
+
+	history, _ := alert.NewHistory(db)
+	history.Add(alert.Alert{Owner: alice, RuleID: "dip", Symbol: "AAPL", BarTime: time.Unix(60, 0)})
+	if w := do("GET", "/alerts", "alice", ""); !strings.Contains(w.Body.String(), `"rule_id":"dip"`) {
+		t.Error("Expected alice to see her alert, got", w.Code, w.Body)
+	}
+	if w := do("GET", "/alerts", "bob", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Error("Expected bob not to see alice's alerts, got", w.Code, w.Body)
+	}
+
+	// bob used up his market bucket; alice has her own
+	do("GET", "/markets", "bob", "")
+	do("GET", "/markets", "bob", "")
+	if w := do("GET", "/markets", "bob", ""); w.Code != http.StatusTooManyRequests {
+		t.Error("Expected bob to be throttled, got", w.Code)
+	}
+	if w := do("GET", "/markets", "alice", ""); w.Code != http.StatusOK {
+		t.Error("Expected alice not to be throttled by bob, got", w.Code)
+	}
+
+	if w := do("GET", "/denials", "alice", ""); !strings.Contains(w.Body.String(), "GET /markets") {
+		t.Error("Expected bob's denial in the audit log, got", w.Code, w.Body)
+	}
+
+	if w := do("GET", "/metrics", "bob", ""); w.Code != http.StatusForbidden {
+		t.Error("Expected bob not to scrape metrics, got", w.Code)
+	}
+	w = do("GET", "/metrics", "alice", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatal("Expected alice to scrape metrics, got", w.Code, w.Header())
+	}
+	for _, want := range []string{
+		`http_request_duration_seconds_bucket{route="/markets",method="GET",code="429",le="+Inf"} 1`,
+		`http_request_duration_seconds_count{route="/accounts",method="POST",code="200"} 2`,
+		`persons_query_duration_seconds_count{op="create"}`,
+		"oms_orders_placed_total 0",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected %s in the metrics, got\n%s", want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package universe
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang_udemy/lesson1/reply"
+)
+
+// OwnerFunc works out the id of the authenticated person a request acts
+// for; account.Owner is one. The owner is never taken from the request
+// itself.
+type OwnerFunc func(r *http.Request) (int64, error)
+
+// NewHandler serves the universe over HTTP:
+//
+//	GET    /markets
+//	GET    /symbols?market=
+//	GET    /symbols/{symbol}
+//	GET    /watchlists
+//	GET    /watchlists/{name}
+//	PUT    /watchlists/{name}
+//	DELETE /watchlists/{name}
+//	POST   /watchlists/{name}/symbols       body: ["AAPL", ...]
+//	DELETE /watchlists/{name}/symbols/{symbol}
+func NewHandler(s *Store, owner OwnerFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /markets", func(w http.ResponseWriter, r *http.Request) {
+		markets, err := s.Markets()
+		reply.JSON(w, markets, err, codes...)
+	})
+	mux.HandleFunc("GET /symbols", func(w http.ResponseWriter, r *http.Request) {
+		syms, err := s.Symbols(r.URL.Query().Get("market"))
+		reply.JSON(w, syms, err, codes...)
+	})
+	mux.HandleFunc("GET /symbols/{symbol}", func(w http.ResponseWriter, r *http.Request) {
+		sym, err := s.Symbol(r.PathValue("symbol"))
+		reply.JSON(w, sym, err, codes...)
+	})
+
+	withOwner := func(h func(w http.ResponseWriter, r *http.Request, p int64)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			p, err := owner(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			h(w, r, p)
+		}
+	}
+	mux.HandleFunc("GET /watchlists", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		names, err := s.Watchlists(p)
+		reply.JSON(w, names, err, codes...)
+	}))
+	mux.HandleFunc("GET /watchlists/{name}", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		syms, err := s.Watchlist(p, r.PathValue("name"))
+		reply.JSON(w, syms, err, codes...)
+	}))
+	mux.HandleFunc("PUT /watchlists/{name}", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		reply.JSON(w, nil, s.CreateWatchlist(p, r.PathValue("name")), codes...)
+	}))
+	mux.HandleFunc("DELETE /watchlists/{name}", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		reply.JSON(w, nil, s.DeleteWatchlist(p, r.PathValue("name")), codes...)
+	}))
+	mux.HandleFunc("POST /watchlists/{name}/symbols", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		var syms []string
+		if err := json.NewDecoder(r.Body).Decode(&syms); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply.JSON(w, nil, s.Watch(p, r.PathValue("name"), syms...), codes...)
+	}))
+	mux.HandleFunc("DELETE /watchlists/{name}/symbols/{symbol}", withOwner(func(w http.ResponseWriter, r *http.Request, p int64) {
+		reply.JSON(w, nil, s.Unwatch(p, r.PathValue("name"), r.PathValue("symbol")), codes...)
+	}))
+	return mux
+}
+
+// codes are the statuses of the errors the handlers return.
+var codes = []reply.Code{
+	{Err: ErrUnknownSymbol, Status: http.StatusNotFound},
+	{Err: ErrNoWatchlist, Status: http.StatusNotFound},
+}
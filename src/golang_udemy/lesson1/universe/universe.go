@@ -0,0 +1,288 @@
+/*
+universe keeps the markets and symbols we track, and the watchlists
+persons build from them, in SQLite. Watchlists are owned by person id.
+*/
+package universe
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	quote "github.com/markcheno/go-quote"
+)
+
+var (
+	ErrUnknownSymbol = errors.New("universe: unknown symbol")
+	ErrNoWatchlist   = errors.New("universe: no such watchlist")
+)
+
+// Symbol is a tradable instrument and how to display it.
+type Symbol struct {
+	Symbol        string `json:"symbol"`
+	Market        string `json:"market"`
+	Exchange      string `json:"exchange,omitempty"`
+	QuoteCurrency string `json:"quote_currency,omitempty"`
+	Precision     int    `json:"precision"`
+}
+
+// Store reads and writes the universe tables.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates the universe tables in db if needed.
+func NewStore(db *sql.DB) (*Store, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS symbols(
+			symbol TEXT PRIMARY KEY,
+			market TEXT,
+			exchange TEXT,
+			quote_currency TEXT,
+			precision INT);
+		CREATE TABLE IF NOT EXISTS watchlists(
+			owner INTEGER,
+			name TEXT,
+			PRIMARY KEY(owner, name));
+		CREATE TABLE IF NOT EXISTS watchlist_symbols(
+			owner INTEGER,
+			name TEXT,
+			symbol TEXT REFERENCES symbols(symbol),
+			PRIMARY KEY(owner, name, symbol))`)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// AddSymbols inserts or updates syms.
+func (s *Store) AddSymbols(syms ...Symbol) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, sym := range syms {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO symbols(symbol, market, exchange, quote_currency, precision)
+			VALUES(?, ?, ?, ?, ?)`, sym.Symbol, sym.Market, sym.Exchange, sym.QuoteCurrency, sym.Precision)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Import adds plain symbol names to market, guessing the quote currency
+// from pairs such as BTC-USD and using go-quote's default precision.
+// Names are upper-cased since go-quote lower-cases symbol files.
+func (s *Store) Import(market string, names []string) error {
+	syms := make([]Symbol, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		sym := Symbol{Symbol: name, Market: market, Precision: 2}
+		if i := strings.LastIndexAny(name, "-/"); i > 0 {
+			sym.QuoteCurrency = name[i+1:]
+			sym.Precision = 8
+		}
+		syms = append(syms, sym)
+	}
+	return s.AddSymbols(syms...)
+}
+
+// ImportMarket downloads a go-quote market list (nasdaq, coinbase, ...) into market.
+func (s *Store) ImportMarket(market string) error {
+	if !quote.ValidMarket(market) {
+		return fmt.Errorf("universe: invalid market %q", market)
+	}
+	names, err := quote.NewMarketList(market)
+	if err != nil {
+		return err
+	}
+	return s.Import(market, names)
+}
+
+// ImportFile adds the symbols listed one per line in filename to market.
+func (s *Store) ImportFile(market, filename string) error {
+	names, err := quote.NewSymbolsFromFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.Import(market, names)
+}
+
+// Markets returns the markets that have symbols.
+func (s *Store) Markets() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT market FROM symbols ORDER BY market`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var markets []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		markets = append(markets, m)
+	}
+	return markets, rows.Err()
+}
+
+// Symbols returns the symbols of market, or every symbol if market is empty.
+func (s *Store) Symbols(market string) ([]Symbol, error) {
+	return s.query(`SELECT symbol, market, exchange, quote_currency, precision FROM symbols
+		WHERE ? = '' OR market = ? ORDER BY symbol`, market, market)
+}
+
+// Symbol looks up one symbol.
+func (s *Store) Symbol(name string) (Symbol, error) {
+	syms, err := s.query(`SELECT symbol, market, exchange, quote_currency, precision FROM symbols
+		WHERE symbol = ?`, name)
+	if err != nil {
+		return Symbol{}, err
+	}
+	if len(syms) == 0 {
+		return Symbol{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, name)
+	}
+	return syms[0], nil
+}
+
+// RemoveSymbol drops a symbol from the universe and from every watchlist.
+func (s *Store) RemoveSymbol(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM watchlist_symbols WHERE symbol = ?`, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM symbols WHERE symbol = ?`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateWatchlist creates an empty watchlist. It is a no-op if it already exists.
+func (s *Store) CreateWatchlist(owner int64, name string) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO watchlists(owner, name) VALUES(?, ?)`, owner, name)
+	return err
+}
+
+// DeleteWatchlist removes a watchlist and its entries.
+func (s *Store) DeleteWatchlist(owner int64, name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM watchlist_symbols WHERE owner = ? AND name = ?`, owner, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM watchlists WHERE owner = ? AND name = ?`, owner, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Watchlists returns the names of the watchlists of owner.
+func (s *Store) Watchlists(owner int64) ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM watchlists WHERE owner = ? ORDER BY name`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// Watch adds symbols, which must be in the universe, to a watchlist of owner.
+func (s *Store) Watch(owner int64, name string, symbols ...string) error {
+	if err := s.exists(owner, name); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, sym := range symbols {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM symbols WHERE symbol = ?`, sym).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", ErrUnknownSymbol, sym)
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO watchlist_symbols(owner, name, symbol) VALUES(?, ?, ?)`,
+			owner, name, sym)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Unwatch removes symbols from a watchlist of owner.
+func (s *Store) Unwatch(owner int64, name string, symbols ...string) error {
+	for _, sym := range symbols {
+		_, err := s.db.Exec(`DELETE FROM watchlist_symbols WHERE owner = ? AND name = ? AND symbol = ?`,
+			owner, name, sym)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watchlist returns the symbols on a watchlist of owner.
+func (s *Store) Watchlist(owner int64, name string) ([]Symbol, error) {
+	if err := s.exists(owner, name); err != nil {
+		return nil, err
+	}
+	return s.query(`SELECT s.symbol, s.market, s.exchange, s.quote_currency, s.precision
+		FROM watchlist_symbols w JOIN symbols s ON s.symbol = w.symbol
+		WHERE w.owner = ? AND w.name = ? ORDER BY s.symbol`, owner, name)
+}
+
+func (s *Store) exists(owner int64, name string) error {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM watchlists WHERE owner = ? AND name = ?`, owner, name).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d/%s", ErrNoWatchlist, owner, name)
+	}
+	return nil
+}
+
+func (s *Store) query(q string, args ...interface{}) ([]Symbol, error) {
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var syms []Symbol
+	for rows.Next() {
+		var sym Symbol
+		var exchange, currency sql.NullString
+		if err := rows.Scan(&sym.Symbol, &sym.Market, &exchange, &currency, &sym.Precision); err != nil {
+			return nil, err
+		}
+		sym.Exchange, sym.QuoteCurrency = exchange.String, currency.String
+		syms = append(syms, sym)
+	}
+	return syms, rows.Err()
+}
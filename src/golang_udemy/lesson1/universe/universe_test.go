@@ -0,0 +1,108 @@
+package universe
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newStore(t *testing.T) *Store {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWatchlist(t *testing.T) {
+	s := newStore(t)
+	s.Import("coinbase", []string{"BTC-USD", "ETH-USD"})
+	s.AddSymbols(Symbol{Symbol: "AAPL", Market: "nasdaq", Exchange: "NASDAQ", QuoteCurrency: "USD", Precision: 2})
+
+	const bob, alice = 1, 2
+	s.CreateWatchlist(bob, "crypto")
+	if err := s.Watch(bob, "crypto", "BTC-USD", "ETH-USD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Watch(bob, "crypto", "DOGE-USD"); !errors.Is(err, ErrUnknownSymbol) {
+		t.Error("Expected unknown symbol, got", err)
+	}
+	if _, err := s.Watchlist(alice, "crypto"); !errors.Is(err, ErrNoWatchlist) {
+		t.Error("Expected no watchlist for Alice, got", err)
+	}
+
+	s.RemoveSymbol("ETH-USD")
+	syms, _ := s.Watchlist(bob, "crypto")
+	if len(syms) != 1 || syms[0].QuoteCurrency != "USD" || syms[0].Precision != 8 {
+		t.Error("Expected BTC-USD only, got", syms)
+	}
+	markets, _ := s.Markets()
+	if strings.Join(markets, ",") != "coinbase,nasdaq" {
+		t.Error("Expected coinbase,nasdaq, got", markets)
+	}
+}
+
+// testOwner stands in for account.Owner, taking the basic auth user as
+// an already authenticated person id.
+func testOwner(r *http.Request) (int64, error) {
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		return 0, errors.New("not authenticated")
+	}
+	return strconv.ParseInt(user, 10, 64)
+}
+
+func TestHandler(t *testing.T) {
+	s := newStore(t)
+	s.Import("coinbase", []string{"BTC-USD"})
+	h := NewHandler(s, testOwner)
+
+	do := func(method, url, owner, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		if owner != "" {
+			r.SetBasicAuth(owner, "")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := do("PUT", "/watchlists/main", "1", ""); w.Code != http.StatusNoContent {
+		t.Error("Expected 204, got", w.Code)
+	}
+	if w := do("POST", "/watchlists/main/symbols", "1", `["BTC-USD"]`); w.Code != http.StatusNoContent {
+		t.Error("Expected 204, got", w.Code, w.Body)
+	}
+	if w := do("GET", "/watchlists/main", "1", ""); !strings.Contains(w.Body.String(), `"symbol":"BTC-USD"`) {
+		t.Error("Expected BTC-USD, got", w.Body)
+	}
+	if w := do("GET", "/watchlists/main?owner=1", "2", ""); w.Code != http.StatusNotFound {
+		t.Error("Expected another owner's list to stay hidden, got", w.Code)
+	}
+	if w := do("GET", "/watchlists/main", "", ""); w.Code != http.StatusUnauthorized {
+		t.Error("Expected 401, got", w.Code)
+	}
+	if w := do("GET", "/symbols/NOPE", "", ""); w.Code != http.StatusNotFound {
+		t.Error("Expected 404, got", w.Code)
+	}
+	s.AddSymbols(Symbol{Symbol: "0700", Market: "hkex"})
+	if w := do("GET", "/symbols/0700", "", ""); !strings.Contains(w.Body.String(), `"symbol":"0700"`) {
+		t.Error("Expected 0700 to keep its leading zero, got", w.Body)
+	}
+	if err := s.DeleteWatchlist(1, "main"); err != nil {
+		t.Error(err)
+	}
+	if w := do("GET", "/watchlists", "1", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Error("Expected no watchlists left, got", w.Body)
+	}
+}